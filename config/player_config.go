@@ -1,13 +1,43 @@
 package config
 
-const SAMPLE_RATE = 44100
+import (
+	"fmt"
+	"time"
+)
+
+// The audio format is configured once at startup (see SetAudioFormat) and must not change afterwards.
+var SAMPLE_RATE = 44100
 
 // The size of an AudioChunk. A frame consists of a sample for each channel.
-const FRAMES_PER_BUFFER = 1024
+var FRAMES_PER_BUFFER = 1024
+
+// 1 (mono) or 2 (stereo).
+var CHANNELS = 2
+
+// SetAudioFormat changes the audio format of the whole player (decoding, mixing and output).
+// It must be called before any clip is created.
+func SetAudioFormat(sampleRate, channels, framesPerBuffer int) error {
+	if sampleRate < 8000 || sampleRate > 192000 {
+		return fmt.Errorf("unsupported sample rate %d", sampleRate)
+	}
+	if channels != 1 && channels != 2 {
+		return fmt.Errorf("unsupported number of channels %d (only mono and stereo are supported)", channels)
+	}
+	if framesPerBuffer < 64 || framesPerBuffer > 16384 {
+		return fmt.Errorf("unsupported buffer size %d", framesPerBuffer)
+	}
+
+	SAMPLE_RATE = sampleRate
+	CHANNELS = channels
+	FRAMES_PER_BUFFER = framesPerBuffer
+
+	return nil
+}
 
-// Unfortunately the number of channels cannot be changed (easily),
-// as it requires changes in other places (for example AudioChunk) as well.
-const CHANNELS = 2
+// BufferDuration is the playback duration of FRAMES_PER_BUFFER frames.
+func BufferDuration() time.Duration {
+	return time.Duration(FRAMES_PER_BUFFER) * time.Second / time.Duration(SAMPLE_RATE)
+}
 
 // TARGET_MIN_RMS defines the minimum acceptable RMS (Root Mean Square) level for audio signals.
 // If the signal's RMS falls below this threshold, automatic amplification will be applied
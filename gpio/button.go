@@ -27,8 +27,20 @@ const (
 	// If no pulse detected for this long, consider button released
 	pulseTimeout = 15 * time.Millisecond
 
-	// If button released within this time, cancel the pause (just skip)
+	// If button released within this time, skip the current clip instead of pausing
 	longPressThreshold = 1 * time.Second
+
+	// Holding the button for this long sets (or extends) the sleep timer and continues the playback
+	sleepPressThreshold = 3 * time.Second
+
+	// Duration that is added to the sleep timer by every sleep press
+	sleepTimerStep = 30 * time.Minute
+
+	// A second short press within this time replays the last seconds instead of skipping
+	doublePressWindow = 400 * time.Millisecond
+
+	// Length of the replay
+	replayLength = 30 * time.Second
 )
 
 type ButtonEvent int
@@ -108,7 +120,9 @@ func InitGPIOButton(pinName string) {
 	go func() {
 		var pressStartTime time.Time
 		var longPressTimer *time.Timer
-		var pause *clips.PauseClip
+		var sleepPressTimer *time.Timer
+		// Pending skip of a short press (waiting for a possible second press)
+		var skipTimer *time.Timer
 
 		for event := range events {
 			switch event {
@@ -116,32 +130,69 @@ func InitGPIOButton(pinName string) {
 				log.Printf("[GPIO] Button %s pressed", pinName)
 				pressStartTime = time.Now()
 
-				// Create long pause and store reference so we can skip it later
-				pause = clips.NewPause(10 * time.Minute)
-				// Schedule the long pause
-				player.QueueClipNext(pause)
-				// Skip current clip (silent=false -> plays beep)
-				player.SkipCurrent(false)
+				if skipTimer != nil && skipTimer.Stop() {
+					// The user wants to hear the last seconds again.
+					log.Printf("[GPIO] Double press detected - replaying")
+					skipTimer = nil
+					longPressTimer = nil
+					// The first press paused the playback, it continues after the replay.
+					player.Resume()
+					if err := player.ReplayLast(replayLength); err != nil {
+						log.Printf("[GPIO] Failed to replay: %v", err)
+					}
+					break
+				}
+				skipTimer = nil
+
+				if player.IsPaused() {
+					// Any press while paused resumes the current clip.
+					log.Printf("[GPIO] Resuming playback")
+					player.Resume()
+					longPressTimer = nil
+					break
+				}
+
+				// Pause immediately, the release decides whether we skip or stay paused.
+				player.Pause()
+				player.PlayPriorityClip(clips.NewBeep())
 
 				longPressTimer = time.AfterFunc(longPressThreshold, func() {
 					// Indicate long press by playing a beep
 					player.PlayPriorityClip(clips.NewBeep())
 				})
+				sleepPressTimer = time.AfterFunc(sleepPressThreshold, func() {
+					// Two more beeps indicate that releasing the button sets the sleep timer
+					player.PlayPriorityClip(clips.NewBeep())
+					player.PlayPriorityClip(clips.NewBeep())
+				})
 			case ButtonReleased:
 				if longPressTimer == nil {
-					// This should never happen but we can handle it trivially
+					// The press resumed the playback (or was never registered).
 					break
 				}
 				longPressTimer.Stop()
+				longPressTimer = nil
+				sleepPressTimer.Stop()
+				sleepPressTimer = nil
 
 				pressDuration := time.Since(pressStartTime)
 				log.Printf("[GPIO] Button %s released (held for %v)", pinName, pressDuration)
 
-				// Handle short press. Long presses are handled in the longPressTimer callback.
-				if pressDuration < longPressThreshold {
-					// Skip pause, user just wants to skip the current clip.
-					log.Printf("[GPIO] Quick release detected - canceling pause")
-					pause.Stop()
+				// Handle short and very long presses. Long presses keep the playback paused.
+				switch {
+				case pressDuration < longPressThreshold:
+					// The user just wants to skip the current clip (unless a second press follows).
+					log.Printf("[GPIO] Quick release detected - skipping")
+					skipTimer = time.AfterFunc(doublePressWindow, func() {
+						player.SkipCurrent(true)
+					})
+				case pressDuration >= sleepPressThreshold:
+					// The user wants to fall asleep to the music.
+					log.Printf("[GPIO] Sleep press detected - extending the sleep timer")
+					if err := player.ExtendSleepTimer(sleepTimerStep); err != nil {
+						log.Printf("[GPIO] Failed to set the sleep timer: %v", err)
+					}
+					player.Resume()
 				}
 			}
 		}
@@ -0,0 +1,88 @@
+package library
+
+import (
+	"log"
+	"slices"
+	"time"
+)
+
+// Files are excluded from random picks after this many failed playbacks (0 = never).
+var maxFailures int32 = 3
+
+// SetMaxFailures sets after how many failed playbacks a file is considered broken.
+// Broken files are no longer picked randomly, but can still be scheduled manually.
+func SetMaxFailures(failures int) {
+	maxFailures = int32(max(0, failures))
+}
+
+// FileFailure describes the last failed playback of a file.
+type FileFailure struct {
+	Time  time.Time
+	Error string
+}
+
+// reportFailure is called whenever a clip of this file could not be played.
+func (file *LibraryFile) reportFailure(err error) {
+	file.lastFailure.Store(&FileFailure{Time: time.Now(), Error: err.Error()})
+	failures := file.failures.Add(1)
+
+	if maxFailures > 0 && failures == maxFailures {
+		log.Printf("Excluding '%s' from random picks after %d failures.", file.filepath, failures)
+		if set := getLibrarySetForFile(file.filepath); set != nil {
+			set.invalidate()
+		}
+	}
+}
+
+// Failures returns how often this file could not be played.
+func (file *LibraryFile) Failures() int {
+	return int(file.failures.Load())
+}
+
+// LastFailure returns the last failed playback (nil if there was none).
+func (file *LibraryFile) LastFailure() *FileFailure {
+	return file.lastFailure.Load()
+}
+
+// IsBroken returns true if the file failed too often and is therefore excluded from random picks.
+func (file *LibraryFile) IsBroken() bool {
+	return maxFailures > 0 && file.failures.Load() >= maxFailures
+}
+
+// ResetFailures gives the file another chance (e.g. after it has been repaired).
+func (file *LibraryFile) ResetFailures() {
+	wasBroken := file.IsBroken()
+	file.failures.Store(0)
+	file.lastFailure.Store(nil)
+
+	if wasBroken {
+		if set := getLibrarySetForFile(file.filepath); set != nil {
+			set.invalidate()
+		}
+	}
+}
+
+// ProblemFile is a file that failed at least once, along with its last failure.
+type ProblemFile struct {
+	File    *LibraryFile
+	Failure FileFailure
+}
+
+// GetProblemFiles returns all files that failed at least once, the most recent failure first.
+func GetProblemFiles() []ProblemFile {
+	var problems []ProblemFile
+	for _, set := range []*LibrarySet{songFiles, clipFiles, hostClips} {
+		for _, file := range set.problemFiles() {
+			// The failures might have been reset in the meantime.
+			if failure := file.LastFailure(); failure != nil {
+				problems = append(problems, ProblemFile{File: file, Failure: *failure})
+			}
+		}
+	}
+
+	slices.SortFunc(problems, func(a, b ProblemFile) int {
+		return b.Failure.Time.Compare(a.Failure.Time)
+	})
+
+	return problems
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,10 +20,14 @@ var hostClips = NewLibrarySet(128)
 var songFiles = NewLibrarySet(512)
 var clipFiles = NewLibrarySet(256)
 
+// The directory passed to WatchRootDir, folders are relative to it.
+var rootDir string
+
 func WatchRootDir(root string) {
 	if !folderExists(root) {
 		log.Fatalf("Folder '%s' does not exist.", root)
 	}
+	rootDir = root
 
 	fmt.Printf("Searching for files in %s...\n", root)
 	unknownFiles := 0
@@ -93,6 +98,10 @@ func WatchRootDir(root string) {
 		hostClips.loadMissingMetaData()
 
 		log.Println("Finished loading meta data.")
+
+		if loudnessCache != nil {
+			analyzeLoudnessInBackground()
+		}
 	}()
 }
 
@@ -202,6 +211,22 @@ func PickRandomHostClip() *LibraryFile {
 	return pickRandomClipWhichHasNotBeenPlayedInAWhile(hostClips)
 }
 
+// PickRandomFromFolder picks a random file from the given folder (or any of its subfolders)
+// relative to the library root, e.g. "music/morning". It returns nil if the folder does not contain any files.
+func PickRandomFromFolder(folder string) *LibraryFile {
+	folder = "/" + strings.Trim(filepath.ToSlash(folder), "/") + "/"
+
+	var candidates []*LibraryFile
+	for _, set := range []*LibrarySet{songFiles, clipFiles, hostClips} {
+		candidates = append(candidates, set.inFolder(folder)...)
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
 // Search the library for clips matching the query. The number of results will be limited by the given limit.
 func Search(query string, limit int) []*LibraryFile {
 	modifiedQuery := strings.Trim(strings.ToLower(query), " ")
@@ -293,6 +318,10 @@ func pickRandomClipWhichHasNotBeenPlayedInAWhile(clips *LibrarySet) *LibraryFile
 	var candidate *LibraryFile
 	for range 2 {
 		newCandidate := clips.GetRandom()
+		if newCandidate == nil {
+			log.Println("All files of the library set are broken.")
+			return nil
+		}
 		if newCandidate.lastPlayed == nil {
 			return newCandidate
 		}
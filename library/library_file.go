@@ -7,6 +7,7 @@ import (
 	"os"
 	fp "path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/tim-we/wavestreamer/player"
@@ -24,6 +25,14 @@ type LibraryFile struct {
 	playCount  int32
 	skipCount  int32
 	lastPlayed *time.Time
+	// Result of the loudness analysis (nil if not analyzed yet).
+	loudness        atomic.Pointer[decoder.LoudnessInfo]
+	loudnessChecked bool
+	// Silence detected during earlier plays (nil if unknown).
+	silence atomic.Pointer[clips.SilenceBoundaries]
+	// Failed playbacks (see failures.go).
+	failures    atomic.Int32
+	lastFailure atomic.Pointer[FileFailure]
 }
 
 func NewLibraryFile(filepath string) (*LibraryFile, error) {
@@ -31,7 +40,7 @@ func NewLibraryFile(filepath string) (*LibraryFile, error) {
 		return nil, fmt.Errorf("file '%s' not found", filepath)
 	}
 
-	return &LibraryFile{
+	file := &LibraryFile{
 		Id:         uuid.New(),
 		filepath:   filepath,
 		searchData: createSearchData(filepath, nil),
@@ -39,7 +48,10 @@ func NewLibraryFile(filepath string) (*LibraryFile, error) {
 		playCount:  0,
 		skipCount:  0,
 		lastPlayed: nil,
-	}, nil
+	}
+	file.loudness.Store(loudnessCache.get(filepath))
+
+	return file, nil
 }
 
 func (file *LibraryFile) CreateClip() *clips.AudioClip {
@@ -49,8 +61,10 @@ func (file *LibraryFile) CreateClip() *clips.AudioClip {
 	clip, err := clips.NewAudioClip(file.filepath)
 	if err != nil {
 		log.Println(err)
+		file.reportFailure(err)
 		return nil
 	}
+	clip.OnError = file.reportFailure
 	clip.OnStart = func(meta *decoder.AudioFileMetaData) {
 		now := time.Now()
 		file.lastPlayed = &now
@@ -58,6 +72,15 @@ func (file *LibraryFile) CreateClip() *clips.AudioClip {
 		file.meta = meta
 		file.searchData = createSearchData(file.filepath, meta)
 	}
+	clip.OnSilenceDetected = func(boundaries clips.SilenceBoundaries) {
+		file.silence.Store(&boundaries)
+	}
+	if silence := file.silence.Load(); silence != nil {
+		clip.SetSilenceBoundaries(*silence)
+	}
+	if loudness := file.loudness.Load(); loudness != nil {
+		clip.SetStaticGain(player.LoudnessGain(loudness.Integrated, loudness.TruePeak, loudnessTarget))
+	}
 	return clip
 }
 
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"path/filepath"
 	"runtime"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,7 +30,7 @@ func NewLibrarySet(initialCapacity int) *LibrarySet {
 	return &LibrarySet{
 		files:       make(map[string]*LibraryFile, initialCapacity),
 		idmap:       make(map[uuid.UUID]*LibraryFile, initialCapacity),
-		list:        make([]*LibraryFile, initialCapacity),
+		list:        make([]*LibraryFile, 0, initialCapacity),
 		recentPicks: make([]*LibraryFile, 0, RECENT_SIZE),
 		dirty:       false,
 	}
@@ -97,6 +99,10 @@ func (ls *LibrarySet) regenerateListIfNecessary() {
 
 	ls.list = make([]*LibraryFile, 0, len(ls.files))
 	for _, f := range ls.files {
+		if f.IsBroken() {
+			// Broken files are not picked randomly.
+			continue
+		}
 		ls.list = append(ls.list, f)
 	}
 	ls.dirty = false
@@ -108,7 +114,7 @@ func (ls *LibrarySet) UpdateInternals() {
 	ls.regenerateListIfNecessary()
 }
 
-// GetRandom returns a random file.
+// GetRandom returns a random file, or nil if all files are broken.
 func (ls *LibrarySet) GetRandom() *LibraryFile {
 	ls.regenerateListIfNecessary()
 
@@ -116,7 +122,7 @@ func (ls *LibrarySet) GetRandom() *LibraryFile {
 	defer ls.mu.RUnlock()
 
 	if len(ls.list) == 0 {
-		panic(fmt.Errorf("no files available"))
+		return nil
 	}
 
 	candidate := ls.list[rand.Intn(len(ls.list))]
@@ -139,6 +145,49 @@ func (ls *LibrarySet) GetRandom() *LibraryFile {
 	return candidate
 }
 
+// inFolder returns the (not broken) files in the given folder or its subfolders. The folder
+// is relative to the library root, with a leading and a trailing slash (e.g. "/music/morning/").
+func (ls *LibrarySet) inFolder(folder string) []*LibraryFile {
+	ls.regenerateListIfNecessary()
+
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	var files []*LibraryFile
+	for _, file := range ls.list {
+		path, err := filepath.Rel(rootDir, file.filepath)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix("/"+filepath.ToSlash(path), folder) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// invalidate regenerates the list for random access before the next pick.
+func (ls *LibrarySet) invalidate() {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.dirty = true
+}
+
+// problemFiles returns the files that failed at least once.
+func (ls *LibrarySet) problemFiles() []*LibraryFile {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	var files []*LibraryFile
+	for _, file := range ls.files {
+		if file.LastFailure() != nil {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
 // GetById returns a file with the given id if it exists, nil otherwise.
 func (ls *LibrarySet) GetById(clipId uuid.UUID) *LibraryFile {
 	ls.mu.RLock()
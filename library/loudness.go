@@ -0,0 +1,203 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tim-we/wavestreamer/player/decoder"
+	"github.com/tim-we/wavestreamer/utils"
+)
+
+// Target loudness in LUFS. Only used if the loudness analysis is enabled.
+var loudnessTarget float64
+
+// Persistent results of the loudness analysis (nil if the analysis is disabled).
+var loudnessCache *analysisCache
+
+// Time between two scans for files that have not been analyzed yet (e.g. new files).
+const loudnessRescanInterval = 10 * time.Minute
+
+// EnableLoudnessNormalization measures the loudness of every file in the background and plays
+// them at the given target loudness (LUFS). The results are cached in the given directory.
+// It must be called before WatchRootDir.
+func EnableLoudnessNormalization(cacheDir string, target float64) {
+	loudnessTarget = target
+	loudnessCache = loadAnalysisCache(filepath.Join(cacheDir, "loudness.json"))
+}
+
+func analyzeLoudnessInBackground() {
+	for {
+		analyzed := 0
+
+		for _, set := range []*LibrarySet{songFiles, clipFiles, hostClips} {
+			for _, file := range set.getFilesWithoutLoudness() {
+				// Analyzing decodes the whole file, it should not compete with the playback.
+				for utils.ShouldReduceCPU() {
+					time.Sleep(time.Minute)
+				}
+
+				file.analyzeLoudness()
+				analyzed++
+
+				if analyzed%10 == 0 {
+					loudnessCache.save()
+				}
+			}
+		}
+
+		if analyzed > 0 {
+			loudnessCache.save()
+			log.Printf("Finished loudness analysis of %d files.", analyzed)
+		}
+
+		time.Sleep(loudnessRescanInterval)
+	}
+}
+
+func (file *LibraryFile) analyzeLoudness() {
+	file.loudnessChecked = true
+
+	info, err := decoder.AnalyzeLoudness(file.filepath)
+	if err != nil {
+		log.Printf("Loudness analysis failed: %v", err)
+		return
+	}
+
+	file.loudness.Store(info)
+	loudnessCache.put(file.filepath, info)
+}
+
+func (ls *LibrarySet) getFilesWithoutLoudness() []*LibraryFile {
+	ls.regenerateListIfNecessary()
+
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+
+	files := make([]*LibraryFile, 0, len(ls.list))
+
+	for _, file := range ls.list {
+		if file.loudness.Load() == nil && !file.loudnessChecked {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// analysisCache stores analysis results on disk. Entries are invalidated when the file changes.
+type analysisCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]analysisCacheEntry
+	dirty   bool
+}
+
+type analysisCacheEntry struct {
+	Size     int64                 `json:"size"`
+	ModTime  time.Time             `json:"modTime"`
+	Loudness *decoder.LoudnessInfo `json:"loudness"`
+}
+
+func loadAnalysisCache(path string) *analysisCache {
+	cache := &analysisCache{
+		path:    path,
+		entries: make(map[string]analysisCacheEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to read analysis cache: %v", err)
+		}
+		return cache
+	}
+
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		log.Printf("Failed to parse analysis cache %s: %v", path, err)
+	}
+
+	return cache
+}
+
+// get returns the cached loudness of the file if the file has not changed since the analysis.
+func (cache *analysisCache) get(path string) *decoder.LoudnessInfo {
+	if cache == nil {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, ok := cache.entries[path]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+
+	return entry.Loudness
+}
+
+func (cache *analysisCache) put(path string, loudness *decoder.LoudnessInfo) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries[path] = analysisCacheEntry{
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Loudness: loudness,
+	}
+	cache.dirty = true
+}
+
+func (cache *analysisCache) save() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if !cache.dirty {
+		return
+	}
+
+	// Forget files that no longer exist.
+	for path := range cache.entries {
+		if !fileExists(path) {
+			delete(cache.entries, path)
+		}
+	}
+
+	data, err := json.Marshal(cache.entries)
+	if err != nil {
+		log.Printf("Failed to encode analysis cache: %v", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cache.path), 0o755); err != nil {
+		log.Printf("Failed to save analysis cache: %v", err)
+		return
+	}
+
+	tmpPath := cache.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		log.Printf("Failed to save analysis cache: %v", err)
+		return
+	}
+	if err := os.Rename(tmpPath, cache.path); err != nil {
+		log.Printf("Failed to save analysis cache: %v", err)
+		return
+	}
+
+	cache.dirty = false
+}
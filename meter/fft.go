@@ -0,0 +1,93 @@
+package meter
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// The spectrum is divided into logarithmically spaced bands between these frequencies.
+const (
+	spectrumBands   = 16
+	spectrumMinFreq = 50.0
+	spectrumMaxFreq = 16000.0
+)
+
+// Lowest reported level in dBFS.
+const spectrumFloor = -100
+
+// SpectrumBands returns the edges of the frequency bands in Hz (one more than the number of bands).
+func SpectrumBands(sampleRate int) []float64 {
+	high := min(spectrumMaxFreq, float64(sampleRate)/2)
+	edges := make([]float64, spectrumBands+1)
+	for i := range edges {
+		edges[i] = spectrumMinFreq * math.Pow(high/spectrumMinFreq, float64(i)/spectrumBands)
+	}
+	return edges
+}
+
+// computeSpectrum returns the level of the strongest frequency of every band in dBFS.
+// A full scale sine results in (about) 0 dB in its band.
+func computeSpectrum(samples []float32, sampleRate int) []float32 {
+	n := len(samples)
+	bins := make([]complex128, n)
+	for i, sample := range samples {
+		// Hann window
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		bins[i] = complex(float64(sample)*window, 0)
+	}
+	fft(bins)
+
+	// The window halves the amplitude, only half of the energy is in the positive frequencies.
+	scale := 4 / float64(n)
+	binWidth := float64(sampleRate) / float64(n)
+
+	edges := SpectrumBands(sampleRate)
+	spectrum := make([]float32, spectrumBands)
+	for band := range spectrum {
+		first := int(edges[band] / binWidth)
+		last := max(first, int(math.Ceil(edges[band+1]/binWidth))-1)
+
+		var magnitude float64
+		for bin := first; bin <= last && bin < n/2; bin++ {
+			magnitude = max(magnitude, cmplx.Abs(bins[bin])*scale)
+		}
+
+		level := float64(spectrumFloor)
+		if magnitude > 0 {
+			level = max(spectrumFloor, 20*math.Log10(magnitude))
+		}
+		spectrum[band] = float32(level)
+	}
+
+	return spectrum
+}
+
+// fft is an in-place radix-2 FFT. The length must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+
+	// Bit reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := range size / 2 {
+				even, odd := x[start+k], x[start+k+size/2]*w
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package meter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+// Levels are measured over this period, which limits the update rate to 20 per second.
+const measurePeriod = 50 * time.Millisecond
+
+// Number of (mono) samples used for the spectrum.
+const fftSize = 1024
+
+// Meter measures the levels of the player output. It only does work while someone is listening.
+type Meter struct {
+	// Number of listeners, the measurement is skipped if there are none.
+	listeners atomic.Int32
+
+	// The latest measurement.
+	latest atomic.Pointer[Levels]
+
+	// Accumulated values of the current period. Only used from the audio thread.
+	sumSquares []float64
+	peak       []float32
+	frames     int
+	window     []float32 // ring buffer of the last fftSize mono samples
+	windowPos  int
+}
+
+// Levels is a measurement of the output over a short period.
+type Levels struct {
+	// Per channel, linear (1 = full scale).
+	RMS  []float32
+	Peak []float32
+
+	// The last fftSize samples (mixed down to mono, in chronological order).
+	samples      []float32
+	spectrum     []float32
+	spectrumOnce sync.Once
+}
+
+func NewMeter() *Meter {
+	return &Meter{
+		sumSquares: make([]float64, config.CHANNELS),
+		peak:       make([]float32, config.CHANNELS),
+		window:     make([]float32, fftSize),
+	}
+}
+
+// Write is an output tap. It is called from the audio thread and never blocks.
+func (m *Meter) Write(out [][]float32) {
+	if m.listeners.Load() == 0 {
+		m.frames = 0
+		return
+	}
+
+	if m.frames == 0 {
+		clear(m.sumSquares)
+		clear(m.peak)
+	}
+
+	frames := len(out[0])
+	for ch, samples := range out {
+		var sum float64
+		peak := m.peak[ch]
+		for _, sample := range samples {
+			sum += float64(sample * sample)
+			peak = max(peak, float32(math.Abs(float64(sample))))
+		}
+		m.sumSquares[ch] += sum
+		m.peak[ch] = peak
+	}
+
+	scale := 1 / float32(len(out))
+	for i := range frames {
+		var mono float32
+		for _, samples := range out {
+			mono += samples[i]
+		}
+		m.window[m.windowPos] = mono * scale
+		m.windowPos = (m.windowPos + 1) % fftSize
+	}
+
+	m.frames += frames
+	if m.frames >= int(measurePeriod.Seconds()*float64(config.SAMPLE_RATE)) {
+		m.publish()
+	}
+}
+
+// publish stores the measurement of the current period and starts a new one.
+func (m *Meter) publish() {
+	levels := &Levels{
+		RMS:     make([]float32, len(m.sumSquares)),
+		Peak:    make([]float32, len(m.peak)),
+		samples: make([]float32, fftSize),
+	}
+	for ch, sum := range m.sumSquares {
+		levels.RMS[ch] = float32(math.Sqrt(sum / float64(m.frames)))
+	}
+	copy(levels.Peak, m.peak)
+	n := copy(levels.samples, m.window[m.windowPos:])
+	copy(levels.samples[n:], m.window[:m.windowPos])
+
+	m.latest.Store(levels)
+	m.frames = 0
+}
+
+// Listen sends the latest levels at the given interval until the context is done.
+// Nothing is sent while no audio is played.
+func (m *Meter) Listen(ctx context.Context, interval time.Duration) <-chan *Levels {
+	updates := make(chan *Levels, 1)
+	m.listeners.Add(1)
+
+	go func() {
+		defer close(updates)
+		defer m.listeners.Add(-1)
+
+		ticker := time.NewTicker(max(interval, measurePeriod))
+		defer ticker.Stop()
+
+		var last *Levels
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			levels := m.latest.Load()
+			if levels == nil || levels == last {
+				continue
+			}
+			last = levels
+
+			select {
+			case updates <- levels:
+			default:
+				// The listener is too slow, skip this update.
+			}
+		}
+	}()
+
+	return updates
+}
+
+// Spectrum returns the levels of the frequency bands (see SpectrumBands) in dBFS.
+// It is computed on the first call.
+func (levels *Levels) Spectrum() []float32 {
+	levels.spectrumOnce.Do(func() {
+		levels.spectrum = computeSpectrum(levels.samples, config.SAMPLE_RATE)
+	})
+	return levels.spectrum
+}
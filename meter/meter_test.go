@@ -0,0 +1,74 @@
+package meter
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+func TestSpectrumOfSine(t *testing.T) {
+	const sampleRate = 44100
+	const frequency = 1000.0
+
+	samples := make([]float32, fftSize)
+	for i := range samples {
+		samples[i] = float32(math.Sin(2 * math.Pi * frequency * float64(i) / sampleRate))
+	}
+
+	spectrum := computeSpectrum(samples, sampleRate)
+	edges := SpectrumBands(sampleRate)
+
+	for band, level := range spectrum {
+		if edges[band] <= frequency && frequency < edges[band+1] {
+			if level < -1.5 || level > 0.5 {
+				t.Errorf("Expected about 0 dB in the band of the sine, got %.1f dB", level)
+			}
+		} else if edges[band+1] < frequency/2 || edges[band] > frequency*2 {
+			if level > -40 {
+				t.Errorf("Expected little energy in band %d (%.0f Hz), got %.1f dB", band, edges[band], level)
+			}
+		}
+	}
+}
+
+func TestMeterMeasuresLevels(t *testing.T) {
+	sampleRate, channels, frames := config.SAMPLE_RATE, config.CHANNELS, config.FRAMES_PER_BUFFER
+	defer config.SetAudioFormat(sampleRate, channels, frames)
+	if err := config.SetAudioFormat(8000, 2, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	meter := NewMeter()
+
+	// Nothing is measured without listeners.
+	out := [][]float32{make([]float32, 400), make([]float32, 400)}
+	meter.Write(out)
+	if meter.latest.Load() != nil {
+		t.Error("Expected no measurement without listeners")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := meter.Listen(ctx, measurePeriod)
+
+	// A square wave on the left channel, silence on the right.
+	for i := range out[0] {
+		out[0][i] = 0.5
+		if i%2 == 1 {
+			out[0][i] = -0.5
+		}
+	}
+	meter.Write(out)
+
+	select {
+	case levels := <-updates:
+		if levels.RMS[0] != 0.5 || levels.Peak[0] != 0.5 || levels.RMS[1] != 0 || levels.Peak[1] != 0 {
+			t.Errorf("Unexpected levels: RMS %v, peak %v", levels.RMS, levels.Peak)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No levels received")
+	}
+}
@@ -1,24 +1,77 @@
 package player
 
 import (
+	"sync"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
 	"github.com/tim-we/wavestreamer/utils"
 )
 
 type AudioChunk struct {
-	// Left channel samples.
-	Left []float32
-
-	// Right channel samples.
-	Right []float32
+	// Samples of each channel (config.CHANNELS slices of config.FRAMES_PER_BUFFER samples).
+	Channels [][]float32
 
-	// Number of samples in this chunk (up to FRAMES_PER_BUFFER).
+	// Number of frames in this chunk (up to FRAMES_PER_BUFFER).
 	Length int
 
-	// Root mean square of this chunk's audio (stereo average).
+	// Root mean square of this chunk's audio (average of all channels).
 	RMS float32
 
-	// Maximum absolute sample value across both channels.
+	// Maximum absolute sample value across all channels.
 	Peak float32
+
+	// Whether the chunk has been created by GetAudioChunk and can be returned to the pool.
+	pooled bool
+}
+
+// Chunks are recycled once they have been copied to the output.
+var chunkPool sync.Pool
+
+// NewAudioChunk creates a silent chunk in the configured audio format.
+// The length is set to a full buffer.
+func NewAudioChunk() *AudioChunk {
+	channels := make([][]float32, config.CHANNELS)
+	for i := range channels {
+		channels[i] = make([]float32, config.FRAMES_PER_BUFFER)
+	}
+
+	return &AudioChunk{
+		Channels: channels,
+		Length:   config.FRAMES_PER_BUFFER,
+	}
+}
+
+// GetAudioChunk returns a silent chunk like NewAudioChunk, but reuses a released chunk if possible.
+// The chunk should be released once its samples are no longer needed.
+func GetAudioChunk() *AudioChunk {
+	if chunk, ok := chunkPool.Get().(*AudioChunk); ok && chunk.hasConfiguredFormat() {
+		for _, samples := range chunk.Channels {
+			clear(samples)
+		}
+		chunk.Length = config.FRAMES_PER_BUFFER
+		chunk.RMS = 0
+		chunk.Peak = 0
+		return chunk
+	}
+
+	chunk := NewAudioChunk()
+	chunk.pooled = true
+	return chunk
+}
+
+// Release returns the chunk to the pool. It must not be used afterwards.
+// Chunks that have not been created by GetAudioChunk (e.g. shared ones) are left alone.
+func (chunk *AudioChunk) Release() {
+	if chunk == nil || !chunk.pooled {
+		return
+	}
+	chunkPool.Put(chunk)
+}
+
+// The audio format might have changed (e.g. in tests) since the chunk was created.
+func (chunk *AudioChunk) hasConfiguredFormat() bool {
+	return len(chunk.Channels) == config.CHANNELS && len(chunk.Channels[0]) == config.FRAMES_PER_BUFFER
 }
 
 func (chunk *AudioChunk) ApplyGain(startGain, endGain float32) {
@@ -31,9 +84,34 @@ func (chunk *AudioChunk) ApplyGain(startGain, endGain float32) {
 	b := (endGain - startGain) / float32(max(1, chunk.Length))
 
 	// Linearly interpolate gain and apply gain with soft limit:
-	for i := range chunk.Length {
-		gain := a + float32(i)*b
-		chunk.Left[i] = utils.SoftLimitGain(chunk.Left[i], gain)
-		chunk.Right[i] = utils.SoftLimitGain(chunk.Right[i], gain)
+	for _, samples := range chunk.Channels {
+		for i := range chunk.Length {
+			gain := a + float32(i)*b
+			samples[i] = utils.SoftLimitGain(samples[i], gain)
+		}
 	}
 }
+
+// Playback duration of a full chunk.
+func chunkDuration() time.Duration {
+	return config.BufferDuration()
+}
+
+// Duration returns the playback duration of the samples in this chunk.
+func (chunk *AudioChunk) Duration() time.Duration {
+	return time.Duration(chunk.Length) * time.Second / time.Duration(config.SAMPLE_RATE)
+}
+
+// MixIn adds the samples of the other chunk to this chunk.
+// RMS and Peak are updated to an upper bound of the mixed signal.
+func (chunk *AudioChunk) MixIn(other *AudioChunk) {
+	for ch, samples := range chunk.Channels {
+		for i := range other.Length {
+			samples[i] += other.Channels[ch][i]
+		}
+	}
+
+	chunk.Length = max(chunk.Length, other.Length)
+	chunk.RMS = min(1, chunk.RMS+other.RMS)
+	chunk.Peak = min(1, chunk.Peak+other.Peak)
+}
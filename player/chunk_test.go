@@ -0,0 +1,32 @@
+package player
+
+import "testing"
+
+func TestReleasedChunksAreSilent(t *testing.T) {
+	chunk := GetAudioChunk()
+	chunk.Channels[0][0] = 1
+	chunk.Length = 1
+	chunk.Release()
+
+	// The pool might return a different chunk, but never a dirty one.
+	chunk = GetAudioChunk()
+	if chunk.Channels[0][0] != 0 || chunk.Length != len(chunk.Channels[0]) {
+		t.Errorf("Expected a silent full-length chunk, got sample %f and length %d", chunk.Channels[0][0], chunk.Length)
+	}
+}
+
+func BenchmarkNewAudioChunk(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		chunk := NewAudioChunk()
+		chunk.Release()
+	}
+}
+
+func BenchmarkGetAudioChunk(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		chunk := GetAudioChunk()
+		chunk.Release()
+	}
+}
@@ -1,6 +1,12 @@
 package player
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrSeekNotSupported is returned by clips that cannot change their playback position.
+var ErrSeekNotSupported = errors.New("clip does not support seeking")
 
 // Clip defines an interface for audio playback sources.
 type Clip interface {
@@ -22,4 +28,26 @@ type Clip interface {
 
 	// Whether the clip should be hidden from the history
 	Hidden() bool
+
+	// Whether the clip supports seeking.
+	CanSeek() bool
+
+	// Seek moves the playback position to the given offset from the start of the clip.
+	// Returns ErrSeekNotSupported if the clip cannot seek.
+	Seek(position time.Duration) error
+}
+
+// FailingClip is implemented by clips that can fail while they are played (e.g. decoding errors).
+// A failed clip ends early.
+type FailingClip interface {
+	// Err returns why the clip failed, or nil.
+	Err() error
+}
+
+// clipError returns why the clip failed (nil if it did not fail or cannot fail).
+func clipError(clip Clip) error {
+	if failing, ok := clip.(FailingClip); ok {
+		return failing.Err()
+	}
+	return nil
 }
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/tim-we/wavestreamer/config"
@@ -15,127 +17,337 @@ import (
 
 type AudioClip struct {
 	filepath string
-	decoder  *d.DecodingProcess
 	meta     *d.AudioFileMetaData
-	buffer   chan *player.AudioChunk
 	started  bool
 	stopped  bool
-	OnStart  func(meta *d.AudioFileMetaData)
-	OnStop   func()
+	// Crossfades are allowed unless explicitly disabled (e.g. for the news).
+	noCrossfade bool
+	// Gain that brings the clip to the target loudness (if known).
+	staticGain    float32
+	hasStaticGain bool
+	OnStart       func(meta *d.AudioFileMetaData)
+	OnStop        func()
+	// Called when silence has been detected at the beginning or the end of the file.
+	OnSilenceDetected func(boundaries SilenceBoundaries)
+	// Called when decoding failed. The clip ends afterwards.
+	OnError func(err error)
+
+	// The decoding state may be replaced when seeking.
+	mu      sync.Mutex
+	session *decodingSession
+	offset  time.Duration // start position of the next decoding session
+	// The audible part of the file. All positions of the clip are relative to its start.
+	silence SilenceBoundaries
+	// Why decoding failed (nil if it did not).
+	err error
+}
+
+// decodingSession is a running decoding process and the goroutine filling the buffer.
+type decodingSession struct {
+	decoder   d.Decoder
+	buffer    chan *player.AudioChunk
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// File position of the next chunk and where decoding should stop (0 = end of file).
+	position time.Duration
+	end      time.Duration
+
+	// Removes leading and trailing silence (nil if disabled).
+	trimmer *silenceTrimmer
+
+	// Set by the decoding goroutine before the buffer is closed if decoding failed.
+	err error
 }
 
 func NewAudioClip(filepath string) (*AudioClip, error) {
 	return NewAudioClipWithMetaData(filepath, nil)
 }
 
+// NewAudioClipWithMetaData creates a clip for the given file. The decoding process is not
+// started until the clip is prepared or played, so queued clips do not hold any resources.
 func NewAudioClipWithMetaData(filepath string, providedMetaData *d.AudioFileMetaData) (*AudioClip, error) {
 	if !fileExists(filepath) {
 		return nil, fmt.Errorf("file '%s' not found", filepath)
 	}
 
-	decoder := d.NewDecodingProcess(filepath)
-
 	meta := providedMetaData
 
 	// If the meta data was already provided by the caller we don't have to call ffprobe again.
 	if providedMetaData == nil {
 		if newMeta, metaErr := d.GetFileMetadata(filepath); metaErr != nil {
-			decoder.Close()
 			return nil, fmt.Errorf("failed to get meta data of '%s'", filepath)
 		} else {
 			meta = newMeta
 		}
 	}
 
-	if err := decoder.StartDecoding(); err != nil {
-		return nil, fmt.Errorf("failed to start the decoding process of '%s'", filepath)
-	}
-
-	buffer := make(chan *player.AudioChunk, 16)
-
 	clip := AudioClip{
 		filepath: filepath,
-		decoder:  &decoder,
 		meta:     meta,
-		buffer:   buffer,
 		started:  false,
 	}
 
-	go func() {
-		defer close(buffer)
+	return &clip, nil
+}
 
-		for {
-			// Create empty chunk.
-			chunk := player.AudioChunk{
-				Left:  make([]float32, config.FRAMES_PER_BUFFER),
-				Right: make([]float32, config.FRAMES_PER_BUFFER),
-			}
+// Prepare starts the decoding process in the background, such that the first chunks are
+// already buffered when the clip starts playing. Calling it more than once has no effect.
+func (clip *AudioClip) Prepare() {
+	clip.mu.Lock()
+	defer clip.mu.Unlock()
 
-			eofReached := false
-			var peak float32 = 0.0
-			var rmsAcc float64 = 0.0
-
-			// Fill chunk and analyze data.
-			for i := range config.FRAMES_PER_BUFFER {
-				left, right, err := decoder.ReadFrame()
-
-				if err != nil {
-					if err == io.EOF {
-						eofReached = true
-						// TODO: Do we need this?
-						decoder.WaitForExit()
-					} else {
-						fmt.Printf("Unexpected decoding error:\n%v\n", err)
-						return
-					}
-					break
-				}
+	if clip.session == nil && !clip.stopped {
+		clip.session = clip.startDecodingSession()
+	}
+}
+
+// startDecodingSession decodes the file from the current offset. The caller must hold the lock.
+func (clip *AudioClip) startDecodingSession() *decodingSession {
+	fileOffset := clip.silence.Start + clip.offset
+
+	var trimmer *silenceTrimmer
+	if trimSilence {
+		trimmer = &silenceTrimmer{
+			leading:  fileOffset == 0,
+			trailing: clip.silence.End == 0,
+			position: fileOffset,
+			onStart:  clip.setSilenceStart,
+			onEnd:    clip.setSilenceEnd,
+		}
+	}
+
+	return startDecodingSession(clip.filepath, fileOffset, clip.silence.End, trimmer)
+}
+
+func startDecodingSession(filepath string, offset, end time.Duration, trimmer *silenceTrimmer) *decodingSession {
+	session := &decodingSession{
+		buffer:   make(chan *player.AudioChunk, 16),
+		done:     make(chan struct{}),
+		position: offset,
+		end:      end,
+		trimmer:  trimmer,
+	}
+
+	decoder, err := d.NewDecoder(filepath, offset)
+	if err != nil {
+		session.err = fmt.Errorf("failed to start decoding: %w", err)
+		close(session.buffer)
+		return session
+	}
+
+	session.decoder = decoder
+
+	go session.decode()
+
+	return session
+}
 
-				peak = max(peak, max(absf32(left), absf32(right)))
-				rmsAcc += float64(left*left + right*right)
+func (session *decodingSession) decode() {
+	defer close(session.buffer)
+
+	for {
+		// Fill chunk with the next frames.
+		chunk := player.GetAudioChunk()
+		frames, err := session.decoder.ReadChunk(chunk.Channels)
+		chunk.Length = frames
+
+		eofReached := false
+		if err == io.EOF {
+			eofReached = true
+		} else if err != nil {
+			select {
+			case <-session.done:
+				// Reading fails after the process has been closed.
+			default:
+				session.err = err
+			}
+			chunk.Release()
+			session.close()
+			return
+		}
 
-				chunk.Left[i] = left
-				chunk.Right[i] = right
-				chunk.Length++
+		// Analyze data.
+		var peak float32 = 0.0
+		var rmsAcc float64 = 0.0
+		for _, samples := range chunk.Channels {
+			var sum float32
+			for _, sample := range samples[:frames] {
+				peak = max(peak, sample, -sample)
+				sum += sample * sample
 			}
+			rmsAcc += float64(sum)
+		}
 
-			chunk.Peak = peak
-			chunk.RMS = float32(math.Sqrt(rmsAcc / float64(config.CHANNELS*config.FRAMES_PER_BUFFER)))
+		chunk.Peak = peak
+		chunk.RMS = float32(math.Sqrt(rmsAcc / float64(config.CHANNELS*config.FRAMES_PER_BUFFER)))
 
-			// Send chunk to buffer.
-			buffer <- &chunk
+		start := session.position
+		session.position += chunk.Duration()
 
+		if session.end > 0 && session.position >= session.end {
+			// Stop at the trailing silence.
+			chunk.Length = min(chunk.Length, int(int64(session.end-start)*int64(config.SAMPLE_RATE)/int64(time.Second)))
+			eofReached = true
+		}
+
+		chunks := []*player.AudioChunk{chunk}
+		if session.trimmer != nil {
+			chunks = session.trimmer.push(chunk)
 			if eofReached {
-				break
+				chunks = append(chunks, session.trimmer.finish()...)
 			}
 		}
-	}()
 
-	return &clip, nil
+		// Send chunks to buffer.
+		for _, chunk := range chunks {
+			select {
+			case session.buffer <- chunk:
+			case <-session.done:
+				return
+			}
+		}
+
+		if eofReached {
+			break
+		}
+	}
+
+	// Release the decoder (which might still be running if we stopped early).
+	session.close()
+}
+
+// close stops the decoding process. The buffer will be closed by the decoding goroutine.
+func (session *decodingSession) close() {
+	session.closeOnce.Do(func() {
+		close(session.done)
+		if session.decoder != nil {
+			session.decoder.Close()
+		}
+	})
 }
 
 func (clip *AudioClip) NextChunk() (*player.AudioChunk, bool) {
+	clip.Prepare()
+
 	if !clip.started {
 		clip.started = true
 		if clip.OnStart != nil {
 			clip.OnStart(clip.meta)
 		}
 	}
-	chunk, hasMore := <-clip.buffer
-	if !hasMore && !clip.stopped && clip.OnStop != nil {
-		clip.stopped = true
-		clip.OnStop()
 
+	for {
+		clip.mu.Lock()
+		session := clip.session
+		clip.mu.Unlock()
+
+		if session == nil {
+			// The clip has been stopped.
+			return nil, false
+		}
+
+		chunk, hasMore := <-session.buffer
+
+		if !hasMore {
+			clip.mu.Lock()
+			if clip.session != session {
+				// The session has been replaced by a seek in the meantime.
+				clip.mu.Unlock()
+				continue
+			}
+			wasStopped := clip.stopped
+			clip.stopped = true
+			clip.err = session.err
+			clip.mu.Unlock()
+
+			if session.err != nil {
+				log.Printf("Failed to decode '%s': %v", clip.filepath, session.err)
+				if clip.OnError != nil {
+					clip.OnError(session.err)
+				}
+			}
+			if !wasStopped && clip.OnStop != nil {
+				clip.OnStop()
+			}
+		}
+
+		return chunk, hasMore
 	}
-	return chunk, hasMore
 }
 
 func (clip *AudioClip) Stop() {
-	clip.decoder.Close()
-	if !clip.stopped && clip.OnStop != nil {
+	clip.mu.Lock()
+	session := clip.session
+	clip.session = nil
+	wasStopped := clip.stopped
+	// Make sure the decoding process is not started after the clip has been stopped.
+	clip.stopped = true
+	clip.mu.Unlock()
+
+	if session != nil {
+		session.close()
+	}
+	if !wasStopped && clip.OnStop != nil {
 		clip.OnStop()
 	}
-	clip.stopped = true
+}
+
+// Err returns why decoding failed, or nil if the clip played (or is playing) fine.
+func (clip *AudioClip) Err() error {
+	clip.mu.Lock()
+	defer clip.mu.Unlock()
+
+	return clip.err
+}
+
+func (clip *AudioClip) CanSeek() bool {
+	return true
+}
+
+// Seek restarts the decoding process at the given position.
+func (clip *AudioClip) Seek(position time.Duration) error {
+	position = clip.clampPosition(position)
+
+	clip.mu.Lock()
+	if clip.stopped {
+		clip.mu.Unlock()
+		return errors.New("the clip has already been stopped")
+	}
+	oldSession := clip.session
+	clip.offset = position
+	clip.session = nil
+	if oldSession != nil {
+		// Only restart decoding if it was running before.
+		clip.session = clip.startDecodingSession()
+	}
+	clip.mu.Unlock()
+
+	if oldSession != nil {
+		oldSession.close()
+	}
+
+	return nil
+}
+
+// Suspend stops the decoding process without stopping the clip.
+// Decoding is restarted at the given position when the clip is played again.
+func (clip *AudioClip) Suspend(position time.Duration) {
+	position = clip.clampPosition(position)
+
+	clip.mu.Lock()
+	if clip.stopped {
+		clip.mu.Unlock()
+		return
+	}
+	session := clip.session
+	clip.session = nil
+	clip.offset = position
+	clip.mu.Unlock()
+
+	if session != nil {
+		session.close()
+	}
 }
 
 func (clip *AudioClip) Name() string {
@@ -146,8 +358,65 @@ func (clip *AudioClip) Name() string {
 	return player.GetDisplayName(clip.filepath, clip.meta)
 }
 
+// Duration of the audible part of the file.
+// clampPosition limits the position to the clip, unless its duration is unknown.
+func (clip *AudioClip) clampPosition(position time.Duration) time.Duration {
+	position = max(0, position)
+	if duration := clip.Duration(); duration > 0 {
+		position = min(position, duration)
+	}
+	return position
+}
+
 func (clip *AudioClip) Duration() time.Duration {
-	return clip.meta.Duration
+	clip.mu.Lock()
+	silence := clip.silence
+	clip.mu.Unlock()
+
+	end := clip.meta.Duration
+	if silence.End > 0 {
+		end = min(end, silence.End)
+	}
+
+	return max(0, end-silence.Start)
+}
+
+// SetSilenceBoundaries sets previously detected silence, such that it can be skipped right away.
+// It must be called before the clip is played.
+func (clip *AudioClip) SetSilenceBoundaries(boundaries SilenceBoundaries) {
+	clip.mu.Lock()
+	defer clip.mu.Unlock()
+
+	clip.silence = boundaries
+}
+
+func (clip *AudioClip) silenceBoundaries() SilenceBoundaries {
+	clip.mu.Lock()
+	defer clip.mu.Unlock()
+
+	return clip.silence
+}
+
+func (clip *AudioClip) setSilenceStart(start time.Duration) {
+	clip.mu.Lock()
+	clip.silence.Start = start
+	boundaries := clip.silence
+	clip.mu.Unlock()
+
+	if clip.OnSilenceDetected != nil {
+		clip.OnSilenceDetected(boundaries)
+	}
+}
+
+func (clip *AudioClip) setSilenceEnd(end time.Duration) {
+	clip.mu.Lock()
+	clip.silence.End = end
+	boundaries := clip.silence
+	clip.mu.Unlock()
+
+	if clip.OnSilenceDetected != nil {
+		clip.OnSilenceDetected(boundaries)
+	}
 }
 
 func (clip *AudioClip) SetMetaData(title, artist, album string) {
@@ -170,6 +439,12 @@ func (clip *AudioClip) Duplicate() player.Clip {
 		panic(err)
 	}
 
+	newClip.noCrossfade = clip.noCrossfade
+	newClip.OnSilenceDetected = clip.OnSilenceDetected
+	newClip.SetSilenceBoundaries(clip.silenceBoundaries())
+	newClip.staticGain = clip.staticGain
+	newClip.hasStaticGain = clip.hasStaticGain
+
 	return newClip
 }
 
@@ -177,14 +452,30 @@ func (clip *AudioClip) Hidden() bool {
 	return false
 }
 
-func fileExists(filename string) bool {
-	_, err := os.Stat(filename)
-	return !errors.Is(err, os.ErrNotExist)
+func (clip *AudioClip) AllowsCrossfade() bool {
+	return !clip.noCrossfade
+}
+
+// DisableCrossfade makes sure the clip is never overlapped with its neighbors.
+func (clip *AudioClip) DisableCrossfade() {
+	clip.noCrossfade = true
 }
 
-func absf32(x float32) float32 {
-	if x < 0 {
-		return -x
+// SetStaticGain sets a fixed gain that is applied instead of the dynamic normalization.
+func (clip *AudioClip) SetStaticGain(gain float32) {
+	clip.staticGain = gain
+	clip.hasStaticGain = true
+}
+
+// StaticGain prefers ReplayGain tags (if enabled) over the gain set by SetStaticGain.
+func (clip *AudioClip) StaticGain() (float32, bool) {
+	if gain, ok := player.ReplayGain(clip.meta.ReplayGain); ok {
+		return gain, true
 	}
-	return x
+	return clip.staticGain, clip.hasStaticGain
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return !errors.Is(err, os.ErrNotExist)
 }
@@ -0,0 +1,69 @@
+package clips
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	d "github.com/tim-we/wavestreamer/player/decoder"
+)
+
+func TestDecodingErrorsEndTheClip(t *testing.T) {
+	// A FLAC file with a valid header but no valid frames.
+	var streamInfo [34]byte
+	binary.BigEndian.PutUint64(streamInfo[10:18], 44100<<44|1<<41|15<<36|44100)
+	data := append([]byte("fLaC\x80\x00\x00\x22"), streamInfo[:]...)
+	data = append(data, make([]byte, 64)...)
+
+	path := filepath.Join(t.TempDir(), "broken.flac")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	clip, err := NewAudioClip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reported error
+	clip.OnError = func(err error) { reported = err }
+
+	for {
+		if _, hasMore := clip.NextChunk(); !hasMore {
+			break
+		}
+	}
+
+	if clip.Err() == nil || reported != clip.Err() {
+		t.Errorf("Expected the decoding error to be reported, got %v and %v", clip.Err(), reported)
+	}
+}
+
+func TestPositionsAreKeptWithUnknownDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.mp3")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	clip, err := NewAudioClipWithMetaData(path, &d.AudioFileMetaData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clip.Suspend(time.Minute)
+	if clip.offset != time.Minute {
+		t.Errorf("Expected to resume at %v, got %v", time.Minute, clip.offset)
+	}
+
+	if err := clip.Seek(2 * time.Minute); err != nil || clip.offset != 2*time.Minute {
+		t.Errorf("Expected to seek to %v, got %v (%v)", 2*time.Minute, clip.offset, err)
+	}
+
+	clip.meta.Duration = 90 * time.Second
+	clip.Suspend(time.Hour)
+	if clip.offset != 90*time.Second {
+		t.Errorf("Expected the position to be clamped to the duration, got %v", clip.offset)
+	}
+}
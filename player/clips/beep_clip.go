@@ -9,7 +9,9 @@ import (
 )
 
 // Beep should be a quarter of a second long
-const NUMBER_OF_CHUNKS = config.SAMPLE_RATE / 4 / config.FRAMES_PER_BUFFER
+func numberOfBeepChunks() int {
+	return max(1, config.SAMPLE_RATE/4/config.FRAMES_PER_BUFFER)
+}
 
 type BeepClip struct {
 	buffer chan *player.AudioChunk
@@ -20,18 +22,16 @@ type BeepClip struct {
 var beepChunk *player.AudioChunk
 
 func NewBeep() *BeepClip {
-	buffer := make(chan *player.AudioChunk, NUMBER_OF_CHUNKS)
+	chunks := numberOfBeepChunks()
+	buffer := make(chan *player.AudioChunk, chunks)
 	defer close(buffer)
 
 	if beepChunk == nil {
-		beepChunk = &player.AudioChunk{
-			Left:  make([]float32, config.FRAMES_PER_BUFFER),
-			Right: make([]float32, config.FRAMES_PER_BUFFER),
-		}
+		beepChunk = player.NewAudioChunk()
 		generateWave(beepChunk)
 	}
 
-	for range NUMBER_OF_CHUNKS {
+	for range chunks {
 		buffer <- beepChunk
 	}
 
@@ -48,14 +48,21 @@ func (clip *BeepClip) Stop() {
 }
 
 func (clip *BeepClip) Duration() time.Duration {
-	durationInSeconds := (NUMBER_OF_CHUNKS * config.FRAMES_PER_BUFFER) / config.SAMPLE_RATE
-	return time.Duration(durationInSeconds) * time.Second
+	return time.Duration(numberOfBeepChunks()) * config.BufferDuration()
 }
 
 func (clip *BeepClip) Hidden() bool {
 	return true
 }
 
+func (clip *BeepClip) CanSeek() bool {
+	return false
+}
+
+func (clip *BeepClip) Seek(position time.Duration) error {
+	return player.ErrSeekNotSupported
+}
+
 func (clip *BeepClip) Duplicate() player.Clip {
 	return NewBeep()
 }
@@ -84,7 +91,8 @@ func generateWave(chunk *player.AudioChunk) {
 
 		v = beepVolume * v
 
-		chunk.Left[i] = v
-		chunk.Right[i] = v
+		for _, samples := range chunk.Channels {
+			samples[i] = v
+		}
 	}
 }
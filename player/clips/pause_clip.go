@@ -2,6 +2,7 @@ package clips
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/tim-we/wavestreamer/config"
@@ -15,13 +16,9 @@ type PauseClip struct {
 	manuallyStopped bool
 }
 
-var emptyChunk = player.AudioChunk{
-	Left:   make([]float32, config.FRAMES_PER_BUFFER),
-	Right:  make([]float32, config.FRAMES_PER_BUFFER),
-	Length: config.FRAMES_PER_BUFFER,
-}
-
-const emptyChunkDuration = (config.FRAMES_PER_BUFFER * time.Second) / config.SAMPLE_RATE
+// All pause clips share the same (silent) chunk. It is created on first use,
+// after the audio format has been configured.
+var emptyChunk = sync.OnceValue(player.NewAudioChunk)
 
 // NewPause creates a new Pause clip with the given duration.
 // Pass 0 to create an indefinite pause clip.
@@ -36,7 +33,7 @@ func NewPause(duration time.Duration) *PauseClip {
 }
 
 func (clip *PauseClip) NextChunk() (*player.AudioChunk, bool) {
-	clip.progress += emptyChunkDuration
+	clip.progress += config.BufferDuration()
 	hasMore := clip.progress < clip.duration
 	if clip.duration == 0 {
 		// Indefinite clip
@@ -45,7 +42,7 @@ func (clip *PauseClip) NextChunk() (*player.AudioChunk, bool) {
 	if clip.manuallyStopped {
 		hasMore = false
 	}
-	return &emptyChunk, hasMore
+	return emptyChunk(), hasMore
 }
 
 func (clip *PauseClip) Stop() {
@@ -79,6 +76,14 @@ func (clip *PauseClip) Hidden() bool {
 	return clip.hidden
 }
 
+func (clip *PauseClip) CanSeek() bool {
+	return false
+}
+
+func (clip *PauseClip) Seek(position time.Duration) error {
+	return player.ErrSeekNotSupported
+}
+
 func formatDuration(d time.Duration) string {
 	minutes := int(d.Minutes())
 	seconds := int(d.Seconds()) % 60
@@ -0,0 +1,145 @@
+package clips
+
+import (
+	"math"
+	"time"
+
+	"github.com/tim-we/wavestreamer/player"
+)
+
+// SilenceBoundaries describe the audible part of a file.
+type SilenceBoundaries struct {
+	// End of the leading silence.
+	Start time.Duration
+
+	// Start of the trailing silence (0 = unknown).
+	End time.Duration
+}
+
+// Silence trimming is disabled unless configured with SetSilenceTrimming.
+var (
+	trimSilence      bool
+	silenceThreshold float32
+	silenceMinLength time.Duration
+)
+
+// Silent chunks are held back until we know whether they are trailing silence.
+// Longer silences (e.g. before a hidden track) are played.
+const maxHeldSilence = 30 * time.Second
+
+// SetSilenceTrimming enables skipping leading and trailing silence of audio clips. Chunks with a peak
+// below the threshold (in dBFS) are considered silent. Shorter silences than minLength are kept.
+func SetSilenceTrimming(thresholdDb float64, minLength time.Duration) {
+	trimSilence = true
+	silenceThreshold = float32(math.Pow(10, thresholdDb/20))
+	silenceMinLength = minLength
+}
+
+// silenceTrimmer detects leading and trailing silence while a file is being decoded.
+// It drops silent chunks at the beginning and at the end of the file.
+type silenceTrimmer struct {
+	// Whether we are still at the beginning of the file (no audible chunk so far).
+	leading bool
+
+	// Whether trailing silence should be detected.
+	trailing bool
+
+	// File position of the next chunk.
+	position time.Duration
+
+	// Silent chunks that have been held back and the file position of the first one.
+	held      []*player.AudioChunk
+	heldStart time.Duration
+	heldTotal time.Duration
+
+	// Called with the (file) positions of the detected boundaries.
+	onStart func(start time.Duration)
+	onEnd   func(end time.Duration)
+}
+
+// push processes the next decoded chunk and returns the chunks that should be played now.
+func (trimmer *silenceTrimmer) push(chunk *player.AudioChunk) []*player.AudioChunk {
+	start := trimmer.position
+	trimmer.position += chunk.Duration()
+
+	if chunk.Peak < silenceThreshold {
+		if !trimmer.leading && !trimmer.trailing {
+			return []*player.AudioChunk{chunk}
+		}
+
+		if trimmer.heldTotal == 0 {
+			trimmer.heldStart = start
+		}
+		trimmer.held = append(trimmer.held, chunk)
+		trimmer.heldTotal += chunk.Duration()
+
+		if trimmer.leading && trimmer.heldTotal >= silenceMinLength {
+			// This will be skipped anyway, no need to keep it.
+			releaseAll(trimmer.held)
+			trimmer.held = nil
+		}
+
+		if !trimmer.leading && trimmer.heldTotal > maxHeldSilence {
+			// Too long to hold back, this is probably not the end.
+			return trimmer.release()
+		}
+		return nil
+	}
+
+	// The chunk is audible.
+	if trimmer.leading {
+		trimmer.leading = false
+
+		if trimmer.heldTotal >= silenceMinLength {
+			// Skip the leading silence.
+			trimmer.discard()
+			if trimmer.onStart != nil {
+				trimmer.onStart(start)
+			}
+		}
+	}
+
+	return append(trimmer.release(), chunk)
+}
+
+// finish is called at the end of the file and returns the remaining chunks that should be played.
+func (trimmer *silenceTrimmer) finish() []*player.AudioChunk {
+	if !trimmer.trailing {
+		return trimmer.release()
+	}
+
+	end := trimmer.position
+	var remaining []*player.AudioChunk
+
+	if trimmer.heldTotal >= silenceMinLength && !trimmer.leading {
+		end = trimmer.heldStart
+		trimmer.discard()
+	} else {
+		remaining = trimmer.release()
+	}
+
+	if trimmer.onEnd != nil {
+		trimmer.onEnd(end)
+	}
+
+	return remaining
+}
+
+func (trimmer *silenceTrimmer) release() []*player.AudioChunk {
+	held := trimmer.held
+	trimmer.held = nil
+	trimmer.heldTotal = 0
+	return held
+}
+
+func (trimmer *silenceTrimmer) discard() {
+	releaseAll(trimmer.held)
+	trimmer.held = nil
+	trimmer.heldTotal = 0
+}
+
+func releaseAll(chunks []*player.AudioChunk) {
+	for _, chunk := range chunks {
+		chunk.Release()
+	}
+}
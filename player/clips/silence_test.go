@@ -0,0 +1,54 @@
+package clips
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player"
+)
+
+func TestSilenceTrimmerSkipsLeadingAndTrailingSilence(t *testing.T) {
+	defer func(enabled bool, threshold float32, minLength time.Duration) {
+		trimSilence, silenceThreshold, silenceMinLength = enabled, threshold, minLength
+	}(trimSilence, silenceThreshold, silenceMinLength)
+
+	SetSilenceTrimming(-50, time.Second)
+
+	var start, end time.Duration
+	trimmer := &silenceTrimmer{
+		leading:  true,
+		trailing: true,
+		onStart:  func(position time.Duration) { start = position },
+		onEnd:    func(position time.Duration) { end = position },
+	}
+
+	// 2s silence, 3s audio, 0.5s silence, 1s audio, 2s silence
+	var played []*player.AudioChunk
+	for _, part := range []struct {
+		peak    float32
+		seconds float64
+	}{{0, 2}, {0.5, 3}, {0, 0.5}, {0.5, 1}, {0.001, 2}} {
+		for range chunksFor(part.seconds) {
+			chunk := player.NewAudioChunk()
+			chunk.Peak = part.peak
+			played = append(played, trimmer.push(chunk)...)
+		}
+	}
+	played = append(played, trimmer.finish()...)
+
+	chunk := config.BufferDuration()
+	if expected := time.Duration(chunksFor(2)) * chunk; start != expected {
+		t.Errorf("Expected the leading silence to end at %v, got %v", expected, start)
+	}
+	if expected := time.Duration(chunksFor(2)+chunksFor(4.5)) * chunk; end != expected {
+		t.Errorf("Expected the trailing silence to start at %v, got %v", expected, end)
+	}
+	if expected := chunksFor(4.5); len(played) != expected {
+		t.Errorf("Expected %d chunks to be played, got %d", expected, len(played))
+	}
+}
+
+func chunksFor(seconds float64) int {
+	return int(seconds * float64(config.SAMPLE_RATE) / float64(config.FRAMES_PER_BUFFER))
+}
@@ -53,13 +53,19 @@ var dialFrequencies = DTMFFrequencies{350, 440}
 const VOLUME = 0.25
 
 // Roughly a third of a second
-const BEEP_DURATION_IN_CHUNKS = max(1, (config.SAMPLE_RATE/3)/config.FRAMES_PER_BUFFER-1)
+func beepDurationInChunks() int {
+	return max(1, (config.SAMPLE_RATE/3)/config.FRAMES_PER_BUFFER-1)
+}
 
 // Roughly 1.5 seconds
-const DIAL_DURATION_IN_CHUNKS = max(1, (3*config.SAMPLE_RATE/2)/config.FRAMES_PER_BUFFER)
+func dialDurationInChunks() int {
+	return max(1, (3*config.SAMPLE_RATE/2)/config.FRAMES_PER_BUFFER)
+}
 
 // Roughly half a second. Pause between beeps and dial sound.
-const PAUSE_DURATION_IN_CHUNKS = max(1, (config.SAMPLE_RATE/2)/config.FRAMES_PER_BUFFER)
+func pauseDurationInChunks() int {
+	return max(1, (config.SAMPLE_RATE/2)/config.FRAMES_PER_BUFFER)
+}
 
 func NewTelephoneDialClip() *TelephoneDialClip {
 	// Pick a random telephone number
@@ -71,7 +77,11 @@ func NewTelephoneDialClip() *TelephoneDialClip {
 func newTelephoneDialClip(telNumber string) *TelephoneDialClip {
 	buffer := make(chan *player.AudioChunk, 16)
 
-	durationInChunks := len(telNumber)*BEEP_DURATION_IN_CHUNKS + PAUSE_DURATION_IN_CHUNKS + DIAL_DURATION_IN_CHUNKS
+	beepChunks := beepDurationInChunks()
+	pauseChunks := pauseDurationInChunks()
+	dialChunks := dialDurationInChunks()
+
+	durationInChunks := len(telNumber)*beepChunks + pauseChunks + dialChunks
 	durationInSeconds := (durationInChunks * config.FRAMES_PER_BUFFER) / config.SAMPLE_RATE
 
 	go func() {
@@ -80,8 +90,7 @@ func newTelephoneDialClip(telNumber string) *TelephoneDialClip {
 		for _, ch := range telNumber {
 			if ch == ' ' || ch == '-' || ch == '/' {
 				for range 3 {
-					chunk := silence()
-					buffer <- &chunk
+					buffer <- player.NewAudioChunk()
 				}
 			}
 
@@ -90,23 +99,21 @@ func newTelephoneDialClip(telNumber string) *TelephoneDialClip {
 				continue
 			}
 
-			for i := range BEEP_DURATION_IN_CHUNKS {
-				chunk := silence()
-				fillChunkWithFrequencies(&chunk, frequencies, i*config.FRAMES_PER_BUFFER, i == BEEP_DURATION_IN_CHUNKS-1)
-				buffer <- &chunk
+			for i := range beepChunks {
+				chunk := player.NewAudioChunk()
+				fillChunkWithFrequencies(chunk, frequencies, i*config.FRAMES_PER_BUFFER, i == beepChunks-1)
+				buffer <- chunk
 			}
 		}
 
-		for range PAUSE_DURATION_IN_CHUNKS {
-			chunk := silence()
-			buffer <- &chunk
+		for range pauseChunks {
+			buffer <- player.NewAudioChunk()
 		}
 
-		for i := range DIAL_DURATION_IN_CHUNKS {
-			chunk := silence()
-
-			fillChunkWithFrequencies(&chunk, dialFrequencies, i*config.FRAMES_PER_BUFFER, i == DIAL_DURATION_IN_CHUNKS-1)
-			buffer <- &chunk
+		for i := range dialChunks {
+			chunk := player.NewAudioChunk()
+			fillChunkWithFrequencies(chunk, dialFrequencies, i*config.FRAMES_PER_BUFFER, i == dialChunks-1)
+			buffer <- chunk
 		}
 	}()
 
@@ -141,25 +148,27 @@ func (clip *TelephoneDialClip) Hidden() bool {
 	return true
 }
 
+func (clip *TelephoneDialClip) CanSeek() bool {
+	return false
+}
+
+func (clip *TelephoneDialClip) Seek(position time.Duration) error {
+	return player.ErrSeekNotSupported
+}
+
 func fillChunkWithFrequencies(chunk *player.AudioChunk, pair DTMFFrequencies, timeOffset int, fadeOut bool) {
 	freqA := float64(pair.Lower)
 	freqB := float64(pair.Higher)
 	for i := range config.FRAMES_PER_BUFFER {
-		t := 2.0 * math.Pi * float64(timeOffset+i) / config.SAMPLE_RATE
+		t := 2.0 * math.Pi * float64(timeOffset+i) / float64(config.SAMPLE_RATE)
 		value := float32(VOLUME * (math.Sin(t*freqA) + math.Sin(t*freqB)))
 		if fadeOut {
-			value = value * (float32(config.FRAMES_PER_BUFFER-i) / config.FRAMES_PER_BUFFER)
+			value = value * (float32(config.FRAMES_PER_BUFFER-i) / float32(config.FRAMES_PER_BUFFER))
+		}
+		for _, samples := range chunk.Channels {
+			samples[i] = value
 		}
-		chunk.Left[i] = value
-		chunk.Right[i] = value
 	}
 	chunk.Peak = 2 * VOLUME
 	chunk.RMS = 0.707106781 * VOLUME // 1/sqrt(2)
 }
-
-func silence() player.AudioChunk {
-	return player.AudioChunk{
-		Left:  make([]float32, config.FRAMES_PER_BUFFER),
-		Right: make([]float32, config.FRAMES_PER_BUFFER),
-	}
-}
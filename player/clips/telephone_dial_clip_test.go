@@ -0,0 +1,36 @@
+package clips
+
+import (
+	"testing"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+func TestTelephoneDialUsesConfiguredFormat(t *testing.T) {
+	sampleRate, channels, frames := config.SAMPLE_RATE, config.CHANNELS, config.FRAMES_PER_BUFFER
+	defer config.SetAudioFormat(sampleRate, channels, frames)
+
+	if err := config.SetAudioFormat(48000, 1, 512); err != nil {
+		t.Fatal(err)
+	}
+
+	clip := newTelephoneDialClip("1")
+	chunks := 0
+
+	for {
+		chunk, hasMore := clip.NextChunk()
+		if !hasMore {
+			break
+		}
+		chunks++
+
+		if len(chunk.Channels) != 1 || len(chunk.Channels[0]) != 512 || chunk.Length != 512 {
+			t.Fatalf("Expected a mono chunk of 512 frames, got %d channels of %d frames", len(chunk.Channels), chunk.Length)
+		}
+	}
+
+	// One beep (~1/3s), the pause (~1/2s) and the dial tone (~1.5s) at 48 kHz.
+	if expected := 30 + 46 + 140; chunks != expected {
+		t.Errorf("Expected %d chunks, got %d", expected, chunks)
+	}
+}
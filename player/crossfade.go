@@ -0,0 +1,45 @@
+package player
+
+import (
+	"math"
+	"time"
+
+	"github.com/tim-we/wavestreamer/utils"
+)
+
+// CrossfadeClip is implemented by clips that support overlapping with their neighbors.
+// Clips that do not implement this interface are never crossfaded.
+type CrossfadeClip interface {
+	Clip
+
+	// Whether the clip may be crossfaded with the previous and next clip.
+	AllowsCrossfade() bool
+}
+
+var crossfadeDuration time.Duration
+
+// SetCrossfade sets the overlap between two consecutive clips. Use 0 to disable crossfades.
+// It must be called before Start.
+func SetCrossfade(duration time.Duration) {
+	crossfadeDuration = max(0, duration)
+}
+
+func canCrossfade(clip Clip) bool {
+	if clip == nil || clip.Hidden() {
+		return false
+	}
+	crossfadeClip, ok := clip.(CrossfadeClip)
+	return ok && crossfadeClip.AllowsCrossfade()
+}
+
+// fadeInGain returns the gain at the given position of a fade in (equal power).
+func fadeInGain(position, length time.Duration) float32 {
+	t := utils.Clamp(0, float64(position)/float64(length), 1)
+	return float32(math.Sin(t * math.Pi / 2))
+}
+
+// fadeOutGain returns the gain at the given position of a fade out (equal power).
+func fadeOutGain(position, length time.Duration) float32 {
+	t := utils.Clamp(0, float64(position)/float64(length), 1)
+	return float32(math.Cos(t * math.Pi / 2))
+}
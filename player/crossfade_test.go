@@ -0,0 +1,65 @@
+package player
+
+import (
+	"testing"
+)
+
+func TestCrossfadeOverlapsClips(t *testing.T) {
+	chunks := runLoopWithCrossfade(&fadeClip{chunkClip{chunks: 10, value: 0.5}}, &fadeClip{chunkClip{chunks: 10, value: 0.5}})
+
+	if len(chunks) != 16 {
+		t.Fatalf("Expected 16 chunks (4 chunks overlap), got %d", len(chunks))
+	}
+
+	if chunks[0].Channels[0][0] != 0.5 || chunks[15].Channels[0][0] != 0.5 {
+		t.Errorf("Clips should not be faded outside of the crossfade")
+	}
+
+	// Within the crossfade both clips are audible.
+	middle := chunks[8].Channels[0][0]
+	if middle <= 0.5 || middle > 0.75 {
+		t.Errorf("Unexpected value %v in the middle of the crossfade", middle)
+	}
+}
+
+func TestCrossfadeExcludesOtherClips(t *testing.T) {
+	chunks := runLoopWithCrossfade(&fadeClip{chunkClip{chunks: 10, value: 0.5}}, &chunkClip{chunks: 10, value: 0.5})
+
+	if len(chunks) != 20 {
+		t.Fatalf("Expected 20 chunks (no overlap), got %d", len(chunks))
+	}
+}
+
+func runLoopWithCrossfade(clips ...Clip) []*AudioChunk {
+	provider := make(chan Clip, len(clips))
+	for _, clip := range clips {
+		provider <- clip
+	}
+	close(provider)
+
+	loop := NewPlaybackLoop("Test", false, func() Clip { return <-provider })
+	loop.crossfade = 4 * chunkDuration()
+
+	done := make(chan struct{})
+	go func() {
+		loop.Run()
+		close(done)
+	}()
+
+	var received []*AudioChunk
+	for {
+		select {
+		case chunk := <-loop.NextAudioChunk:
+			received = append(received, chunk)
+		case <-done:
+			return received
+		}
+	}
+}
+
+// fadeClip is a chunkClip that allows crossfades.
+type fadeClip struct {
+	chunkClip
+}
+
+func (clip *fadeClip) AllowsCrossfade() bool { return true }
@@ -0,0 +1,96 @@
+package decoder
+
+import (
+	"io"
+	"math/bits"
+)
+
+// bitReader reads big endian bit fields (MSB first) as used by FLAC.
+type bitReader struct {
+	reader io.ByteReader
+	// Unread bits are the n most significant bits of cache.
+	cache uint64
+	n     uint
+}
+
+func (br *bitReader) reset(reader io.ByteReader) {
+	br.reader = reader
+	br.cache = 0
+	br.n = 0
+}
+
+// fill ensures that at least count bits (up to 57) are in the cache.
+func (br *bitReader) fill(count uint) error {
+	for br.n < count {
+		b, err := br.reader.ReadByte()
+		if err != nil {
+			if err == io.EOF && br.n > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		br.cache |= uint64(b) << (56 - br.n)
+		br.n += 8
+	}
+	return nil
+}
+
+// read returns the next count bits (up to 57) as an unsigned number.
+func (br *bitReader) read(count uint) (uint64, error) {
+	if count == 0 {
+		return 0, nil
+	}
+	if err := br.fill(count); err != nil {
+		return 0, err
+	}
+
+	value := br.cache >> (64 - count)
+	br.cache <<= count
+	br.n -= count
+
+	return value, nil
+}
+
+// readSigned returns the next count bits as a two's complement number.
+func (br *bitReader) readSigned(count uint) (int64, error) {
+	value, err := br.read(count)
+	if err != nil || count == 0 {
+		return 0, err
+	}
+
+	// Sign extend.
+	shift := 64 - count
+	return int64(value<<shift) >> shift, nil
+}
+
+// readUnary counts the zero bits before the next one bit (which is consumed).
+func (br *bitReader) readUnary() (uint64, error) {
+	var count uint64
+
+	for {
+		if br.n == 0 {
+			if err := br.fill(8); err != nil {
+				return 0, err
+			}
+		}
+
+		zeros := uint(bits.LeadingZeros64(br.cache))
+		if zeros < br.n {
+			br.cache <<= zeros + 1
+			br.n -= zeros + 1
+			return count + uint64(zeros), nil
+		}
+
+		// All cached bits are zero.
+		count += uint64(br.n)
+		br.cache = 0
+		br.n = 0
+	}
+}
+
+// align discards the bits up to the next byte boundary.
+func (br *bitReader) align() {
+	skip := br.n % 8
+	br.cache <<= skip
+	br.n -= skip
+}
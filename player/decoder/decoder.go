@@ -0,0 +1,242 @@
+package decoder
+
+import (
+	"errors"
+	"io"
+	"log"
+	fp "path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+// Decoder produces PCM audio in the configured output format (config.SAMPLE_RATE, config.CHANNELS).
+type Decoder interface {
+	// ReadChunk reads up to len(channels[0]) frames into the channel buffers (one buffer per
+	// output channel) and returns the number of frames read. At the end of the file io.EOF is
+	// returned along with the remaining frames.
+	ReadChunk(channels [][]float32) (int, error)
+
+	// Close stops decoding and releases all resources. It may be called while ReadChunk is
+	// running in another goroutine, which will then fail.
+	Close()
+}
+
+// errUnsupported is returned by native decoders for files they cannot handle (e.g. compressed WAV files).
+var errUnsupported = errors.New("unsupported format")
+
+// Native decoders by file extension. All other files are decoded by ffmpeg.
+var nativeDecoders = map[string]func(filepath string) (source, error){
+	".wav":  openWav,
+	".flac": openFlac,
+}
+
+// EnableNativeMP3 decodes MP3 files without ffmpeg (and reads their tags without ffprobe).
+// It has to be called before any file is opened.
+func EnableNativeMP3() {
+	nativeDecoders[".mp3"] = openMp3
+	nativeMetadata[".mp3"] = mp3Metadata
+}
+
+// NewDecoder starts decoding the file at the given offset. Native decoders are used for
+// common formats, with ffmpeg as a fallback for everything else.
+func NewDecoder(filepath string, offset time.Duration) (Decoder, error) {
+	if open, ok := nativeDecoders[strings.ToLower(fp.Ext(filepath))]; ok {
+		src, err := open(filepath)
+		if err == nil {
+			return newNativeDecoder(src, offset), nil
+		}
+		if !errors.Is(err, errUnsupported) {
+			log.Printf("Native decoding of '%s' failed, falling back to ffmpeg: %v", filepath, err)
+		}
+	}
+
+	process := NewDecodingProcess(filepath, offset)
+	if err := process.StartDecoding(); err != nil {
+		return nil, err
+	}
+	return process, nil
+}
+
+// source reads the frames of a file in its native format.
+type source interface {
+	// format returns the sample rate, the number of channels and the number of frames (0 = unknown).
+	format() (sampleRate, channels int, frames int64)
+
+	// readFrames reads up to len(buffers[0]) frames (one buffer per source channel).
+	// It returns io.EOF at the end of the file, possibly along with the remaining frames.
+	readFrames(buffers [][]float32) (int, error)
+
+	// seek moves to the given frame. The position might be before the target (e.g. at
+	// the start of a compressed block), it returns the frame it actually moved to.
+	seek(frame int64) (int64, error)
+
+	close() error
+}
+
+// nativeDecoder converts the frames of a source to the output format. Resampling uses
+// linear interpolation, which is cheap and good enough for common rates like 48 kHz to 44.1 kHz.
+type nativeDecoder struct {
+	src            source
+	sourceChannels int
+
+	// Source frames, the first frame is at the (fractional) position 0.
+	input     [][]float32
+	views     [][]float32 // free space of the input buffers (reused)
+	available int
+	position  float64
+	step      float64
+
+	// Source frames that still have to be skipped (seeking).
+	skip int64
+
+	// Offset of the first read, the seek is deferred to the decoding goroutine.
+	offset time.Duration
+	eof    bool
+}
+
+func newNativeDecoder(src source, offset time.Duration) *nativeDecoder {
+	rate, channels, _ := src.format()
+
+	input := make([][]float32, channels)
+	for ch := range input {
+		input[ch] = make([]float32, 4096)
+	}
+
+	return &nativeDecoder{
+		src:            src,
+		sourceChannels: channels,
+		input:          input,
+		views:          make([][]float32, channels),
+		step:           float64(rate) / float64(config.SAMPLE_RATE),
+		offset:         offset,
+	}
+}
+
+func (d *nativeDecoder) ReadChunk(channels [][]float32) (int, error) {
+	if d.offset > 0 {
+		if err := d.seek(d.offset); err != nil {
+			return 0, err
+		}
+		d.offset = 0
+	}
+
+	frames := 0
+
+	for frames < len(channels[0]) {
+		i := int(d.position)
+
+		if i+1 >= d.available && !d.eof {
+			if err := d.fill(); err != nil {
+				return frames, err
+			}
+			continue
+		}
+
+		if i >= d.available {
+			// All frames have been consumed.
+			return frames, io.EOF
+		}
+
+		// Interpolate between frame i and the next frame (if there is one).
+		frac := float32(d.position - float64(i))
+		next := min(i+1, d.available-1)
+
+		for ch, samples := range channels {
+			samples[frames] = d.sample(ch, i, next, frac, len(channels))
+		}
+
+		frames++
+		d.position += d.step
+	}
+
+	return frames, nil
+}
+
+// sample returns the interpolated sample of the given output channel.
+func (d *nativeDecoder) sample(outputChannel, i, next int, frac float32, outputChannels int) float32 {
+	at := func(ch int) float32 {
+		a, b := d.input[ch][i], d.input[ch][next]
+		return a + (b-a)*frac
+	}
+
+	switch {
+	case d.sourceChannels == outputChannels:
+		return at(outputChannel)
+	case outputChannels == 1:
+		// Mix down to mono.
+		var sum float32
+		for ch := range d.sourceChannels {
+			sum += at(ch)
+		}
+		return sum / float32(d.sourceChannels)
+	case d.sourceChannels == 1:
+		return at(0)
+	default:
+		// Additional channels (e.g. surround) are dropped.
+		return at(min(outputChannel, d.sourceChannels-1))
+	}
+}
+
+// fill moves the remaining frames to the front and reads more frames from the source.
+func (d *nativeDecoder) fill() error {
+	consumed := min(int(d.position), d.available)
+	for ch := range d.input {
+		copy(d.input[ch], d.input[ch][consumed:d.available])
+	}
+	d.available -= consumed
+	d.position -= float64(consumed)
+
+	for ch := range d.input {
+		d.views[ch] = d.input[ch][d.available:]
+	}
+
+	n, err := d.readSkipping(d.views)
+	d.available += n
+
+	if err == io.EOF {
+		d.eof = true
+		return nil
+	}
+	return err
+}
+
+// readSkipping reads frames from the source, discarding the frames that have to be skipped.
+func (d *nativeDecoder) readSkipping(buffers [][]float32) (int, error) {
+	for {
+		n, err := d.src.readFrames(buffers)
+
+		if d.skip > 0 {
+			skipped := int(min(int64(n), d.skip))
+			d.skip -= int64(skipped)
+			for ch := range buffers {
+				copy(buffers[ch], buffers[ch][skipped:n])
+			}
+			n -= skipped
+		}
+
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (d *nativeDecoder) seek(offset time.Duration) error {
+	rate, _, _ := d.src.format()
+	target := int64(offset.Seconds() * float64(rate))
+
+	position, err := d.src.seek(target)
+	if err != nil {
+		return err
+	}
+
+	d.skip = target - position
+	return nil
+}
+
+func (d *nativeDecoder) Close() {
+	if err := d.src.close(); err != nil {
+		log.Printf("Failed to close decoder: %v", err)
+	}
+}
@@ -0,0 +1,731 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+func TestWavIsResampledToOutputFormat(t *testing.T) {
+	sampleRate, channels, frames := config.SAMPLE_RATE, config.CHANNELS, config.FRAMES_PER_BUFFER
+	defer config.SetAudioFormat(sampleRate, channels, frames)
+	if err := config.SetAudioFormat(44100, 2, 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	// One second of a mono ramp at 22050 Hz.
+	samples := make([]int16, 22050)
+	for i := range samples {
+		samples[i] = int16(i)
+	}
+	path := writeWav(t, 22050, 1, samples)
+
+	meta, err := GetFileMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Duration != time.Second || meta.Title != "Ramp" {
+		t.Errorf("Unexpected meta data %+v", meta)
+	}
+
+	decoder, err := NewDecoder(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	output := readAll(t, decoder)
+
+	if len(output[0]) < 44090 || len(output[0]) > 44100 {
+		t.Errorf("Expected about 44100 frames, got %d", len(output[0]))
+	}
+	for _, i := range []int{0, 1, 2, 1001, 40000} {
+		// Every second output frame is halfway between two input frames.
+		expected := float32(i) / 2 / 32768
+		if math.Abs(float64(output[0][i]-expected)) > 1e-6 || output[1][i] != output[0][i] {
+			t.Errorf("Expected frame %d to be %f on both channels, got %f and %f", i, expected, output[0][i], output[1][i])
+		}
+	}
+}
+
+func TestWavSeek(t *testing.T) {
+	sampleRate, channels, frames := config.SAMPLE_RATE, config.CHANNELS, config.FRAMES_PER_BUFFER
+	defer config.SetAudioFormat(sampleRate, channels, frames)
+	if err := config.SetAudioFormat(8000, 1, 256); err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]int16, 16000)
+	for i := range samples {
+		samples[i] = int16(i)
+	}
+	path := writeWav(t, 8000, 1, samples)
+
+	decoder, err := NewDecoder(path, 1500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	output := readAll(t, decoder)
+
+	if len(output[0]) != 4000 || output[0][0] != 12000.0/32768 {
+		t.Errorf("Expected 4000 frames starting at sample 12000, got %d frames starting at %f", len(output[0]), output[0][0]*32768)
+	}
+}
+
+func TestFlacDecoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.flac")
+	left, right := testFlacStream(t, path)
+
+	src, err := openFlac(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.close()
+
+	if rate, channels, frames := src.format(); rate != 44100 || channels != 2 || frames != int64(len(left)) {
+		t.Fatalf("Unexpected format: %d Hz, %d channels, %d frames", rate, channels, frames)
+	}
+
+	buffers := [][]float32{make([]float32, 100), make([]float32, 100)}
+	n, err := src.readFrames(buffers)
+	if err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+	if n != len(left) {
+		t.Fatalf("Expected %d frames, got %d", len(left), n)
+	}
+
+	for i := range n {
+		if buffers[0][i] != float32(left[i])/32768 || buffers[1][i] != float32(right[i])/32768 {
+			t.Errorf("Frame %d: expected (%d, %d), got (%.0f, %.0f)", i, left[i], right[i], buffers[0][i]*32768, buffers[1][i]*32768)
+		}
+	}
+
+	meta, err := flacMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Artist != "Tester" || meta.ReplayGain == nil || meta.ReplayGain.TrackGain != -3 {
+		t.Errorf("Unexpected meta data %+v", meta)
+	}
+}
+
+func TestWavReplayGainFromID3Chunk(t *testing.T) {
+	path := writeWav(t, 8000, 1, make([]int16, 8000))
+
+	// ID3v2.3 tag with a UTF-16 title and ReplayGain in TXXX frames, appended after the data.
+	title := []byte{1, 0xFF, 0xFE, 'S', 0, 0xFC, 0, 'd', 0}
+	tag := id3v23Tag(
+		id3Frame("TIT2", title),
+		id3Frame("TXXX", []byte("\x00REPLAYGAIN_TRACK_GAIN\x00-6.50 dB")),
+		id3Frame("TXXX", []byte("\x00replaygain_track_peak\x000.5")),
+	)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("id3 ")
+	binary.Write(file, binary.LittleEndian, uint32(len(tag)))
+	file.Write(tag)
+	if len(tag)%2 != 0 {
+		file.Write([]byte{0})
+	}
+	file.Close()
+
+	meta, err := GetFileMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The title of the INFO list takes precedence.
+	if meta.Title != "Ramp" || meta.Duration != time.Second {
+		t.Errorf("Unexpected meta data %+v", meta)
+	}
+	if meta.ReplayGain == nil || meta.ReplayGain.TrackGain != -6.5 || meta.ReplayGain.TrackPeak != 0.5 {
+		t.Errorf("Unexpected ReplayGain %+v", meta.ReplayGain)
+	}
+
+	tags := map[string]string{}
+	parseID3v2(tag, tags)
+	if tags["title"] != "Süd" {
+		t.Errorf("Expected the UTF-16 title 'Süd', got %q", tags["title"])
+	}
+}
+
+func TestWavHeaderSkipsLargeChunks(t *testing.T) {
+	var header bytes.Buffer
+	header.WriteString("RIFF\x00\x00\x00\x00WAVE")
+	header.WriteString("fmt ")
+	binary.Write(&header, binary.LittleEndian, []uint32{16, 1<<16 | wavFormatPCM, 8000, 16000, 16<<16 | 2})
+
+	// Chunks are skipped without allocating their (claimed) size.
+	chunk := func(id string, size uint32) []byte {
+		return binary.LittleEndian.AppendUint32([]byte(id), size)
+	}
+	for _, id := range []string{"JUNK", "id3 "} {
+		file := io.MultiReader(bytes.NewReader(header.Bytes()), bytes.NewReader(chunk(id, 0xFFFFFFF0)))
+		if _, err := readWavHeader(file); err == nil {
+			t.Errorf("Expected an error for a truncated %q chunk", id)
+		}
+
+		file = io.MultiReader(
+			bytes.NewReader(header.Bytes()),
+			bytes.NewReader(chunk(id, maxWavTagChunkSize+1)),
+			io.LimitReader(zeroReader{}, maxWavTagChunkSize+2),
+			bytes.NewReader(chunk("data", 0)),
+		)
+		wav, err := readWavHeader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := int64(header.Len() + 8 + maxWavTagChunkSize + 2 + 8); wav.dataOffset != expected {
+			t.Errorf("Expected the data at %d, got %d", expected, wav.dataOffset)
+		}
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestMp3Decoding(t *testing.T) {
+	EnableNativeMP3()
+	sampleRate, channels, frames := config.SAMPLE_RATE, config.CHANNELS, config.FRAMES_PER_BUFFER
+	defer config.SetAudioFormat(sampleRate, channels, frames)
+	if err := config.SetAudioFormat(44100, 1, 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same value in spectral line 44 of every granule is a sine tone at 44 * 44100 / 1152 Hz.
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	testMp3{frames: 20, line: 44}.write(t, path)
+	total := 20*1152 - 576 - 1000
+	frequency := 44.0 * 44100 / 1152
+
+	meta, err := GetFileMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Sine" || meta.Duration != time.Duration(total)*time.Second/44100 {
+		t.Errorf("Unexpected meta data %+v", meta)
+	}
+	if meta.ReplayGain == nil || meta.ReplayGain.TrackGain != -2 {
+		t.Errorf("Unexpected ReplayGain %+v", meta.ReplayGain)
+	}
+
+	decoder, err := NewDecoder(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	output := readAll(t, decoder)[0]
+	if len(output) != total {
+		t.Fatalf("Expected %d frames, got %d", total, len(output))
+	}
+	checkTone(t, output, 44100, frequency)
+
+	// Seeking decodes some frames before the target, so the samples are exactly the same.
+	decoder, err = NewDecoder(path, 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+
+	seeked := readAll(t, decoder)[0]
+	if len(seeked) != total-13230 {
+		t.Fatalf("Expected %d frames after seeking, got %d", total-13230, len(seeked))
+	}
+	for i, sample := range seeked {
+		if math.Abs(float64(sample-output[13230+i])) > 1e-6 {
+			t.Fatalf("Frame %d after seeking: expected %f, got %f", i, output[13230+i], sample)
+		}
+	}
+}
+
+func TestMp3FrameFormats(t *testing.T) {
+	EnableNativeMP3()
+	sampleRate, channels, frames := config.SAMPLE_RATE, config.CHANNELS, config.FRAMES_PER_BUFFER
+	defer config.SetAudioFormat(sampleRate, channels, frames)
+	if err := config.SetAudioFormat(44100, 2, 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	decode := func(file testMp3) [][]float32 {
+		path := filepath.Join(t.TempDir(), "test.mp3")
+		file.write(t, path)
+		decoder, err := NewDecoder(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer decoder.Close()
+		return readAll(t, decoder)
+	}
+
+	// Mono files are played on both channels.
+	mono := decode(testMp3{frames: 20, line: 44})[0]
+
+	for name, test := range map[string]struct {
+		file        testMp3
+		left, right float64 // expected output relative to the mono file
+	}{
+		"crc":                {testMp3{frames: 20, line: 44, crc: true}, 1, 1},
+		"bit reservoir":      {testMp3{frames: 20, line: 44, reservoir: 100}, 1, 1},
+		"middle/side stereo": {testMp3{frames: 20, line: 44, jointStereo: 2}, math.Sqrt2 / 2, math.Sqrt2 / 2},
+		// The intensity position 0 moves everything to the right channel.
+		"intensity stereo": {testMp3{frames: 20, line: 44, jointStereo: 1}, 0, 1},
+	} {
+		output := decode(test.file)
+		if len(output[0]) != len(mono) {
+			t.Errorf("%s: expected %d frames, got %d", name, len(mono), len(output[0]))
+			continue
+		}
+	samples:
+		for i := range mono {
+			for ch, factor := range []float64{test.left, test.right} {
+				if expected := float64(mono[i]) * factor; math.Abs(float64(output[ch][i])-expected) > 1e-6 {
+					t.Errorf("%s: frame %d of channel %d should be %f, got %f", name, i, ch, expected, output[ch][i])
+					break samples
+				}
+			}
+		}
+	}
+
+	// MPEG-2 has a single granule per frame.
+	if err := config.SetAudioFormat(22050, 1, 1024); err != nil {
+		t.Fatal(err)
+	}
+	output := decode(testMp3{frames: 40, line: 44, lsf: true, crc: true, reservoir: 20})[0]
+	if total := 40*576 - 576 - 1000; len(output) != total {
+		t.Fatalf("Expected %d frames, got %d", total, len(output))
+	}
+	checkTone(t, output, 22050, 44.0*22050/1152)
+}
+
+func TestMp3HuffmanTablesArePrefixCodes(t *testing.T) {
+	codes := map[string]mp3HuffmanCode{"count1": mp3Count1Code}
+	for number, code := range mp3HuffmanCodes {
+		codes[fmt.Sprint(number)] = code
+	}
+
+	for name, code := range codes {
+		// Complete codes fill the whole code space.
+		sum := 0.0
+		for _, length := range code.lengths {
+			sum += math.Exp2(-float64(length))
+		}
+		if len(code.codes) != len(code.lengths) || math.Abs(sum-1) > 1e-9 {
+			t.Errorf("Table %s is not a complete code", name)
+		}
+
+		for i := range code.codes {
+			for j := range code.codes {
+				shorter := min(code.lengths[i], code.lengths[j])
+				if i != j && code.codes[i]>>(code.lengths[i]-shorter) == code.codes[j]>>(code.lengths[j]-shorter) {
+					t.Errorf("Table %s: code %d is a prefix of code %d", name, i, j)
+				}
+			}
+		}
+	}
+}
+
+// checkTone checks that the output is a sine tone with the amplitude of the test files.
+func checkTone(t *testing.T, output []float32, sampleRate int, frequency float64) {
+	t.Helper()
+
+	// The amplitude is the requantized value 2^((200 - 210) / 4).
+	peak := float32(0)
+	for _, sample := range output {
+		peak = max(peak, sample)
+	}
+	if math.Abs(float64(peak)-math.Exp2(-2.5)) > 0.002 {
+		t.Errorf("Expected a peak of %f, got %f", math.Exp2(-2.5), peak)
+	}
+
+	tone := toneLevel(output[2048:], sampleRate, frequency)
+	for _, other := range []float64{500, 1000, 2500, 5000} {
+		if level := toneLevel(output[2048:], sampleRate, other); level*100 > tone {
+			t.Errorf("Expected a tone at %.0f Hz, but the level at %.0f Hz is %f (%f)", frequency, other, level, tone)
+		}
+	}
+}
+
+// toneLevel returns the amplitude of the given frequency.
+func toneLevel(samples []float32, sampleRate int, frequency float64) float64 {
+	var re, im float64
+	for i, sample := range samples {
+		phase := 2 * math.Pi * frequency * float64(i) / float64(sampleRate)
+		re += float64(sample) * math.Cos(phase)
+		im += float64(sample) * math.Sin(phase)
+	}
+	return math.Hypot(re, im) / float64(len(samples))
+}
+
+// testMp3 describes an MP3 file with an ID3v2 tag and a LAME tag (576 samples delay, 1000 samples
+// padding). Every granule contains the same spectral line in the first channel, the second channel is empty.
+type testMp3 struct {
+	frames, line int
+	lsf          bool // MPEG-2 (22.05 kHz, 64 kbit/s) instead of MPEG-1 (44.1 kHz, 128 kbit/s)
+	crc          bool
+	jointStereo  int // mode extension of a joint stereo file (1 = intensity, 2 = middle/side), 0 = mono
+	reservoir    int // main_data_begin of all frames but the first
+}
+
+func (m testMp3) write(t *testing.T, path string) {
+	header := []byte{0xFF, 0xFB, 0x90, 0xC0}
+	frameSize, granules, channels, granuleInfoSize := 417, 2, 1, 59
+	if m.lsf {
+		header[1], header[2] = 0xF3, 0x80
+		frameSize, granules, granuleInfoSize = 208, 1, 63
+	}
+	if m.jointStereo != 0 {
+		header[3] = 0x40 | byte(m.jointStereo)<<4
+		channels = 2
+	}
+	if m.crc {
+		header[1] &^= 1
+	}
+
+	sideInfoSize := map[[2]bool]int{{false, false}: 17, {false, true}: 32, {true, false}: 9, {true, true}: 17}[[2]bool{m.lsf, channels == 2}]
+	frame := func(sideInfo, mainData []byte) []byte {
+		data := bytes.Clone(header)
+		if m.crc {
+			data = binary.BigEndian.AppendUint16(data, mp3CRC(header[2:], sideInfo))
+		}
+		data = append(append(data, sideInfo...), mainData...)
+		return append(data, make([]byte, frameSize-len(data))...)
+	}
+
+	var file bytes.Buffer
+	file.Write(id3v23Tag(
+		id3Frame("TIT2", []byte("\x00Sine")),
+		id3Frame("TXXX", []byte("\x00replaygain_track_gain\x00-2.00 dB")),
+	))
+
+	// Info frame with the number of frames and the LAME tag after the (empty) side information.
+	info := &bitWriter{}
+	info.bytes("Info")
+	info.write(1, 32)
+	info.write(uint64(m.frames), 32)
+	info.bytes("LAME3.100" + string(make([]byte, 12)))
+	info.write(576, 12)
+	info.write(1000, 12)
+	file.Write(frame(make([]byte, sideInfoSize), info.data))
+
+	// Big values of Huffman table 1 up to the line (with a positive sign), the rest is zero.
+	table := mp3HuffmanCodes[1]
+	granule := &bitWriter{}
+	for i := 0; i < m.line; i += 2 {
+		granule.write(uint64(table.codes[0]), uint(table.lengths[0]))
+	}
+	granule.write(uint64(table.codes[2]), uint(table.lengths[2]))
+	granule.write(0, 1)
+	length := 8*len(granule.data) - (8-int(granule.n))%8
+
+	mainData := &bitWriter{}
+	for range granules {
+		for i := range length {
+			mainData.write(uint64(granule.data[i/8]>>(7-i%8)&1), 1)
+		}
+	}
+
+	// The main data of all frames but the first starts in the previous frame.
+	capacity := frameSize - 4 - sideInfoSize
+	if m.crc {
+		capacity -= 2
+	}
+	reservoir := make([]byte, m.frames*capacity)
+	for i := range m.frames {
+		copy(reservoir[max(i*capacity-m.reservoir, 0):], mainData.data)
+	}
+
+	for i := range m.frames {
+		sideInfo := &bitWriter{}
+		begin := min(i*capacity, m.reservoir)
+		if m.lsf {
+			sideInfo.write(uint64(begin), 8)
+			sideInfo.write(0, uint(channels)) // private bits
+		} else {
+			sideInfo.write(uint64(begin), 9)
+			sideInfo.write(0, uint(7-2*channels)) // private bits
+			sideInfo.write(0, uint(4*channels))   // scfsi
+		}
+		for range granules {
+			sideInfo.write(uint64(length), 12)
+			sideInfo.write(uint64(m.line/2+1), 9) // big_values
+			sideInfo.write(200, 8)                // global_gain
+			if m.lsf {
+				sideInfo.write(0, 9+1) // scalefac_compress, window_switching
+			} else {
+				sideInfo.write(0, 4+1)
+			}
+			sideInfo.write(1<<10|1<<5|1, 15) // table_select
+			sideInfo.write(15, 4)            // region0_count
+			sideInfo.write(7, 3)             // region1_count
+			if m.lsf {
+				sideInfo.write(0, 2) // scalefac_scale, count1table
+			} else {
+				sideInfo.write(0, 3) // preflag, scalefac_scale, count1table
+			}
+			if channels == 2 {
+				// Nothing in the second channel (part2_3_length and big_values are 0).
+				sideInfo.write(0, uint(granuleInfoSize))
+			}
+		}
+		file.Write(frame(sideInfo.data, reservoir[i*capacity:(i+1)*capacity]))
+	}
+
+	if err := os.WriteFile(path, file.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mp3CRC returns the CRC-16 of the frame header (without the sync word) and the side information.
+func mp3CRC(data ...[]byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, part := range data {
+		for _, b := range part {
+			for bit := 7; bit >= 0; bit-- {
+				if (crc>>15)^uint16(b>>bit&1) != 0 {
+					crc = crc<<1 ^ 0x8005
+				} else {
+					crc <<= 1
+				}
+			}
+		}
+	}
+	return crc
+}
+
+func readAll(t *testing.T, decoder Decoder) [][]float32 {
+	output := make([][]float32, config.CHANNELS)
+	channels := make([][]float32, config.CHANNELS)
+	for ch := range channels {
+		channels[ch] = make([]float32, config.FRAMES_PER_BUFFER)
+	}
+
+	for {
+		n, err := decoder.ReadChunk(channels)
+		for ch := range channels {
+			output[ch] = append(output[ch], channels[ch][:n]...)
+		}
+		if err == io.EOF {
+			return output
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func writeWav(t *testing.T, sampleRate, channels int, samples []int16) string {
+	info := []byte("INFOINAM\x05\x00\x00\x00Ramp\x00\x00")
+
+	var data bytes.Buffer
+	data.WriteString("RIFF")
+	binary.Write(&data, binary.LittleEndian, uint32(4+8+16+8+len(info)+8+2*len(samples)))
+	data.WriteString("WAVEfmt ")
+	for _, value := range []any{
+		uint32(16), uint16(1), uint16(channels), uint32(sampleRate),
+		uint32(sampleRate * channels * 2), uint16(channels * 2), uint16(16),
+	} {
+		binary.Write(&data, binary.LittleEndian, value)
+	}
+	data.WriteString("LIST")
+	binary.Write(&data, binary.LittleEndian, uint32(len(info)))
+	data.Write(info)
+	data.WriteString("data")
+	binary.Write(&data, binary.LittleEndian, uint32(2*len(samples)))
+	binary.Write(&data, binary.LittleEndian, samples)
+
+	path := filepath.Join(t.TempDir(), "test.wav")
+	if err := os.WriteFile(path, data.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testFlacStream writes a FLAC file covering the different subframe types and channel
+// assignments and returns the samples it contains.
+func testFlacStream(t *testing.T, path string) (left, right []int32) {
+	const blockSize = 16
+	const total = 3*blockSize - 2
+
+	w := &bitWriter{}
+	w.bytes("fLaC")
+
+	// STREAMINFO
+	w.write(0, 1)
+	w.write(0, 7)
+	w.write(34, 24)
+	w.write(blockSize, 16)
+	w.write(blockSize, 16)
+	w.write(0, 24)
+	w.write(0, 24)
+	w.write(44100, 20)
+	w.write(1, 3)  // 2 channels
+	w.write(15, 5) // 16 bits
+	w.write(total, 36)
+	w.bytes(string(make([]byte, 16)))
+
+	// VORBIS_COMMENT
+	comments := []string{"ARTIST=Tester", "REPLAYGAIN_TRACK_GAIN=-3.00 dB"}
+	var block bytes.Buffer
+	binary.Write(&block, binary.LittleEndian, uint32(0))
+	binary.Write(&block, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(&block, binary.LittleEndian, uint32(len(comment)))
+		block.WriteString(comment)
+	}
+	w.write(1, 1)
+	w.write(4, 7)
+	w.write(uint64(block.Len()), 24)
+	w.bytes(block.String())
+
+	frameHeader := func(channelAssignment uint64, number uint64) {
+		w.write(0x3FFE, 14)
+		w.write(0, 2)
+		w.write(6, 4) // 8-bit block size follows
+		w.write(0, 4) // sample rate from STREAMINFO
+		w.write(channelAssignment, 4)
+		w.write(4, 3) // 16 bits
+		w.write(0, 1)
+		w.write(number, 8)
+		w.write(blockSize-1, 8)
+		w.write(0, 8) // CRC-8
+	}
+	frameFooter := func() {
+		w.align()
+		w.write(0, 16) // CRC-16
+	}
+
+	// Frame 1: independent channels, fixed predictor and a constant.
+	frameHeader(1, 0)
+	w.write((8+2)<<1, 8) // FIXED, order 2
+	for i := range blockSize {
+		left = append(left, int32(3*i*i-100))
+		right = append(right, -1000)
+		if i < 2 {
+			w.writeSigned(int64(left[i]), 16)
+		}
+	}
+	// The second order residual is constant (6), rice coded with parameter 3.
+	w.write(0, 2)
+	w.write(0, 4)
+	w.write(3, 4)
+	for range blockSize - 2 {
+		w.write(1, 2) // 12 >> 3 = 1 in unary
+		w.write(12&7, 3)
+	}
+	w.write(0, 8) // CONSTANT
+	w.writeSigned(-1000, 16)
+	frameFooter()
+
+	// Frame 2: mid/side, verbatim
+	frameHeader(10, 1)
+	var mid, side []int32
+	for i := range blockSize {
+		l, r := int32(1000+2*i), int32(-2000+4*i)
+		left, right = append(left, l), append(right, r)
+		mid, side = append(mid, (l+r)>>1), append(side, l-r)
+	}
+	w.write(1<<1, 8) // VERBATIM
+	for _, sample := range mid {
+		w.writeSigned(int64(sample), 16)
+	}
+	w.write(1<<1|1, 8) // VERBATIM with wasted bits
+	w.write(1, 1)      // 1 wasted bit
+	for _, sample := range side {
+		// The side channel has 17 bits, one of them is wasted.
+		w.writeSigned(int64(sample>>1), 16)
+	}
+	frameFooter()
+
+	// Frame 3: LPC with an escaped residual (only partially part of the stream).
+	frameHeader(1, 2)
+	w.write(32<<1, 8) // LPC, order 1
+	w.writeSigned(500, 16)
+	w.write(3, 4)       // 4-bit coefficients
+	w.writeSigned(1, 5) // shift
+	w.writeSigned(2, 4) // s[i] = 2*s[i-1] >> 1 + residual
+	w.write(0, 2)
+	w.write(0, 4)
+	w.write(15, 4) // escape
+	w.write(8, 5)
+	for i := range blockSize {
+		sample := int32(500 + 3*i)
+		if i > 0 {
+			w.writeSigned(3, 8)
+		}
+		if len(left) < total {
+			left, right = append(left, sample), append(right, 0)
+		}
+	}
+	w.write(0, 8) // CONSTANT
+	w.writeSigned(0, 16)
+	frameFooter()
+
+	if err := os.WriteFile(path, w.data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return left, right
+}
+
+type bitWriter struct {
+	data []byte
+	n    uint // bits used in the last byte
+}
+
+func (w *bitWriter) write(value uint64, bits uint) {
+	for i := int(bits) - 1; i >= 0; i-- {
+		if w.n == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(value>>i&1) << (7 - w.n)
+		w.n = (w.n + 1) % 8
+	}
+}
+
+func (w *bitWriter) writeSigned(value int64, bits uint) {
+	w.write(uint64(value)&(1<<bits-1), bits)
+}
+
+func (w *bitWriter) bytes(value string) {
+	for i := range len(value) {
+		w.write(uint64(value[i]), 8)
+	}
+}
+
+func (w *bitWriter) align() {
+	w.n = 0
+}
+
+func id3Frame(id string, content []byte) []byte {
+	frame := []byte(id)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(content)))
+	frame = append(frame, 0, 0)
+	return append(frame, content...)
+}
+
+func id3v23Tag(frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	size := len(body)
+	return append([]byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F),
+	}, body...)
+}
@@ -10,28 +10,45 @@ import (
 	"os/exec"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/tim-we/wavestreamer/config"
 	"github.com/tim-we/wavestreamer/utils"
 )
 
+// DecodingProcess decodes a file with ffmpeg. It is used for all formats without a native decoder.
 type DecodingProcess struct {
 	filepath string
 	cmd      *exec.Cmd
 	stdout   io.ReadCloser
 	reader   *bufio.Reader
+	// Raw PCM data of the last read, reused to avoid allocations.
+	buffer []byte
+	// The last error messages of ffmpeg.
+	stderr *tailBuffer
+	// The process must only be waited for once.
+	waitOnce sync.Once
+	waitErr  error
 }
 
-func NewDecodingProcess(filepath string) DecodingProcess {
+// NewDecodingProcess prepares an ffmpeg process that decodes the file starting at the given offset.
+func NewDecodingProcess(filepath string, offset time.Duration) *DecodingProcess {
 	threads := max(1, runtime.NumCPU()/2)
 
 	if threads > 1 && utils.ShouldReduceCPU() {
 		threads = 1
 	}
 
-	cmd := exec.Command(
-		"ffmpeg",
-		"-threads", strconv.Itoa(threads),
+	args := []string{"-v", "error", "-threads", strconv.Itoa(threads)}
+
+	if offset > 0 {
+		// As an input option this seeks in the input file, which is fast.
+		args = append(args, "-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64))
+	}
+
+	args = append(args,
 		"-i", filepath, // input file
 		"-f", "s16le", // output format (signed 16bit integer little endian)
 		"-ac", strconv.Itoa(config.CHANNELS),
@@ -39,16 +56,20 @@ func NewDecodingProcess(filepath string) DecodingProcess {
 		"pipe:1", // output to stdout
 	)
 
+	cmd := exec.Command("ffmpeg", args...)
+	stderr := &tailBuffer{limit: 512}
+	cmd.Stderr = stderr
+
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return DecodingProcess{
-		filepath,
-		cmd,
-		stdout,
-		nil,
+	return &DecodingProcess{
+		filepath: filepath,
+		cmd:      cmd,
+		stdout:   stdout,
+		stderr:   stderr,
 	}
 }
 
@@ -74,13 +95,12 @@ func (process *DecodingProcess) Close() {
 		return
 	}
 
-	if process.cmd.ProcessState == nil || !process.cmd.ProcessState.Exited() {
-		if err := process.cmd.Process.Kill(); err != nil && err != os.ErrProcessDone {
-			log.Printf("Failed to kill process: %v", err)
-		}
+	// Killing a process that has already exited has no effect.
+	if err := process.cmd.Process.Kill(); err != nil && err != os.ErrProcessDone {
+		log.Printf("Failed to kill process: %v", err)
 	}
 
-	if waitErr := process.cmd.Wait(); waitErr != nil && waitErr != os.ErrProcessDone {
+	if waitErr := process.wait(); waitErr != nil && waitErr != os.ErrProcessDone {
 		// Only log unexpected errors (e.g. not "signal: killed")
 		if exitErr, ok := waitErr.(*exec.ExitError); ok && exitErr.ExitCode() != -1 {
 			log.Printf("Process exited with error: %v", waitErr)
@@ -88,20 +108,87 @@ func (process *DecodingProcess) Close() {
 	}
 }
 
-// readFrame reads two 16-bit samples from the PCM stream
-func (process *DecodingProcess) ReadFrame() (float32, float32, error) {
-	var left, right int16
-	if err := binary.Read(process.reader, binary.LittleEndian, &left); err != nil {
-		return 0, 0, err
+// wait waits for the process to exit. It may be called more than once and from different goroutines.
+func (process *DecodingProcess) wait() error {
+	process.waitOnce.Do(func() {
+		process.waitErr = process.cmd.Wait()
+	})
+	return process.waitErr
+}
+
+// ReadChunk reads up to len(channels[0]) frames of the PCM stream into the channel buffers
+// (one buffer per channel) and returns the number of frames read. At the end of the stream
+// io.EOF is returned along with the remaining frames.
+func (process *DecodingProcess) ReadChunk(channels [][]float32) (int, error) {
+	frameSize := 2 * len(channels)
+	size := frameSize * len(channels[0])
+
+	if cap(process.buffer) < size {
+		process.buffer = make([]byte, size)
 	}
-	if err := binary.Read(process.reader, binary.LittleEndian, &right); err != nil {
-		return 0, 0, err
+	buffer := process.buffer[:size]
+
+	n, err := io.ReadFull(process.reader, buffer)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err == io.EOF {
+		// The output might have ended because ffmpeg failed.
+		if exitErr := process.WaitForExit(); exitErr != nil {
+			err = exitErr
+		}
+	}
+
+	frames := n / frameSize
+	numChannels := len(channels)
+
+	// Convert interleaved signed 16-bit little endian samples.
+	for ch, samples := range channels {
+		samples = samples[:frames]
+		for i := range samples {
+			offset := 2 * (i*numChannels + ch)
+			sample := int16(binary.LittleEndian.Uint16(buffer[offset:]))
+			samples[i] = float32(sample) / 32768.0
+		}
+	}
+
+	return frames, err
+}
+
+// WaitForExit waits until ffmpeg has exited and returns an error if it failed.
+func (process *DecodingProcess) WaitForExit() error {
+	if process.cmd == nil || process.cmd.Process == nil {
+		// Not started
+		return nil
+	}
+
+	if err := process.wait(); err != nil {
+		if message := process.stderr.String(); message != "" {
+			return fmt.Errorf("ffmpeg failed (%w): %s", err, message)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	return nil
+}
+
+// tailBuffer keeps the last bytes written to it.
+type tailBuffer struct {
+	data  []byte
+	limit int
+}
+
+func (buffer *tailBuffer) Write(p []byte) (int, error) {
+	buffer.data = append(buffer.data, p...)
+	if len(buffer.data) > buffer.limit {
+		buffer.data = buffer.data[len(buffer.data)-buffer.limit:]
 	}
-	return float32(left) / 32768.0, float32(right) / 32768.0, nil
+	return len(p), nil
 }
 
-func (process *DecodingProcess) WaitForExit() {
-	if waitErr := process.cmd.Wait(); waitErr != nil {
-		log.Fatal(waitErr)
+func (buffer *tailBuffer) String() string {
+	if buffer == nil {
+		return ""
 	}
+	return strings.TrimSpace(string(buffer.data))
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -17,10 +18,26 @@ type AudioFileMetaData struct {
 	Title  string
 	Artist string
 	Album  string
+
+	// Optional ReplayGain information, nil if the file has no gain tags.
+	ReplayGain *ReplayGainInfo
+}
+
+// Files that can be parsed without ffprobe (by file extension).
+var nativeMetadata = map[string]func(filepath string) (*AudioFileMetaData, error){
+	".wav":  wavMetadata,
+	".flac": flacMetadata,
 }
 
 // GetFileMetadata fetches the duration of an audio file in seconds using ffprobe and, if available, the tracks title, artist and album.
+// WAV and FLAC files (and MP3 files, see EnableNativeMP3) are parsed directly.
 func GetFileMetadata(filePath string) (*AudioFileMetaData, error) {
+	if parse, ok := nativeMetadata[strings.ToLower(filepath.Ext(filePath))]; ok {
+		if meta, err := parse(filePath); err == nil {
+			return meta, nil
+		}
+	}
+
 	// Run ffprobe with JSON output
 	cmd := exec.Command(
 		"ffprobe",
@@ -59,22 +76,22 @@ func GetFileMetadata(filePath string) (*AudioFileMetaData, error) {
 		}
 	}
 
-	// Fallback to stream tags if format tags are missing
-	if len(metadata) == 0 && len(probeResult.Streams) > 0 {
-		for _, stream := range probeResult.Streams {
-			if stream.Tags != nil {
-				for key, value := range stream.Tags {
-					metadata[strings.ToLower(key)] = value
-				}
+	// Fallback to stream tags for tags missing in the container (e.g. Ogg files store all tags in the stream)
+	for _, stream := range probeResult.Streams {
+		for key, value := range stream.Tags {
+			key = strings.ToLower(key)
+			if _, exists := metadata[key]; !exists {
+				metadata[key] = value
 			}
 		}
 	}
 
 	return &AudioFileMetaData{
-		Duration: time.Duration(duration * float64(time.Second)),
-		Title:    metadata["title"],
-		Artist:   metadata["artist"],
-		Album:    metadata["album"],
+		Duration:   time.Duration(duration * float64(time.Second)),
+		Title:      metadata["title"],
+		Artist:     metadata["artist"],
+		Album:      metadata["album"],
+		ReplayGain: parseReplayGain(metadata),
 	}, nil
 }
 
@@ -0,0 +1,651 @@
+package decoder
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	flacBlockStreamInfo    = 0
+	flacBlockSeekTable     = 3
+	flacBlockVorbisComment = 4
+)
+
+// flacStream contains the metadata blocks of a FLAC file.
+type flacStream struct {
+	sampleRate    int
+	channels      int
+	bitsPerSample int
+	totalSamples  int64 // 0 = unknown
+	seekPoints    []flacSeekPoint
+	tags          map[string]string
+	// Position of the first frame in the file.
+	framesOffset int64
+}
+
+type flacSeekPoint struct {
+	sample int64
+	offset int64 // relative to the first frame
+}
+
+// readFlacStream parses the metadata blocks up to the first audio frame.
+func readFlacStream(r io.Reader) (*flacStream, error) {
+	var marker [4]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil {
+		return nil, err
+	}
+	offset := int64(len(marker))
+
+	if string(marker[0:3]) == "ID3" {
+		// Skip an ID3v2 tag in front of the stream.
+		var id3 [6]byte
+		if _, err := io.ReadFull(r, id3[:]); err != nil {
+			return nil, err
+		}
+		size := int64(id3[2])<<21 | int64(id3[3])<<14 | int64(id3[4])<<7 | int64(id3[5])
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, err
+		}
+		offset += int64(len(id3)) + size + int64(len(marker))
+	}
+
+	if string(marker[:]) != "fLaC" {
+		return nil, errors.New("not a FLAC file")
+	}
+
+	stream := &flacStream{tags: map[string]string{}}
+
+	for last := false; !last; {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		last = header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		offset += int64(len(header) + size)
+
+		switch blockType {
+		case flacBlockStreamInfo:
+			if size < 34 {
+				return nil, errors.New("invalid STREAMINFO block")
+			}
+			info := binary.BigEndian.Uint64(data[10:18])
+			stream.sampleRate = int(info >> 44)
+			stream.channels = int(info>>41&0x7) + 1
+			stream.bitsPerSample = int(info>>36&0x1F) + 1
+			stream.totalSamples = int64(info & (1<<36 - 1))
+		case flacBlockSeekTable:
+			for point := data; len(point) >= 18; point = point[18:] {
+				sample := binary.BigEndian.Uint64(point[0:8])
+				if sample == 0xFFFFFFFFFFFFFFFF {
+					// Placeholder
+					continue
+				}
+				stream.seekPoints = append(stream.seekPoints, flacSeekPoint{
+					sample: int64(sample),
+					offset: int64(binary.BigEndian.Uint64(point[8:16])),
+				})
+			}
+		case flacBlockVorbisComment:
+			parseVorbisComment(data, stream.tags)
+		}
+	}
+
+	if stream.sampleRate == 0 {
+		return nil, errors.New("missing STREAMINFO block")
+	}
+
+	stream.framesOffset = offset
+	return stream, nil
+}
+
+// parseVorbisComment reads the tags (with lower case keys) of a VORBIS_COMMENT block.
+func parseVorbisComment(data []byte, tags map[string]string) {
+	next := func() ([]byte, bool) {
+		if len(data) < 4 {
+			return nil, false
+		}
+		length := int(binary.LittleEndian.Uint32(data))
+		if length > len(data)-4 {
+			return nil, false
+		}
+		value := data[4 : 4+length]
+		data = data[4+length:]
+		return value, true
+	}
+
+	// Vendor string
+	if _, ok := next(); !ok || len(data) < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+
+	for range count {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		if key, value, found := strings.Cut(string(comment), "="); found {
+			tags[strings.ToLower(key)] = value
+		}
+	}
+}
+
+// flacSource decodes FLAC frames.
+type flacSource struct {
+	file   *os.File
+	reader *bufio.Reader
+	bits   bitReader
+	stream *flacStream
+
+	// Samples of the current frame and the next frame to read from it.
+	samples [][]int32
+	length  int
+	index   int
+	// Number of samples decoded so far.
+	decoded int64
+}
+
+func openFlac(filepath string) (source, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	stream, err := readFlacStream(reader)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	src := &flacSource{
+		file:    file,
+		reader:  reader,
+		stream:  stream,
+		samples: make([][]int32, stream.channels),
+	}
+	src.bits.reset(reader)
+
+	return src, nil
+}
+
+func (src *flacSource) format() (int, int, int64) {
+	return src.stream.sampleRate, src.stream.channels, src.stream.totalSamples
+}
+
+func (src *flacSource) readFrames(buffers [][]float32) (int, error) {
+	frames := 0
+	scale := 1 / float32(int64(1)<<(src.stream.bitsPerSample-1))
+
+	for frames < len(buffers[0]) {
+		if src.index >= src.length {
+			if err := src.decodeFrame(); err != nil {
+				return frames, err
+			}
+			continue
+		}
+
+		n := min(len(buffers[0])-frames, src.length-src.index)
+		for ch, samples := range src.samples {
+			for i, sample := range samples[src.index : src.index+n] {
+				buffers[ch][frames+i] = float32(sample) * scale
+			}
+		}
+		src.index += n
+		frames += n
+	}
+
+	return frames, nil
+}
+
+func (src *flacSource) seek(frame int64) (int64, error) {
+	// Find the last seek point before the target. Without a seek table we decode from the start.
+	var point flacSeekPoint
+	for _, p := range src.stream.seekPoints {
+		if p.sample > frame {
+			break
+		}
+		point = p
+	}
+
+	if _, err := src.file.Seek(src.stream.framesOffset+point.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	src.reader.Reset(src.file)
+	src.bits.reset(src.reader)
+	src.length = 0
+	src.index = 0
+	src.decoded = point.sample
+
+	return point.sample, nil
+}
+
+func (src *flacSource) close() error {
+	return src.file.Close()
+}
+
+// decodeFrame decodes the next frame into the sample buffers.
+func (src *flacSource) decodeFrame() error {
+	stream := src.stream
+	if stream.totalSamples > 0 && src.decoded >= stream.totalSamples {
+		return io.EOF
+	}
+
+	sync, err := src.bits.read(14)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return io.EOF
+	} else if err != nil {
+		return err
+	}
+	if sync != 0x3FFE {
+		if stream.totalSamples == 0 {
+			// Probably trailing data like an ID3v1 tag.
+			return io.EOF
+		}
+		return errors.New("lost FLAC frame sync")
+	}
+
+	header, err := src.bits.read(18)
+	if err != nil {
+		return err
+	}
+	blockSizeCode := header >> 12 & 0xF
+	sampleRateCode := header >> 8 & 0xF
+	channelAssignment := int(header >> 4 & 0xF)
+	sampleSizeCode := header >> 1 & 0x7
+
+	// Frame or sample number (UTF-8 like coding), we don't need it.
+	first, err := src.bits.read(8)
+	if err != nil {
+		return err
+	}
+	for mask := uint64(0x80); first&mask != 0 && mask > 1; mask >>= 1 {
+		if mask != 0x80 {
+			if _, err := src.bits.read(8); err != nil {
+				return err
+			}
+		}
+	}
+
+	var blockSize int
+	switch {
+	case blockSizeCode == 1:
+		blockSize = 192
+	case blockSizeCode >= 2 && blockSizeCode <= 5:
+		blockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		value, err := src.bits.read(8)
+		if err != nil {
+			return err
+		}
+		blockSize = int(value) + 1
+	case blockSizeCode == 7:
+		value, err := src.bits.read(16)
+		if err != nil {
+			return err
+		}
+		blockSize = int(value) + 1
+	case blockSizeCode >= 8:
+		blockSize = 256 << (blockSizeCode - 8)
+	default:
+		return errors.New("invalid FLAC block size")
+	}
+
+	// The sample rate is always taken from the STREAMINFO, only skip the extra bits.
+	switch sampleRateCode {
+	case 12:
+		_, err = src.bits.read(8)
+	case 13, 14:
+		_, err = src.bits.read(16)
+	}
+	if err != nil {
+		return err
+	}
+
+	// CRC-8 of the header
+	if _, err := src.bits.read(8); err != nil {
+		return err
+	}
+
+	bitsPerSample := stream.bitsPerSample
+	if sampleSizeCode != 0 {
+		bitsPerSample = flacSampleSizes[sampleSizeCode]
+		if bitsPerSample == 0 || bitsPerSample != stream.bitsPerSample {
+			return fmt.Errorf("unsupported FLAC sample size code %d", sampleSizeCode)
+		}
+	}
+
+	channels := channelAssignment + 1
+	if channelAssignment >= 8 {
+		if channelAssignment > 10 {
+			return errors.New("invalid FLAC channel assignment")
+		}
+		channels = 2
+	}
+	if channels != stream.channels {
+		return errors.New("unexpected number of channels in FLAC frame")
+	}
+
+	for ch := range channels {
+		if cap(src.samples[ch]) < blockSize {
+			src.samples[ch] = make([]int32, blockSize)
+		}
+		samples := src.samples[ch][:blockSize]
+
+		// The side channel has one more bit.
+		sideChannel := (channelAssignment == 8 || channelAssignment == 10) && ch == 1 ||
+			channelAssignment == 9 && ch == 0
+		bps := bitsPerSample
+		if sideChannel {
+			bps++
+		}
+
+		if err := src.decodeSubframe(samples, uint(bps)); err != nil {
+			return err
+		}
+	}
+
+	decorrelate(src.samples[:channels], blockSize, channelAssignment)
+
+	// Padding and CRC-16 of the frame
+	src.bits.align()
+	if _, err := src.bits.read(16); err != nil {
+		return err
+	}
+
+	src.length = blockSize
+	src.index = 0
+	if stream.totalSamples > 0 {
+		// The last frame might contain more samples than the stream.
+		src.length = int(min(int64(blockSize), stream.totalSamples-src.decoded))
+	}
+	src.decoded += int64(blockSize)
+
+	return nil
+}
+
+func (src *flacSource) decodeSubframe(samples []int32, bps uint) error {
+	header, err := src.bits.read(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return errors.New("invalid FLAC subframe header")
+	}
+	subframeType := header >> 1 & 0x3F
+
+	// Wasted bits per sample
+	var wasted uint
+	if header&1 != 0 {
+		count, err := src.bits.readUnary()
+		if err != nil {
+			return err
+		}
+		wasted = uint(count) + 1
+		bps -= wasted
+	}
+
+	switch {
+	case subframeType == 0:
+		// Constant
+		value, err := src.bits.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		for i := range samples {
+			samples[i] = int32(value)
+		}
+	case subframeType == 1:
+		// Verbatim
+		for i := range samples {
+			value, err := src.bits.readSigned(bps)
+			if err != nil {
+				return err
+			}
+			samples[i] = int32(value)
+		}
+	case subframeType >= 8 && subframeType <= 12:
+		if err := src.decodeFixed(samples, bps, int(subframeType-8)); err != nil {
+			return err
+		}
+	case subframeType >= 32:
+		if err := src.decodeLPC(samples, bps, int(subframeType-31)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid FLAC subframe type %d", subframeType)
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= wasted
+		}
+	}
+
+	return nil
+}
+
+// Bits per sample by the sample size code of the frame header (0 = reserved or from STREAMINFO).
+var flacSampleSizes = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+
+// Coefficients of the fixed predictors (order 0 to 4).
+var fixedCoefficients = [][]int64{
+	{},
+	{1},
+	{2, -1},
+	{3, -3, 1},
+	{4, -6, 4, -1},
+}
+
+func (src *flacSource) decodeFixed(samples []int32, bps uint, order int) error {
+	if order > len(samples) {
+		return errors.New("invalid FLAC predictor order")
+	}
+	if err := src.readWarmup(samples[:order], bps); err != nil {
+		return err
+	}
+	if err := src.decodeResidual(samples, order); err != nil {
+		return err
+	}
+
+	predict(samples, fixedCoefficients[order], 0)
+	return nil
+}
+
+func (src *flacSource) decodeLPC(samples []int32, bps uint, order int) error {
+	if order > len(samples) {
+		return errors.New("invalid FLAC predictor order")
+	}
+	if err := src.readWarmup(samples[:order], bps); err != nil {
+		return err
+	}
+
+	precision, err := src.bits.read(4)
+	if err != nil {
+		return err
+	}
+	if precision == 0xF {
+		return errors.New("invalid FLAC coefficient precision")
+	}
+	shift, err := src.bits.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return errors.New("negative FLAC LPC shift")
+	}
+
+	var buffer [32]int64
+	coefficients := buffer[:order]
+	for i := range coefficients {
+		if coefficients[i], err = src.bits.readSigned(uint(precision) + 1); err != nil {
+			return err
+		}
+	}
+
+	if err := src.decodeResidual(samples, order); err != nil {
+		return err
+	}
+
+	predict(samples, coefficients, uint(shift))
+	return nil
+}
+
+func (src *flacSource) readWarmup(samples []int32, bps uint) error {
+	for i := range samples {
+		value, err := src.bits.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		samples[i] = int32(value)
+	}
+	return nil
+}
+
+// decodeResidual reads the rice coded residual into samples[order:].
+func (src *flacSource) decodeResidual(samples []int32, order int) error {
+	method, err := src.bits.read(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return errors.New("invalid FLAC residual coding method")
+	}
+	paramBits, escape := uint(4), uint64(0xF)
+	if method == 1 {
+		paramBits, escape = 5, 0x1F
+	}
+
+	partitionOrder, err := src.bits.read(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	partitionSize := len(samples) >> partitionOrder
+	if partitionSize<<partitionOrder != len(samples) || partitionSize < order {
+		return errors.New("invalid FLAC partition order")
+	}
+
+	i := order
+	for p := range partitions {
+		end := (p + 1) * partitionSize
+
+		param, err := src.bits.read(paramBits)
+		if err != nil {
+			return err
+		}
+
+		if param == escape {
+			// Unencoded residual with a fixed number of bits.
+			size, err := src.bits.read(5)
+			if err != nil {
+				return err
+			}
+			for ; i < end; i++ {
+				value, err := src.bits.readSigned(uint(size))
+				if err != nil {
+					return err
+				}
+				samples[i] = int32(value)
+			}
+			continue
+		}
+
+		for ; i < end; i++ {
+			high, err := src.bits.readUnary()
+			if err != nil {
+				return err
+			}
+			low, err := src.bits.read(uint(param))
+			if err != nil {
+				return err
+			}
+			value := high<<param | low
+			// Zigzag decoding
+			samples[i] = int32(value>>1) ^ -int32(value&1)
+		}
+	}
+
+	return nil
+}
+
+// predict restores the samples from the residual (samples[order:]) and the predictor.
+func predict(samples []int32, coefficients []int64, shift uint) {
+	order := len(coefficients)
+
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range coefficients {
+			sum += c * int64(samples[i-j-1])
+		}
+		samples[i] += int32(sum >> shift)
+	}
+}
+
+// decorrelate converts stereo channels from left/side, side/right or mid/side to left/right.
+func decorrelate(samples [][]int32, blockSize, channelAssignment int) {
+	if channelAssignment < 8 {
+		return
+	}
+
+	a, b := samples[0][:blockSize], samples[1][:blockSize]
+
+	for i := range blockSize {
+		switch channelAssignment {
+		case 8:
+			// left/side
+			b[i] = a[i] - b[i]
+		case 9:
+			// side/right
+			a[i] += b[i]
+		case 10:
+			// mid/side
+			side := b[i]
+			mid := a[i]<<1 | side&1
+			a[i] = (mid + side) >> 1
+			b[i] = (mid - side) >> 1
+		}
+	}
+}
+
+// flacMetadata reads the duration and tags from the metadata blocks of a FLAC file.
+func flacMetadata(filepath string) (*AudioFileMetaData, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stream, err := readFlacStream(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	if stream.totalSamples == 0 {
+		return nil, errors.New("unknown duration")
+	}
+
+	return &AudioFileMetaData{
+		Duration:   time.Duration(stream.totalSamples) * time.Second / time.Duration(stream.sampleRate),
+		Title:      stream.tags["title"],
+		Artist:     stream.tags["artist"],
+		Album:      stream.tags["album"],
+		ReplayGain: parseReplayGain(stream.tags),
+	}, nil
+}
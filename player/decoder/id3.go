@@ -0,0 +1,218 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+// Size of the header (and the optional footer) of an ID3v2 tag.
+const id3HeaderSize = 10
+
+// Text frames that we care about, by ID3v2.3/2.4 and ID3v2.2 frame id.
+var id3TextFrames = map[string]string{
+	"TIT2": "title",
+	"TPE1": "artist",
+	"TALB": "album",
+	"TT2":  "title",
+	"TP1":  "artist",
+	"TAL":  "album",
+}
+
+// id3TagSize returns the total size of the ID3v2 tag that starts with the given header
+// (at least id3HeaderSize bytes), or false if there is no tag.
+func id3TagSize(header []byte) (int, bool) {
+	if len(header) < id3HeaderSize || string(header[0:3]) != "ID3" || header[3] < 2 || header[3] > 4 {
+		return 0, false
+	}
+
+	size := id3HeaderSize + syncsafeInt(header[6:10])
+	if header[5]&0x10 != 0 {
+		// Footer (ID3v2.4)
+		size += id3HeaderSize
+	}
+	return size, true
+}
+
+// parseID3v2 reads the title, artist, album and the user defined text frames (TXXX, by their
+// lower case description, e.g. "replaygain_track_gain") of an ID3v2 tag into the given map.
+// Tags that are already set are kept.
+func parseID3v2(tag []byte, tags map[string]string) {
+	if _, ok := id3TagSize(tag); !ok {
+		return
+	}
+
+	version, flags := tag[3], tag[5]
+	end := min(len(tag), id3HeaderSize+syncsafeInt(tag[6:10]))
+	data := tag[id3HeaderSize:end]
+
+	if flags&0x80 != 0 && version < 4 {
+		// Unsynchronisation of the whole tag (ID3v2.4 flags it per frame instead).
+		data = removeUnsynchronisation(data)
+	}
+
+	if flags&0x40 != 0 && len(data) >= 4 {
+		// Skip the extended header.
+		size := int(binary.BigEndian.Uint32(data[0:4])) + 4
+		if version == 4 {
+			size = syncsafeInt(data[0:4])
+		}
+		data = data[min(len(data), size):]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	for len(data) >= headerSize && data[0] != 0 {
+		id := string(data[0:idSize])
+
+		var size int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			size = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(data[4:8]))
+			frameFlags = binary.BigEndian.Uint16(data[8:10])
+		default:
+			size = syncsafeInt(data[4:8])
+			frameFlags = binary.BigEndian.Uint16(data[8:10])
+		}
+
+		if size > len(data)-headerSize {
+			return
+		}
+		frame := data[headerSize : headerSize+size]
+		data = data[headerSize+size:]
+
+		frame, ok := id3FrameContent(frame, version, frameFlags)
+		if !ok || len(frame) == 0 {
+			continue
+		}
+
+		key, isText := id3TextFrames[id]
+		value := ""
+		switch {
+		case isText:
+			value, _ = splitID3Text(frame[0], frame[1:])
+		case id == "TXXX" || id == "TXX":
+			description, rest := splitID3Text(frame[0], frame[1:])
+			key = strings.ToLower(description)
+			// Only the first value of a list
+			value, _, _ = strings.Cut(rest, "\x00")
+		default:
+			continue
+		}
+
+		if _, exists := tags[key]; !exists && key != "" && value != "" {
+			tags[key] = value
+		}
+	}
+}
+
+// id3FrameContent undoes the frame specific encodings. Returns false for compressed
+// or encrypted frames, which are not supported.
+func id3FrameContent(frame []byte, version byte, flags uint16) ([]byte, bool) {
+	switch version {
+	case 3:
+		if flags&0x00C0 != 0 {
+			return nil, false
+		}
+		if flags&0x0020 != 0 && len(frame) > 0 {
+			// Grouping identity
+			frame = frame[1:]
+		}
+	case 4:
+		if flags&0x000C != 0 {
+			return nil, false
+		}
+		if flags&0x0040 != 0 && len(frame) > 0 {
+			// Grouping identity
+			frame = frame[1:]
+		}
+		if flags&0x0002 != 0 {
+			frame = removeUnsynchronisation(frame)
+		}
+		if flags&0x0001 != 0 && len(frame) >= 4 {
+			// Data length indicator
+			frame = frame[4:]
+		}
+	}
+	return frame, true
+}
+
+// splitID3Text decodes the first (terminated) string of a text frame and returns the rest
+// of the frame (as UTF-8) for frames with more than one string.
+func splitID3Text(encoding byte, data []byte) (string, string) {
+	switch encoding {
+	case 1, 2:
+		// UTF-16 with byte order mark (1) or big endian (2), terminated by two zero bytes.
+		end := len(data) - len(data)%2
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				end = i
+				break
+			}
+		}
+		text := decodeUTF16(data[:end], encoding == 2)
+		rest := data[min(len(data), end+2):]
+		return text, decodeUTF16(rest, encoding == 2)
+	default:
+		// ISO-8859-1 (0) or UTF-8 (3), terminated by a zero byte.
+		text, rest, _ := bytes.Cut(data, []byte{0})
+		if encoding == 0 {
+			return latin1ToString(text), latin1ToString(rest)
+		}
+		return string(text), string(rest)
+	}
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			order, data = binary.LittleEndian, data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			order, data = binary.BigEndian, data[2:]
+		}
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
+
+func latin1ToString(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// removeUnsynchronisation removes the zero bytes that have been inserted after 0xFF bytes.
+func removeUnsynchronisation(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		result = append(result, data[i])
+		if data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0 {
+			i++
+		}
+	}
+	return result
+}
+
+// syncsafeInt decodes a 28-bit integer that is stored in the lower 7 bits of 4 bytes.
+func syncsafeInt(data []byte) int {
+	return int(data[0]&0x7F)<<21 | int(data[1]&0x7F)<<14 | int(data[2]&0x7F)<<7 | int(data[3]&0x7F)
+}
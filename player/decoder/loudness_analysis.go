@@ -0,0 +1,81 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// LoudnessInfo is the result of an EBU R128 analysis of a file.
+type LoudnessInfo struct {
+	// Integrated loudness in LUFS.
+	Integrated float64 `json:"integrated"`
+
+	// Maximum true peak in dBTP.
+	TruePeak float64 `json:"truePeak"`
+}
+
+// AnalyzeLoudness measures the integrated loudness and the true peak of a file with the
+// ffmpeg loudnorm filter. The whole file has to be decoded, so this takes a while.
+func AnalyzeLoudness(filePath string) (*LoudnessInfo, error) {
+	cmd := exec.Command(
+		"ffmpeg",
+		"-hide_banner",
+		"-nostats",
+		"-threads", "1",
+		"-i", filePath,
+		"-vn", // ignore cover art
+		"-af", "loudnorm=print_format=json",
+		"-f", "null",
+		"-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to analyze '%s': %w", filePath, err)
+	}
+
+	return parseLoudnormOutput(stderr.Bytes())
+}
+
+// parseLoudnormOutput extracts the measurements from the JSON block the loudnorm filter
+// prints at the end of its (otherwise unstructured) log output.
+func parseLoudnormOutput(output []byte) (*LoudnessInfo, error) {
+	start := bytes.LastIndexByte(output, '{')
+	end := bytes.LastIndexByte(output, '}')
+	if start < 0 || end < start {
+		return nil, errors.New("no loudnorm measurements found")
+	}
+
+	// All values are reported as strings.
+	var result struct {
+		InputI  string `json:"input_i"`
+		InputTP string `json:"input_tp"`
+	}
+	if err := json.Unmarshal(output[start:end+1], &result); err != nil {
+		return nil, fmt.Errorf("failed to parse loudnorm output: %w", err)
+	}
+
+	integrated, err := strconv.ParseFloat(result.InputI, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid integrated loudness '%s'", result.InputI)
+	}
+	truePeak, err := strconv.ParseFloat(result.InputTP, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid true peak '%s'", result.InputTP)
+	}
+
+	// Silent files are reported with -inf, there is nothing to normalize.
+	if integrated < -70 {
+		return nil, errors.New("file is silent")
+	}
+
+	return &LoudnessInfo{
+		Integrated: integrated,
+		TruePeak:   truePeak,
+	}, nil
+}
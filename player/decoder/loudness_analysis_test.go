@@ -0,0 +1,44 @@
+package decoder
+
+import (
+	"testing"
+)
+
+const loudnormOutput = `Input #0, mp3, from 'song.mp3':
+  Duration: 00:03:12.35, start: 0.025057, bitrate: 320 kb/s
+[Parsed_loudnorm_0 @ 0x55d5c8a0c000]
+{
+	"input_i" : "-9.12",
+	"input_tp" : "0.35",
+	"input_lra" : "5.30",
+	"input_thresh" : "-19.34",
+	"output_i" : "-23.55",
+	"output_tp" : "-8.73",
+	"output_lra" : "4.90",
+	"output_thresh" : "-33.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "-0.45"
+}
+`
+
+func TestParseLoudnormOutput(t *testing.T) {
+	info, err := parseLoudnormOutput([]byte(loudnormOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Integrated != -9.12 {
+		t.Errorf("Expected integrated loudness -9.12, got %v", info.Integrated)
+	}
+	if info.TruePeak != 0.35 {
+		t.Errorf("Expected true peak 0.35, got %v", info.TruePeak)
+	}
+}
+
+func TestParseLoudnormOutputOfSilence(t *testing.T) {
+	output := `{ "input_i" : "-inf", "input_tp" : "-inf" }`
+
+	if _, err := parseLoudnormOutput([]byte(output)); err == nil {
+		t.Error("Expected an error for a silent file")
+	}
+}
@@ -0,0 +1,563 @@
+package decoder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	mp3ModeStereo        = 0
+	mp3ModeJointStereo   = 1
+	mp3ModeDualChannel   = 2
+	mp3ModeSingleChannel = 3
+)
+
+// Delay of the decoder (synthesis filterbank and overlap) in samples, which encoders
+// like LAME include in the delay stored in the LAME tag.
+const mp3DecoderDelay = 529
+
+// Frames that are decoded before the seek target to fill the bit reservoir and the overlap.
+const mp3SeekWarmupFrames = 10
+
+// Bytes that are searched for the next frame if the stream is damaged.
+const mp3MaxResync = 64 << 10
+
+// mp3Header is the 4 byte header of an MPEG audio frame.
+type mp3Header struct {
+	lsf             bool // MPEG-2 or MPEG-2.5 (low sampling frequencies)
+	sampleRateIndex int  // index into mp3ScalefactorBands
+	sampleRate      int
+	bitrate         int // kbit/s
+	crc             bool
+	mode            int
+	modeExtension   int
+	channels        int
+	frameSize       int // in bytes, including the header
+}
+
+// parseMp3Header parses a Layer III frame header. Free format streams are not supported.
+func parseMp3Header(data []byte) (mp3Header, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+		return mp3Header{}, false
+	}
+
+	version := data[1] >> 3 & 3
+	layer := data[1] >> 1 & 3
+	bitrateIndex := int(data[2] >> 4)
+	rateIndex := int(data[2] >> 2 & 3)
+	if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mp3Header{}, false
+	}
+
+	h := mp3Header{
+		lsf:           version != 3,
+		crc:           data[1]&1 == 0,
+		mode:          int(data[3] >> 6),
+		modeExtension: int(data[3] >> 4 & 3),
+		channels:      2,
+	}
+	if h.mode == mp3ModeSingleChannel {
+		h.channels = 1
+	}
+
+	switch version {
+	case 3: // MPEG-1
+		h.sampleRateIndex = rateIndex
+		h.sampleRate = mp3SampleRates[rateIndex]
+		h.bitrate = mp3Bitrates[bitrateIndex]
+	case 2: // MPEG-2
+		h.sampleRateIndex = 3 + rateIndex
+		h.sampleRate = mp3SampleRates[rateIndex] / 2
+		h.bitrate = mp3BitratesLSF[bitrateIndex]
+	default: // MPEG-2.5
+		h.sampleRateIndex = 6 + rateIndex
+		h.sampleRate = mp3SampleRates[rateIndex] / 4
+		h.bitrate = mp3BitratesLSF[bitrateIndex]
+	}
+
+	h.frameSize = 144000 * h.bitrate / h.sampleRate
+	if h.lsf {
+		h.frameSize /= 2
+	}
+	if data[2]&2 != 0 {
+		h.frameSize++
+	}
+
+	return h, true
+}
+
+func (h *mp3Header) granules() int {
+	if h.lsf {
+		return 1
+	}
+	return 2
+}
+
+func (h *mp3Header) samplesPerFrame() int {
+	return 576 * h.granules()
+}
+
+func (h *mp3Header) sideInfoSize() int {
+	switch {
+	case h.lsf && h.channels == 1:
+		return 9
+	case h.lsf || h.channels == 1:
+		return 17
+	default:
+		return 32
+	}
+}
+
+// dataOffset returns the position of the side information in the frame.
+func (h *mp3Header) dataOffset() int {
+	if h.crc {
+		return 6
+	}
+	return 4
+}
+
+// mp3Stream contains the properties of an MP3 file.
+type mp3Stream struct {
+	sampleRate      int
+	channels        int
+	samplesPerFrame int
+	bitrate         int // of the first frame
+
+	// Number of frames (Xing or VBRI header), 0 = unknown.
+	frames int64
+	// Samples at the start and the end that are not part of the audio (LAME tag).
+	delay   int
+	padding int
+	gapless bool
+
+	// Position of the first audio frame and the end of the audio data (without an ID3v1 tag).
+	firstFrame int64
+	end        int64
+
+	tags map[string]string
+}
+
+// readMp3Stream parses the tags and the first frame of an MP3 file.
+func readMp3Stream(file *os.File) (*mp3Stream, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &mp3Stream{tags: map[string]string{}, end: info.Size()}
+
+	var header [id3HeaderSize]byte
+	if _, err := file.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	if size, ok := id3TagSize(header[:]); ok {
+		tag := make([]byte, min(int64(size), stream.end))
+		if _, err := file.ReadAt(tag, 0); err != nil {
+			return nil, err
+		}
+		parseID3v2(tag, stream.tags)
+		stream.firstFrame = int64(size)
+	}
+
+	if stream.end >= 128 {
+		var id3v1 [128]byte
+		if _, err := file.ReadAt(id3v1[:], stream.end-128); err != nil {
+			return nil, err
+		}
+		if string(id3v1[0:3]) == "TAG" {
+			parseID3v1(id3v1[:], stream.tags)
+			stream.end -= 128
+		}
+	}
+
+	// Find the first frame, which must be followed by another frame of the same stream.
+	data := make([]byte, min(mp3MaxResync, max(stream.end-stream.firstFrame, 0)))
+	if _, err := file.ReadAt(data, stream.firstFrame); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	for i := range data {
+		first, ok := parseMp3Header(data[i:])
+		if !ok {
+			continue
+		}
+		next := i + first.frameSize
+		if next+4 <= len(data) {
+			second, ok := parseMp3Header(data[next:])
+			if !ok || second.sampleRate != first.sampleRate || second.lsf != first.lsf {
+				continue
+			}
+		} else if stream.firstFrame+int64(next) != stream.end {
+			continue
+		}
+
+		stream.sampleRate = first.sampleRate
+		stream.channels = first.channels
+		stream.samplesPerFrame = first.samplesPerFrame()
+		stream.bitrate = first.bitrate
+		stream.firstFrame += int64(i)
+
+		if next <= len(data) && stream.parseInfoFrame(&first, data[i:next]) {
+			// The info frame does not contain audio.
+			stream.firstFrame += int64(first.frameSize)
+		}
+		return stream, nil
+	}
+
+	// Probably another layer or a free format stream, leave it to ffmpeg.
+	return nil, errUnsupported
+}
+
+// parseInfoFrame reads the Xing (or Info) and LAME tags or the VBRI header of the first frame.
+// Returns false if the frame is an audio frame.
+func (stream *mp3Stream) parseInfoFrame(header *mp3Header, frame []byte) bool {
+	if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+		stream.frames = int64(binary.BigEndian.Uint32(frame[36+14:]))
+		return true
+	}
+
+	offset := header.dataOffset() + header.sideInfoSize()
+	if len(frame) < offset+8 {
+		return false
+	}
+	xing := frame[offset:]
+	if id := string(xing[0:4]); id != "Xing" && id != "Info" {
+		return false
+	}
+
+	flags := binary.BigEndian.Uint32(xing[4:8])
+	position := 8
+	if flags&1 != 0 && len(xing) >= position+4 {
+		stream.frames = int64(binary.BigEndian.Uint32(xing[position:]))
+		position += 4
+	}
+	for _, field := range []struct {
+		flag uint32
+		size int
+	}{{2, 4}, {4, 100}, {8, 4}} {
+		// Bytes, table of contents and quality
+		if flags&field.flag != 0 {
+			position += field.size
+		}
+	}
+
+	if len(xing) >= position+24 {
+		lame := xing[position:]
+		if encoder := string(lame[0:4]); encoder == "LAME" || encoder == "Lavf" || encoder == "Lavc" {
+			stream.delay = int(lame[21])<<4 | int(lame[22])>>4
+			stream.padding = int(lame[22]&0x0F)<<8 | int(lame[23])
+			stream.gapless = true
+		}
+	}
+
+	return true
+}
+
+// parseID3v1 reads the title, artist and album of an ID3v1 tag. Tags that are already set are kept.
+func parseID3v1(tag []byte, tags map[string]string) {
+	for key, field := range map[string][2]int{"title": {3, 33}, "artist": {33, 63}, "album": {63, 93}} {
+		value, _, _ := bytes.Cut(tag[field[0]:field[1]], []byte{0})
+		text := strings.TrimSpace(latin1ToString(value))
+		if _, exists := tags[key]; !exists && text != "" {
+			tags[key] = text
+		}
+	}
+}
+
+// totalSamples returns the number of samples of the stream (0 = unknown).
+func (stream *mp3Stream) totalSamples() int64 {
+	if stream.frames == 0 {
+		return 0
+	}
+	total := stream.frames * int64(stream.samplesPerFrame)
+	if stream.gapless {
+		total -= int64(stream.delay + stream.padding)
+	}
+	return max(total, 0)
+}
+
+// skippedSamples returns the number of samples at the start that are not part of the audio.
+func (stream *mp3Stream) skippedSamples() int64 {
+	if !stream.gapless {
+		return 0
+	}
+	return int64(stream.delay + mp3DecoderDelay)
+}
+
+// mp3Source decodes MPEG-1, MPEG-2 and MPEG-2.5 Layer III files.
+type mp3Source struct {
+	file    *os.File
+	reader  *bufio.Reader
+	stream  *mp3Stream
+	decoder mp3Layer3
+
+	frame []byte
+	// Samples of the current frame (by stream channel) and the next one to read from them.
+	pcm    [][]float32
+	output [][]float32
+	length int
+	index  int
+
+	// Position of the next frame in the file and its number.
+	offset      int64
+	frameNumber int64
+	// Offsets of the frames that have been found so far (for seeking).
+	frameOffsets []int64
+
+	// Samples that still have to be dropped (encoder delay) and the samples returned so far.
+	discard  int64
+	returned int64
+}
+
+func openMp3(filepath string) (source, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := readMp3Stream(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	src := &mp3Source{
+		file:   file,
+		stream: stream,
+		pcm:    [][]float32{make([]float32, 1152), make([]float32, 1152)},
+		output: make([][]float32, stream.channels),
+	}
+	if _, err := src.seek(0); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return src, nil
+}
+
+func (src *mp3Source) format() (int, int, int64) {
+	return src.stream.sampleRate, src.stream.channels, src.stream.totalSamples()
+}
+
+func (src *mp3Source) readFrames(buffers [][]float32) (int, error) {
+	frames := 0
+	total := src.stream.totalSamples()
+
+	for frames < len(buffers[0]) {
+		if total > 0 && src.returned >= total {
+			return frames, io.EOF
+		}
+
+		if src.index >= src.length {
+			if err := src.decodeFrame(); err != nil {
+				return frames, err
+			}
+			continue
+		}
+
+		if src.discard > 0 {
+			skipped := int(min(src.discard, int64(src.length-src.index)))
+			src.index += skipped
+			src.discard -= int64(skipped)
+			continue
+		}
+
+		n := min(len(buffers[0])-frames, src.length-src.index)
+		if total > 0 {
+			n = int(min(int64(n), total-src.returned))
+		}
+		for ch, samples := range src.output {
+			copy(buffers[ch][frames:], samples[src.index:src.index+n])
+		}
+		src.index += n
+		src.returned += int64(n)
+		frames += n
+	}
+
+	return frames, nil
+}
+
+// decodeFrame decodes the next frame into the sample buffers.
+func (src *mp3Source) decodeFrame() error {
+	header, err := src.nextFrame()
+	if err != nil {
+		return err
+	}
+
+	frame, err := src.reader.Peek(header.frameSize)
+	if err != nil {
+		// Truncated frame at the end of the file
+		return io.EOF
+	}
+	src.frame = append(src.frame[:0], frame...)
+	src.reader.Discard(header.frameSize)
+
+	if src.frameNumber == int64(len(src.frameOffsets)) {
+		src.frameOffsets = append(src.frameOffsets, src.offset)
+	}
+	src.offset += int64(header.frameSize)
+	src.frameNumber++
+
+	src.length = header.samplesPerFrame()
+	src.index = 0
+
+	if err := src.decoder.decodeFrame(&header, src.frame[header.dataOffset():], src.pcm); err != nil {
+		// Skip damaged frames, the decoder recovers with the next frames.
+		for _, samples := range src.pcm {
+			clear(samples)
+		}
+	}
+
+	// The channels of a frame might differ from the first frame.
+	for ch := range src.output {
+		src.output[ch] = src.pcm[min(ch, header.channels-1)][:src.length]
+	}
+	if header.channels == 2 && len(src.output) == 1 {
+		for i, right := range src.pcm[1][:src.length] {
+			src.pcm[0][i] = (src.pcm[0][i] + right) / 2
+		}
+	}
+
+	return nil
+}
+
+// nextFrame finds the header of the next frame, skipping damaged data.
+func (src *mp3Source) nextFrame() (mp3Header, error) {
+	for skipped := 0; skipped < mp3MaxResync; skipped++ {
+		if src.offset+4 > src.stream.end {
+			return mp3Header{}, io.EOF
+		}
+
+		data, err := src.reader.Peek(4)
+		if err != nil {
+			return mp3Header{}, io.EOF
+		}
+
+		header, ok := parseMp3Header(data)
+		if ok && header.sampleRate == src.stream.sampleRate {
+			return header, nil
+		}
+
+		src.reader.Discard(1)
+		src.offset++
+	}
+
+	return mp3Header{}, io.EOF
+}
+
+func (src *mp3Source) seek(frame int64) (int64, error) {
+	// Position in the decoded samples, which include the encoder delay.
+	target := frame + src.stream.skippedSamples()
+	start := max(target/int64(src.stream.samplesPerFrame)-mp3SeekWarmupFrames, 0)
+
+	offset, number, err := src.frameOffset(start)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := src.file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if src.reader == nil {
+		src.reader = bufio.NewReader(src.file)
+	} else {
+		src.reader.Reset(src.file)
+	}
+
+	src.decoder.reset()
+	src.offset = offset
+	src.frameNumber = number
+	src.length = 0
+	src.index = 0
+
+	position := number*int64(src.stream.samplesPerFrame) - src.stream.skippedSamples()
+	src.discard = max(-position, 0)
+	src.returned = max(position, 0)
+
+	return src.returned, nil
+}
+
+// frameOffset returns the position of the given frame in the file, or of the last frame
+// if the stream is shorter. The headers are scanned from the last known frame.
+func (src *mp3Source) frameOffset(number int64) (int64, int64, error) {
+	if number < int64(len(src.frameOffsets)) {
+		return src.frameOffsets[number], number, nil
+	}
+	if len(src.frameOffsets) == 0 {
+		src.frameOffsets = append(src.frameOffsets, src.stream.firstFrame)
+	}
+
+	last := int64(len(src.frameOffsets)) - 1
+	offset := src.frameOffsets[last]
+	reader := bufio.NewReader(io.NewSectionReader(src.file, offset, src.stream.end-offset))
+
+	for last < number {
+		data, err := reader.Peek(4)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, 0, err
+		}
+
+		header, ok := parseMp3Header(data)
+		if !ok {
+			// Damaged stream, decoding resynchronizes from here.
+			break
+		}
+		if _, err := reader.Discard(header.frameSize); err != nil {
+			break
+		}
+
+		offset += int64(header.frameSize)
+		if offset+4 > src.stream.end {
+			break
+		}
+		src.frameOffsets = append(src.frameOffsets, offset)
+		last++
+	}
+
+	return src.frameOffsets[last], last, nil
+}
+
+func (src *mp3Source) close() error {
+	return src.file.Close()
+}
+
+// mp3Metadata reads the duration and the tags (ID3v2 or ID3v1) of an MP3 file. The duration
+// of files without a Xing or VBRI header is estimated from the bitrate of the first frame.
+func mp3Metadata(filepath string) (*AudioFileMetaData, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stream, err := readMp3Stream(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var duration time.Duration
+	if samples := stream.totalSamples(); samples > 0 {
+		duration = time.Duration(samples) * time.Second / time.Duration(stream.sampleRate)
+	} else if stream.bitrate > 0 {
+		duration = time.Duration(stream.end-stream.firstFrame) * 8 * time.Millisecond / time.Duration(stream.bitrate)
+	}
+	if duration <= 0 {
+		return nil, errors.New("unknown duration")
+	}
+
+	return &AudioFileMetaData{
+		Duration:   duration,
+		Title:      stream.tags["title"],
+		Artist:     stream.tags["artist"],
+		Album:      stream.tags["album"],
+		ReplayGain: parseReplayGain(stream.tags),
+	}, nil
+}
@@ -0,0 +1,921 @@
+package decoder
+
+import (
+	"errors"
+	"math"
+)
+
+// errCorruptFrame is returned for frames that cannot be decoded (e.g. invalid Huffman codes).
+var errCorruptFrame = errors.New("corrupt MP3 frame")
+
+const (
+	mp3BlockNormal = 0
+	mp3BlockStart  = 1
+	mp3BlockShort  = 2
+	mp3BlockStop   = 3
+)
+
+// mp3GranuleInfo is the side information of one channel of a granule.
+type mp3GranuleInfo struct {
+	part23Length     int
+	bigValues        int
+	globalGain       int
+	scalefacCompress int
+	windowSwitching  bool
+	blockType        int
+	mixedBlock       bool
+	tableSelect      [3]int
+	subblockGain     [3]int
+	region0Count     int
+	region1Count     int
+	preflag          bool
+	scalefacScale    int
+	count1Table      int
+}
+
+func (g *mp3GranuleInfo) shortBlocks() bool {
+	return g.windowSwitching && g.blockType == mp3BlockShort
+}
+
+type mp3SideInfo struct {
+	mainDataBegin int
+	scfsi         [2][4]bool
+	granules      [2][2]mp3GranuleInfo // by granule and channel
+}
+
+// mp3Scalefactors of one channel. The maximum values mark illegal intensity stereo positions (MPEG-2).
+type mp3Scalefactors struct {
+	long     [22]int
+	short    [13][3]int
+	longMax  [22]int
+	shortMax [13][3]int
+}
+
+// mp3Layer3 decodes the audio data of Layer III frames. It keeps the state that carries over
+// from one frame to the next (bit reservoir, overlap of the transforms, synthesis filter).
+type mp3Layer3 struct {
+	reservoir []byte
+
+	scalefactors [2]mp3Scalefactors
+	// Requantized spectral values and the number of lines up to the last non-zero value.
+	spectrum [2][576]float32
+	nonZero  [2]int
+	reorder  [576]float32
+
+	overlap   [2][32][18]float32
+	synthesis [2]mp3Synthesis
+}
+
+// mp3Synthesis is the state of the polyphase filterbank of one channel.
+type mp3Synthesis struct {
+	v      [1024]float32
+	offset int
+}
+
+// reset clears the state, e.g. after seeking.
+func (l3 *mp3Layer3) reset() {
+	l3.reservoir = l3.reservoir[:0]
+	l3.overlap = [2][32][18]float32{}
+	l3.synthesis = [2]mp3Synthesis{}
+}
+
+// Maximum size of the bit reservoir (main_data_begin has 9 bits).
+const mp3MaxReservoir = 511
+
+// decodeFrame decodes the frame data following the header (and the CRC) into pcm
+// (one buffer per channel of the frame, 576 samples per granule).
+func (l3 *mp3Layer3) decodeFrame(header *mp3Header, data []byte, pcm [][]float32) error {
+	sideInfoSize := header.sideInfoSize()
+	if len(data) < sideInfoSize {
+		return errCorruptFrame
+	}
+
+	var side mp3SideInfo
+	header.readSideInfo(&mp3Bits{data: data[:sideInfoSize]}, &side)
+	mainData := data[sideInfoSize:]
+
+	// The main data of this frame might start in previous frames.
+	available := len(l3.reservoir)
+	l3.reservoir = append(l3.reservoir, mainData...)
+	defer func() {
+		if excess := len(l3.reservoir) - mp3MaxReservoir; excess > 0 {
+			l3.reservoir = l3.reservoir[:copy(l3.reservoir, l3.reservoir[excess:])]
+		}
+	}()
+
+	if side.mainDataBegin > available {
+		// Missing data (start of the stream or after seeking).
+		for _, samples := range pcm {
+			clear(samples[:header.granules()*576])
+		}
+		return nil
+	}
+
+	bits := &mp3Bits{data: l3.reservoir[available-side.mainDataBegin:]}
+	bands := &mp3ScalefactorBands[header.sampleRateIndex]
+
+	for gr := range header.granules() {
+		for ch := range header.channels {
+			info := &side.granules[gr][ch]
+			start := bits.pos
+
+			if header.lsf {
+				l3.readLSFScalefactors(bits, info, header, ch)
+			} else {
+				l3.readScalefactors(bits, info, &side.scfsi[ch], gr, ch)
+			}
+
+			if err := l3.readSpectrum(bits, info, bands, ch, start+info.part23Length); err != nil {
+				return err
+			}
+			bits.pos = start + info.part23Length
+
+			l3.requantize(info, bands, ch)
+		}
+
+		if header.mode == mp3ModeJointStereo && header.channels == 2 {
+			l3.jointStereo(header, &side.granules[gr][1], bands)
+		}
+
+		for ch := range header.channels {
+			info := &side.granules[gr][ch]
+			l3.hybridSynthesis(info, bands, ch, pcm[ch][gr*576:(gr+1)*576])
+		}
+	}
+
+	return nil
+}
+
+// readSideInfo parses the side information following the header.
+func (h *mp3Header) readSideInfo(bits *mp3Bits, side *mp3SideInfo) {
+	if h.lsf {
+		side.mainDataBegin = bits.read(8)
+		bits.read(h.channels) // private bits
+	} else {
+		side.mainDataBegin = bits.read(9)
+		if h.channels == 1 {
+			bits.read(5) // private bits
+		} else {
+			bits.read(3)
+		}
+		for ch := range h.channels {
+			for band := range 4 {
+				side.scfsi[ch][band] = bits.read(1) == 1
+			}
+		}
+	}
+
+	for gr := range h.granules() {
+		for ch := range h.channels {
+			info := &side.granules[gr][ch]
+			info.part23Length = bits.read(12)
+			info.bigValues = min(bits.read(9), 288)
+			info.globalGain = bits.read(8)
+			if h.lsf {
+				info.scalefacCompress = bits.read(9)
+			} else {
+				info.scalefacCompress = bits.read(4)
+			}
+			info.windowSwitching = bits.read(1) == 1
+
+			if info.windowSwitching {
+				info.blockType = bits.read(2)
+				info.mixedBlock = bits.read(1) == 1
+				for region := range 2 {
+					info.tableSelect[region] = bits.read(5)
+				}
+				for window := range 3 {
+					info.subblockGain[window] = bits.read(3)
+				}
+				// The regions are implicit, region 2 is empty.
+				info.region0Count = 7
+				if info.blockType == mp3BlockShort && !info.mixedBlock {
+					info.region0Count = 8
+				}
+				info.region1Count = 20 - info.region0Count
+			} else {
+				info.blockType = mp3BlockNormal
+				info.mixedBlock = false
+				for region := range 3 {
+					info.tableSelect[region] = bits.read(5)
+				}
+				info.region0Count = bits.read(4)
+				info.region1Count = bits.read(3)
+			}
+
+			if !h.lsf {
+				info.preflag = bits.read(1) == 1
+			}
+			info.scalefacScale = bits.read(1)
+			info.count1Table = bits.read(1)
+		}
+	}
+}
+
+// readScalefactors reads the scalefactors of MPEG-1 granules.
+func (l3 *mp3Layer3) readScalefactors(bits *mp3Bits, info *mp3GranuleInfo, scfsi *[4]bool, gr, ch int) {
+	sf := &l3.scalefactors[ch]
+	slen1, slen2 := mp3Slen1[info.scalefacCompress], mp3Slen2[info.scalefacCompress]
+
+	if info.shortBlocks() {
+		firstShort := 0
+		if info.mixedBlock {
+			for band := range 8 {
+				sf.long[band] = bits.read(slen1)
+			}
+			firstShort = 3
+		}
+		for band := firstShort; band < 12; band++ {
+			slen := slen1
+			if band >= 6 {
+				slen = slen2
+			}
+			for window := range 3 {
+				sf.short[band][window] = bits.read(slen)
+			}
+		}
+		sf.short[12] = [3]int{}
+		return
+	}
+
+	// Groups of bands that can share the scalefactors of the first granule (scfsi).
+	groups := [5]int{0, 6, 11, 16, 21}
+	for group := range 4 {
+		if gr == 1 && scfsi[group] {
+			continue
+		}
+		slen := slen1
+		if group >= 2 {
+			slen = slen2
+		}
+		for band := groups[group]; band < groups[group+1]; band++ {
+			sf.long[band] = bits.read(slen)
+		}
+	}
+	sf.long[21] = 0
+}
+
+// readLSFScalefactors reads the scalefactors of MPEG-2 and MPEG-2.5 granules.
+func (l3 *mp3Layer3) readLSFScalefactors(bits *mp3Bits, info *mp3GranuleInfo, header *mp3Header, ch int) {
+	var slen [4]int
+	var table int
+	compress := info.scalefacCompress
+
+	intensityRight := ch == 1 && header.mode == mp3ModeJointStereo && header.modeExtension&1 != 0
+	info.preflag = false
+
+	switch {
+	case !intensityRight && compress < 400:
+		slen = [4]int{(compress >> 4) / 5, (compress >> 4) % 5, (compress & 15) >> 2, compress & 3}
+		table = 0
+	case !intensityRight && compress < 500:
+		compress -= 400
+		slen = [4]int{(compress >> 2) / 5, (compress >> 2) % 5, compress & 3, 0}
+		table = 1
+	case !intensityRight:
+		compress -= 500
+		slen = [4]int{compress / 3, compress % 3, 0, 0}
+		table = 2
+		info.preflag = true
+	default:
+		compress >>= 1
+		switch {
+		case compress < 180:
+			slen = [4]int{compress / 36, (compress % 36) / 6, (compress % 36) % 6, 0}
+			table = 3
+		case compress < 244:
+			compress -= 180
+			slen = [4]int{(compress % 64) >> 4, (compress % 16) >> 2, compress % 4, 0}
+			table = 4
+		default:
+			compress -= 244
+			slen = [4]int{compress / 3, compress % 3, 0, 0}
+			table = 5
+		}
+	}
+
+	blockIndex := 0
+	if info.shortBlocks() {
+		blockIndex = 1
+		if info.mixedBlock {
+			blockIndex = 2
+		}
+	}
+
+	// Scalefactors in the order they are assigned to the bands.
+	var values, maxValues [39]int
+	n := 0
+	for i, count := range mp3LSFScalefactorCounts[table][blockIndex] {
+		for range count {
+			values[n] = bits.read(slen[i])
+			maxValues[n] = 1<<slen[i] - 1
+			n++
+		}
+	}
+
+	sf := &l3.scalefactors[ch]
+	*sf = mp3Scalefactors{}
+	i := 0
+
+	if !info.shortBlocks() {
+		for band := range 21 {
+			sf.long[band], sf.longMax[band] = values[i], maxValues[i]
+			i++
+		}
+		return
+	}
+
+	firstShort := 0
+	if info.mixedBlock {
+		for band := range 6 {
+			sf.long[band], sf.longMax[band] = values[i], maxValues[i]
+			i++
+		}
+		firstShort = 3
+	}
+	for band := firstShort; band < 12 && i < n; band++ {
+		for window := range 3 {
+			sf.short[band][window], sf.shortMax[band][window] = values[i], maxValues[i]
+			i++
+		}
+	}
+}
+
+// readSpectrum decodes the Huffman coded spectral values of a channel into the spectrum
+// (as integers converted to float, see requantize). The data of the channel ends at the given bit position.
+func (l3 *mp3Layer3) readSpectrum(bits *mp3Bits, info *mp3GranuleInfo, bands *mp3Bands, ch, end int) error {
+	spectrum := &l3.spectrum[ch]
+
+	region1Start, region2Start := 36, 576
+	if !info.shortBlocks() {
+		region1Start = bands.long[min(info.region0Count+1, 22)]
+		region2Start = bands.long[min(info.region0Count+info.region1Count+2, 22)]
+	}
+
+	bigValues := info.bigValues * 2
+	i := 0
+	for ; i < bigValues; i += 2 {
+		region := 0
+		if i >= region2Start {
+			region = 2
+		} else if i >= region1Start {
+			region = 1
+		}
+
+		x, y, err := mp3Tables[info.tableSelect[region]].decodePair(bits)
+		if err != nil {
+			return err
+		}
+		spectrum[i], spectrum[i+1] = float32(x), float32(y)
+	}
+
+	// Quadruples of values -1, 0 or 1 up to the end of the data.
+	for i+4 <= 576 && bits.pos < end {
+		var quad int
+		if info.count1Table == 1 {
+			quad = 15 - bits.read(4)
+		} else {
+			value, err := mp3Count1Tree.decode(bits)
+			if err != nil {
+				return err
+			}
+			quad = value
+		}
+
+		var values [4]float32
+		for k := range 4 {
+			if quad&(8>>k) != 0 {
+				values[k] = 1
+				if bits.read(1) == 1 {
+					values[k] = -1
+				}
+			}
+		}
+
+		if bits.pos > end {
+			// The last quadruple has been read beyond the end of the data.
+			break
+		}
+		copy(spectrum[i:i+4], values[:])
+		i += 4
+	}
+
+	l3.nonZero[ch] = i
+	clear(spectrum[i:])
+	return nil
+}
+
+// Precomputed |x|^(4/3) of the possible Huffman values (15 + 13 linbits).
+var mp3Pow43 = func() []float32 {
+	table := make([]float32, 8207)
+	for i := range table {
+		table[i] = float32(math.Pow(float64(i), 4.0/3))
+	}
+	return table
+}()
+
+// requantize converts the Huffman values of a channel to spectral values.
+func (l3 *mp3Layer3) requantize(info *mp3GranuleInfo, bands *mp3Bands, ch int) {
+	spectrum := &l3.spectrum[ch]
+	sf := &l3.scalefactors[ch]
+	multiplier := 0.5 * float64(1+info.scalefacScale)
+	globalGain := 0.25 * float64(info.globalGain-210)
+
+	scale := func(from, to int, exponent float64) {
+		gain := float32(math.Exp2(exponent))
+		for i := from; i < min(to, l3.nonZero[ch]); i++ {
+			value := spectrum[i]
+			if value < 0 {
+				spectrum[i] = -mp3Pow43[int(-value)] * gain
+			} else {
+				spectrum[i] = mp3Pow43[int(value)] * gain
+			}
+		}
+	}
+
+	longBands := 22
+	firstShort := 13
+	if info.shortBlocks() {
+		longBands, firstShort = 0, 0
+		if info.mixedBlock {
+			firstShort = 3
+			for longBands < 22 && bands.long[longBands] < 3*bands.short[firstShort] {
+				longBands++
+			}
+		}
+	}
+
+	for band := range longBands {
+		pretab := 0
+		if info.preflag {
+			pretab = mp3Pretab[band]
+		}
+		exponent := globalGain - multiplier*float64(sf.long[band]+pretab)
+		scale(bands.long[band], bands.long[band+1], exponent)
+	}
+
+	for band := firstShort; band < 13; band++ {
+		width := bands.short[band+1] - bands.short[band]
+		start := 3 * bands.short[band]
+		for window := range 3 {
+			exponent := globalGain - 2*float64(info.subblockGain[window]) - multiplier*float64(sf.short[band][window])
+			scale(start+window*width, start+(window+1)*width, exponent)
+		}
+	}
+}
+
+// jointStereo applies the intensity stereo and middle/side stereo processing of a granule.
+// The side information of the right channel determines the intensity stereo positions.
+func (l3 *mp3Layer3) jointStereo(header *mp3Header, right *mp3GranuleInfo, bands *mp3Bands) {
+	left, rightSpectrum := &l3.spectrum[0], &l3.spectrum[1]
+
+	// Lines processed by intensity stereo (all lines above are as well).
+	var intensity [576]bool
+	if header.modeExtension&1 != 0 {
+		l3.intensityStereo(header, right, bands, &intensity)
+	}
+
+	if header.modeExtension&2 != 0 {
+		end := max(l3.nonZero[0], l3.nonZero[1])
+		for i := range end {
+			if intensity[i] {
+				continue
+			}
+			middle, side := left[i], rightSpectrum[i]
+			left[i] = (middle + side) * math.Sqrt2 / 2
+			rightSpectrum[i] = (middle - side) * math.Sqrt2 / 2
+		}
+		l3.nonZero[0], l3.nonZero[1] = end, end
+	}
+}
+
+// intensityStereo reconstructs the right channel from the left channel in the bands above
+// the last non-zero value of the right channel.
+func (l3 *mp3Layer3) intensityStereo(header *mp3Header, right *mp3GranuleInfo, bands *mp3Bands, processed *[576]bool) {
+	left, rightSpectrum := &l3.spectrum[0], &l3.spectrum[1]
+	sf := &l3.scalefactors[1]
+
+	apply := func(from, to, position, maxPosition int) {
+		kl, kr, ok := header.intensityRatio(position, maxPosition, right.scalefacCompress)
+		if !ok {
+			return
+		}
+		for i := from; i < to; i++ {
+			value := left[i]
+			left[i] = value * kl
+			rightSpectrum[i] = value * kr
+			processed[i] = true
+		}
+		l3.nonZero[0] = max(l3.nonZero[0], to)
+		l3.nonZero[1] = max(l3.nonZero[1], to)
+	}
+
+	// lastNonZero returns the index of the last non-zero value of the right channel in the range (or -1).
+	lastNonZero := func(from, to, step int) int {
+		for i := to - step; i >= from; i -= step {
+			if rightSpectrum[i] != 0 {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if !right.shortBlocks() {
+		last := lastNonZero(0, l3.nonZero[1], 1)
+		for band := range 22 {
+			if bands.long[band] <= last {
+				continue
+			}
+			// The last band uses the position of the band before.
+			position := min(band, 20)
+			apply(bands.long[band], bands.long[band+1], sf.long[position], sf.longMax[position])
+		}
+		return
+	}
+
+	firstShort := 0
+	if right.mixedBlock {
+		firstShort = 3
+	}
+
+	for window := range 3 {
+		// Highest band of the window that contains a non-zero value.
+		lastBand := firstShort - 1
+		for band := 12; band >= firstShort; band-- {
+			width := bands.short[band+1] - bands.short[band]
+			start := 3*bands.short[band] + window*width
+			if lastNonZero(start, start+width, 1) >= 0 {
+				lastBand = band
+				break
+			}
+		}
+
+		for band := max(lastBand+1, firstShort); band < 13; band++ {
+			width := bands.short[band+1] - bands.short[band]
+			start := 3*bands.short[band] + window*width
+			position := min(band, 11)
+			apply(start, start+width, sf.short[position][window], sf.shortMax[position][window])
+		}
+	}
+}
+
+// intensityRatio returns the factors of the left and right channel for an intensity stereo
+// position, or false if the position is illegal (no intensity stereo in that band).
+func (h *mp3Header) intensityRatio(position, maxPosition, scalefacCompress int) (float32, float32, bool) {
+	if !h.lsf {
+		if position >= 7 {
+			return 0, 0, false
+		}
+		angle := float64(position) * math.Pi / 12
+		sin, cos := math.Sin(angle), math.Cos(angle)
+		return float32(sin / (sin + cos)), float32(cos / (sin + cos)), true
+	}
+
+	if position == maxPosition {
+		return 0, 0, false
+	}
+
+	base := math.Pow(2, -0.25)
+	if scalefacCompress&1 != 0 {
+		base = math.Sqrt2 / 2
+	}
+
+	switch {
+	case position == 0:
+		return 1, 1, true
+	case position%2 == 1:
+		return float32(math.Pow(base, float64(position+1)/2)), 1, true
+	default:
+		return 1, float32(math.Pow(base, float64(position)/2)), true
+	}
+}
+
+// hybridSynthesis converts the spectrum of a channel to 576 samples (reordering, alias reduction,
+// IMDCT and polyphase filterbank).
+func (l3 *mp3Layer3) hybridSynthesis(info *mp3GranuleInfo, bands *mp3Bands, ch int, out []float32) {
+	spectrum := &l3.spectrum[ch]
+
+	longSubbands := 32
+	if info.shortBlocks() {
+		longSubbands = 0
+		if info.mixedBlock {
+			longSubbands = 2
+		}
+		l3.reorderShortBlocks(info, bands, ch)
+	}
+
+	// Alias reduction between the long subbands
+	for sb := 1; sb < longSubbands; sb++ {
+		for i := range 8 {
+			a, b := spectrum[18*sb-1-i], spectrum[18*sb+i]
+			spectrum[18*sb-1-i] = a*mp3AliasCs[i] - b*mp3AliasCa[i]
+			spectrum[18*sb+i] = b*mp3AliasCs[i] + a*mp3AliasCa[i]
+		}
+	}
+
+	var samples [18][32]float32
+	var block [36]float32
+
+	for sb := range 32 {
+		input := spectrum[18*sb : 18*sb+18]
+
+		if sb < longSubbands {
+			blockType := info.blockType
+			if info.shortBlocks() {
+				// Lower subbands of mixed blocks
+				blockType = mp3BlockNormal
+			}
+			imdct36(input, &block, &mp3Windows[blockType])
+		} else {
+			imdct12(input, &block)
+		}
+
+		overlap := &l3.overlap[ch][sb]
+		for i := range 18 {
+			sample := block[i] + overlap[i]
+			overlap[i] = block[18+i]
+			if sb%2 == 1 && i%2 == 1 {
+				// Frequency inversion of the odd subbands
+				sample = -sample
+			}
+			samples[i][sb] = sample
+		}
+	}
+
+	for i := range 18 {
+		l3.synthesis[ch].filter(&samples[i], out[32*i:32*i+32])
+	}
+}
+
+// reorderShortBlocks moves the values of short blocks from the band/window order of the bitstream
+// to subband/window order (18 values per subband, 6 per window).
+func (l3 *mp3Layer3) reorderShortBlocks(info *mp3GranuleInfo, bands *mp3Bands, ch int) {
+	spectrum := &l3.spectrum[ch]
+
+	firstBand := 0
+	if info.mixedBlock {
+		firstBand = 3
+	}
+	start := 3 * bands.short[firstBand]
+
+	for band := firstBand; band < 13; band++ {
+		width := bands.short[band+1] - bands.short[band]
+		for window := range 3 {
+			for j := range width {
+				line := bands.short[band] + j
+				from := 3*bands.short[band] + window*width + j
+				l3.reorder[(line/6)*18+window*6+line%6] = spectrum[from]
+			}
+		}
+	}
+
+	// Mixed blocks keep the long values of the lower subbands.
+	copy(spectrum[start:], l3.reorder[start:])
+}
+
+var (
+	mp3AliasCs, mp3AliasCa [8]float32
+
+	// Windows of the long blocks by block type (the short block window is in imdct12).
+	mp3Windows [4][36]float32
+
+	mp3Imdct36     [36][18]float32
+	mp3Imdct12     [12][6]float32
+	mp3ShortWindow [12]float32
+
+	// Matrix of the polyphase filterbank and the complete synthesis window.
+	mp3SynthesisMatrix [64][32]float32
+	mp3SynthesisWindow [512]float32
+
+	// Huffman decoders by table number (with linbits).
+	mp3Tables     [32]mp3Table
+	mp3Count1Tree huffmanTree
+)
+
+func init() {
+	for i, c := range mp3AliasCoefficients {
+		norm := math.Sqrt(1 + c*c)
+		mp3AliasCs[i] = float32(1 / norm)
+		mp3AliasCa[i] = float32(c / norm)
+	}
+
+	for i := range 36 {
+		longWindow := float32(math.Sin(math.Pi / 36 * (float64(i) + 0.5)))
+		mp3Windows[mp3BlockNormal][i] = longWindow
+
+		switch {
+		case i < 18:
+			mp3Windows[mp3BlockStart][i] = longWindow
+		case i < 24:
+			mp3Windows[mp3BlockStart][i] = 1
+		case i < 30:
+			mp3Windows[mp3BlockStart][i] = float32(math.Sin(math.Pi / 12 * (float64(i-18) + 0.5)))
+		}
+
+		switch {
+		case i < 6:
+		case i < 12:
+			mp3Windows[mp3BlockStop][i] = float32(math.Sin(math.Pi / 12 * (float64(i-6) + 0.5)))
+		case i < 18:
+			mp3Windows[mp3BlockStop][i] = 1
+		default:
+			mp3Windows[mp3BlockStop][i] = longWindow
+		}
+
+		for k := range 18 {
+			mp3Imdct36[i][k] = float32(math.Cos(math.Pi / 72 * float64((2*i+19)*(2*k+1))))
+		}
+	}
+
+	for i := range 12 {
+		mp3ShortWindow[i] = float32(math.Sin(math.Pi / 12 * (float64(i) + 0.5)))
+		for k := range 6 {
+			mp3Imdct12[i][k] = float32(math.Cos(math.Pi / 24 * float64((2*i+7)*(2*k+1))))
+		}
+	}
+
+	for i := range 64 {
+		for k := range 32 {
+			mp3SynthesisMatrix[i][k] = float32(math.Cos(float64((16+i)*(2*k+1)) * math.Pi / 64))
+		}
+	}
+
+	// The prototype filter is symmetric, the window flips its sign every 64 values.
+	for i := range 512 {
+		var value int32
+		if i <= 256 {
+			value = mp3SynthesisWindowHalf[i]
+		} else {
+			mirrored := 512 - i
+			value = mp3SynthesisWindowHalf[mirrored]
+			if (i/64)%2 != (mirrored/64)%2 {
+				value = -value
+			}
+		}
+		mp3SynthesisWindow[i] = float32(value) / 65536
+	}
+
+	linbits := [32]int{16: 1, 17: 2, 18: 3, 19: 4, 20: 6, 21: 8, 22: 10, 23: 13, 24: 4, 25: 5, 26: 6, 27: 7, 28: 8, 29: 9, 30: 11, 31: 13}
+	trees := map[int]huffmanTree{}
+	for number, code := range mp3HuffmanCodes {
+		trees[number] = newHuffmanTree(code.codes, code.lengths)
+	}
+	for number := range mp3Tables {
+		base := number
+		switch {
+		case number >= 24:
+			base = 24
+		case number >= 16:
+			base = 16
+		}
+		if code, ok := mp3HuffmanCodes[base]; ok {
+			mp3Tables[number] = mp3Table{tree: trees[base], size: code.size, linbits: linbits[number]}
+		}
+	}
+
+	mp3Count1Tree = newHuffmanTree(mp3Count1Code.codes, mp3Count1Code.lengths)
+}
+
+// imdct36 transforms the 18 values of a long block subband and applies the window.
+func imdct36(input []float32, output *[36]float32, window *[36]float32) {
+	for i := range 36 {
+		var sum float32
+		for k, value := range input[:18] {
+			sum += value * mp3Imdct36[i][k]
+		}
+		output[i] = sum * window[i]
+	}
+}
+
+// imdct12 transforms the three short blocks of a subband and overlaps them.
+func imdct12(input []float32, output *[36]float32) {
+	*output = [36]float32{}
+	for window := range 3 {
+		values := input[6*window : 6*window+6]
+		for i := range 12 {
+			var sum float32
+			for k, value := range values {
+				sum += value * mp3Imdct12[i][k]
+			}
+			output[6+6*window+i] += sum * mp3ShortWindow[i]
+		}
+	}
+}
+
+// filter converts one sample of each of the 32 subbands into 32 output samples.
+func (s *mp3Synthesis) filter(subbands *[32]float32, out []float32) {
+	s.offset = (s.offset - 64) & 1023
+
+	for i := range 64 {
+		var sum float32
+		for k, value := range subbands {
+			sum += mp3SynthesisMatrix[i][k] * value
+		}
+		s.v[(s.offset+i)&1023] = sum
+	}
+
+	for j := range 32 {
+		var sum float32
+		for i := range 8 {
+			// U[64i+j] = V[128i+j], U[64i+32+j] = V[128i+96+j]
+			sum += s.v[(s.offset+128*i+j)&1023] * mp3SynthesisWindow[64*i+j]
+			sum += s.v[(s.offset+128*i+96+j)&1023] * mp3SynthesisWindow[64*i+32+j]
+		}
+		out[j] = sum
+	}
+}
+
+// mp3Table is a big value Huffman table.
+type mp3Table struct {
+	tree    huffmanTree
+	size    int
+	linbits int
+}
+
+// decodePair reads the next pair of (signed) spectral values.
+func (t *mp3Table) decodePair(bits *mp3Bits) (int, int, error) {
+	if t.tree == nil {
+		// Table 0 (or unused tables) code all values as zero.
+		return 0, 0, nil
+	}
+
+	value, err := t.tree.decode(bits)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	x, y := value/t.size, value%t.size
+	return t.readValue(bits, x), t.readValue(bits, y), nil
+}
+
+func (t *mp3Table) readValue(bits *mp3Bits, value int) int {
+	if value == 15 && t.linbits > 0 {
+		value += bits.read(t.linbits)
+	}
+	if value != 0 && bits.read(1) == 1 {
+		return -value
+	}
+	return value
+}
+
+// huffmanTree is a binary tree of the nodes of a Huffman code. Each node has two children,
+// leaves are stored as -(value+1).
+type huffmanTree [][2]int32
+
+func newHuffmanTree(codes []uint16, lengths []uint8) huffmanTree {
+	tree := huffmanTree{{}}
+
+	for value, code := range codes {
+		node := 0
+		for i := int(lengths[value]) - 1; i >= 0; i-- {
+			bit := code >> i & 1
+			if i == 0 {
+				tree[node][bit] = int32(-(value + 1))
+				break
+			}
+			if tree[node][bit] == 0 {
+				tree = append(tree, [2]int32{})
+				tree[node][bit] = int32(len(tree) - 1)
+			}
+			node = int(tree[node][bit])
+		}
+	}
+
+	return tree
+}
+
+func (tree huffmanTree) decode(bits *mp3Bits) (int, error) {
+	node := int32(0)
+	for {
+		next := tree[node][bits.read(1)]
+		switch {
+		case next < 0:
+			return int(-next - 1), nil
+		case next == 0:
+			return 0, errCorruptFrame
+		}
+		node = next
+		if bits.pos > 8*len(bits.data) {
+			return 0, errCorruptFrame
+		}
+	}
+}
+
+// mp3Bits reads big endian bit fields from a byte slice. Reading beyond the end returns zeros.
+type mp3Bits struct {
+	data []byte
+	pos  int // in bits
+}
+
+func (b *mp3Bits) read(count int) int {
+	value := 0
+	for range count {
+		bit := 0
+		if byteIndex := b.pos >> 3; byteIndex < len(b.data) {
+			bit = int(b.data[byteIndex]>>(7-b.pos&7)) & 1
+		}
+		value = value<<1 | bit
+		b.pos++
+	}
+	return value
+}
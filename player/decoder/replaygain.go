@@ -0,0 +1,92 @@
+package decoder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ReplayGainInfo holds the ReplayGain tags of a file.
+type ReplayGainInfo struct {
+	// Gains in dB relative to the ReplayGain reference level (-18 LUFS).
+	TrackGain float64
+	AlbumGain float64
+
+	// Peaks as linear sample values (1 = full scale). 0 if unknown.
+	TrackPeak float64
+	AlbumPeak float64
+
+	HasAlbumGain bool
+}
+
+// The reference level of R128 gain tags (-23 LUFS) is 5 dB below the one of ReplayGain.
+const r128ToReplayGainOffset = 5
+
+// parseReplayGain reads the ReplayGain information from the (lower case) tags of a file.
+// Returns nil if the file has no gain tags.
+func parseReplayGain(tags map[string]string) *ReplayGainInfo {
+	trackGain, hasTrackGain := parseGain(tags["replaygain_track_gain"])
+	albumGain, hasAlbumGain := parseGain(tags["replaygain_album_gain"])
+
+	// Opus files use R128 gain tags instead.
+	if !hasTrackGain {
+		trackGain, hasTrackGain = parseR128Gain(tags["r128_track_gain"])
+	}
+	if !hasAlbumGain {
+		albumGain, hasAlbumGain = parseR128Gain(tags["r128_album_gain"])
+	}
+
+	if !hasTrackGain && !hasAlbumGain {
+		return nil
+	}
+
+	info := ReplayGainInfo{
+		TrackGain:    trackGain,
+		AlbumGain:    albumGain,
+		TrackPeak:    parsePeak(tags["replaygain_track_peak"]),
+		AlbumPeak:    parsePeak(tags["replaygain_album_peak"]),
+		HasAlbumGain: hasAlbumGain,
+	}
+
+	if !hasTrackGain {
+		// Better than nothing.
+		info.TrackGain = info.AlbumGain
+		info.TrackPeak = info.AlbumPeak
+	}
+
+	return &info
+}
+
+// parseGain parses values like "-7.89 dB".
+func parseGain(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(value, "dB"), "db"))
+
+	if value == "" {
+		return 0, false
+	}
+
+	gain, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return gain, true
+}
+
+// parseR128Gain parses R128 gain tags (Q7.8 fixed point numbers relative to -23 LUFS).
+func parseR128Gain(value string) (float64, bool) {
+	gain, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+
+	return float64(gain)/256 + r128ToReplayGainOffset, true
+}
+
+func parsePeak(value string) float64 {
+	peak, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || peak < 0 {
+		return 0
+	}
+	return peak
+}
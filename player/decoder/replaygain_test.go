@@ -0,0 +1,37 @@
+package decoder
+
+import (
+	"testing"
+)
+
+func TestParseReplayGain(t *testing.T) {
+	info := parseReplayGain(map[string]string{
+		"replaygain_track_gain": "-7.89 dB",
+		"replaygain_track_peak": "0.988525",
+		"replaygain_album_gain": "+1.5 dB",
+	})
+
+	if info == nil {
+		t.Fatal("Expected ReplayGain info")
+	}
+	if info.TrackGain != -7.89 || info.TrackPeak != 0.988525 {
+		t.Errorf("Unexpected track values: %+v", info)
+	}
+	if !info.HasAlbumGain || info.AlbumGain != 1.5 || info.AlbumPeak != 0 {
+		t.Errorf("Unexpected album values: %+v", info)
+	}
+}
+
+func TestParseR128Gain(t *testing.T) {
+	info := parseReplayGain(map[string]string{"r128_track_gain": "-512"})
+
+	if info == nil || info.TrackGain != 3 {
+		t.Errorf("Expected a track gain of 3 dB, got %+v", info)
+	}
+}
+
+func TestParseReplayGainWithoutTags(t *testing.T) {
+	if info := parseReplayGain(map[string]string{"title": "Song"}); info != nil {
+		t.Errorf("Expected no ReplayGain info, got %+v", info)
+	}
+}
@@ -0,0 +1,381 @@
+package decoder
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavHeader contains the relevant parts of a RIFF/WAVE header.
+type wavHeader struct {
+	format        uint16
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+	// Position and size of the sample data in bytes (size 0 = until the end of the file).
+	dataOffset int64
+	dataSize   int64
+	// Tags of the LIST INFO chunk (if it precedes the data).
+	tags map[string]string
+}
+
+func (h *wavHeader) frameSize() int {
+	return h.channels * h.bitsPerSample / 8
+}
+
+// readWavHeader parses the chunks up to the start of the sample data.
+func readWavHeader(r io.Reader) (*wavHeader, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	header := &wavHeader{tags: map[string]string{}}
+	offset := int64(len(riff))
+
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("no data chunk: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		offset += int64(len(chunk))
+
+		if id == "data" {
+			if header.channels == 0 {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			header.dataOffset = offset
+			if size != 0xFFFFFFFF {
+				// Otherwise the file is being streamed and the size is unknown.
+				header.dataSize = size
+			}
+			return header, nil
+		}
+
+		// Chunks are padded to an even size. The size is not trusted, only small chunks that
+		// we need are read into memory.
+		padded := size + size%2
+		if (id != "fmt " && !isWavTagChunk(id)) || size > maxWavTagChunkSize {
+			if _, err := io.CopyN(io.Discard, r, padded); err != nil {
+				return nil, fmt.Errorf("no data chunk: %w", err)
+			}
+			offset += padded
+			continue
+		}
+
+		data := make([]byte, padded)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		offset += padded
+
+		if id == "fmt " {
+			if err := header.parseFormat(data); err != nil {
+				return nil, err
+			}
+		}
+		header.parseTags(id, data[:size])
+	}
+}
+
+// parseTags reads the tags of LIST INFO and ID3 chunks.
+func (h *wavHeader) parseTags(id string, data []byte) {
+	switch id {
+	case "LIST":
+		if len(data) >= 4 && string(data[0:4]) == "INFO" {
+			parseWavInfo(data[4:], h.tags)
+		}
+	case "id3 ", "ID3 ":
+		// Written by most tag editors, this is also where ReplayGain tags end up.
+		parseID3v2(data, h.tags)
+	}
+}
+
+func isWavTagChunk(id string) bool {
+	return id == "LIST" || id == "id3 " || id == "ID3 "
+}
+
+// Chunks larger than this are skipped without reading them.
+const maxWavTagChunkSize = 16 << 20
+
+// readTrailingTags reads the tags of the chunks after the sample data, which is where
+// most tag editors append them.
+func (h *wavHeader) readTrailingTags(file *os.File) error {
+	if h.dataSize == 0 {
+		// Streamed file, there is nothing after the data.
+		return nil
+	}
+
+	offset := h.dataOffset + h.dataSize + h.dataSize%2
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(reader, chunk[:]); err != nil {
+			// End of the file (or a truncated chunk).
+			return nil
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		padded := size + size%2
+
+		if !isWavTagChunk(id) || size > maxWavTagChunkSize {
+			if _, err := reader.Discard(int(padded)); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		data := make([]byte, padded)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil
+		}
+		h.parseTags(id, data[:size])
+	}
+}
+
+func (h *wavHeader) parseFormat(data []byte) error {
+	if len(data) < 16 {
+		return errors.New("invalid fmt chunk")
+	}
+
+	h.format = binary.LittleEndian.Uint16(data[0:2])
+	h.channels = int(binary.LittleEndian.Uint16(data[2:4]))
+	h.sampleRate = int(binary.LittleEndian.Uint32(data[4:8]))
+	h.bitsPerSample = int(binary.LittleEndian.Uint16(data[14:16]))
+
+	if h.format == wavFormatExtensible && len(data) >= 26 {
+		// The actual format is the beginning of the sub format GUID.
+		h.format = binary.LittleEndian.Uint16(data[24:26])
+	}
+
+	if h.channels == 0 || h.sampleRate == 0 {
+		return errors.New("invalid fmt chunk")
+	}
+
+	switch {
+	case h.format == wavFormatPCM && (h.bitsPerSample == 8 || h.bitsPerSample == 16 || h.bitsPerSample == 24 || h.bitsPerSample == 32):
+	case h.format == wavFormatFloat && (h.bitsPerSample == 32 || h.bitsPerSample == 64):
+	default:
+		return fmt.Errorf("%w: WAV format %d with %d bits", errUnsupported, h.format, h.bitsPerSample)
+	}
+
+	return nil
+}
+
+// Tags of the INFO list that we care about.
+var wavInfoTags = map[string]string{
+	"INAM": "title",
+	"IART": "artist",
+	"IPRD": "album",
+}
+
+func parseWavInfo(data []byte, tags map[string]string) {
+	for len(data) >= 8 {
+		id := string(data[0:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		data = data[8:]
+		if size > len(data) {
+			return
+		}
+
+		if key, ok := wavInfoTags[id]; ok {
+			tags[key] = strings.TrimRight(string(data[:size]), "\x00")
+		}
+
+		data = data[min(len(data), size+size%2):]
+	}
+}
+
+// wavSource reads uncompressed (integer or floating point) WAV files.
+type wavSource struct {
+	file   *os.File
+	reader *bufio.Reader
+	header *wavHeader
+	// Remaining bytes of sample data (-1 = until the end of the file).
+	remaining int64
+	buffer    []byte
+}
+
+func openWav(filepath string) (source, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	header, err := readWavHeader(reader)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	src := &wavSource{
+		file:      file,
+		reader:    reader,
+		header:    header,
+		remaining: -1,
+	}
+	if header.dataSize > 0 {
+		src.remaining = header.dataSize
+	}
+
+	return src, nil
+}
+
+func (src *wavSource) format() (int, int, int64) {
+	return src.header.sampleRate, src.header.channels, src.header.dataSize / int64(src.header.frameSize())
+}
+
+func (src *wavSource) readFrames(buffers [][]float32) (int, error) {
+	frameSize := src.header.frameSize()
+	size := frameSize * len(buffers[0])
+	if src.remaining >= 0 {
+		size = int(min(int64(size), src.remaining-src.remaining%int64(frameSize)))
+	}
+
+	if cap(src.buffer) < size {
+		src.buffer = make([]byte, size)
+	}
+	data := src.buffer[:size]
+
+	n, err := io.ReadFull(src.reader, data)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if src.remaining >= 0 {
+		src.remaining -= int64(n)
+		if src.remaining < int64(frameSize) {
+			err = io.EOF
+		}
+	}
+
+	frames := n / frameSize
+	bytesPerSample := src.header.bitsPerSample / 8
+	convert := src.sampleConverter()
+
+	for ch := range src.header.channels {
+		samples := buffers[ch][:frames]
+		offset := ch * bytesPerSample
+		for i := range samples {
+			samples[i] = convert(data[offset:])
+			offset += frameSize
+		}
+	}
+
+	return frames, err
+}
+
+func (src *wavSource) sampleConverter() func(data []byte) float32 {
+	if src.header.format == wavFormatFloat {
+		if src.header.bitsPerSample == 64 {
+			return func(data []byte) float32 {
+				return float32(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+			}
+		}
+		return func(data []byte) float32 {
+			return math.Float32frombits(binary.LittleEndian.Uint32(data))
+		}
+	}
+
+	switch src.header.bitsPerSample {
+	case 8:
+		// 8-bit samples are unsigned.
+		return func(data []byte) float32 {
+			return float32(int(data[0])-128) / 128
+		}
+	case 16:
+		return func(data []byte) float32 {
+			return float32(int16(binary.LittleEndian.Uint16(data))) / (1 << 15)
+		}
+	case 24:
+		return func(data []byte) float32 {
+			sample := int32(uint32(data[0])<<8|uint32(data[1])<<16|uint32(data[2])<<24) >> 8
+			return float32(sample) / (1 << 23)
+		}
+	default:
+		return func(data []byte) float32 {
+			return float32(int32(binary.LittleEndian.Uint32(data))) / (1 << 31)
+		}
+	}
+}
+
+func (src *wavSource) seek(frame int64) (int64, error) {
+	offset := frame * int64(src.header.frameSize())
+	if src.header.dataSize > 0 {
+		offset = min(offset, src.header.dataSize)
+	}
+
+	if _, err := src.file.Seek(src.header.dataOffset+offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	src.reader.Reset(src.file)
+
+	if src.remaining >= 0 {
+		src.remaining = src.header.dataSize - offset
+	}
+
+	return offset / int64(src.header.frameSize()), nil
+}
+
+func (src *wavSource) close() error {
+	return src.file.Close()
+}
+
+// wavMetadata reads the duration and the tags (LIST INFO and ID3 chunks) of a WAV file.
+func wavMetadata(filepath string) (*AudioFileMetaData, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header, err := readWavHeader(bufio.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+
+	size := header.dataSize
+	if size == 0 {
+		// Streamed file, the data goes until the end of the file.
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		size = info.Size() - header.dataOffset
+	}
+
+	frames := size / int64(header.frameSize())
+
+	if err := header.readTrailingTags(file); err != nil {
+		return nil, err
+	}
+
+	return &AudioFileMetaData{
+		Duration:   time.Duration(frames) * time.Second / time.Duration(header.sampleRate),
+		Title:      header.tags["title"],
+		Artist:     header.tags["artist"],
+		Album:      header.tags["album"],
+		ReplayGain: parseReplayGain(header.tags),
+	}, nil
+}
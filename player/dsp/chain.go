@@ -0,0 +1,164 @@
+// Package dsp contains the processors that can be applied to the output of the player.
+package dsp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tim-we/wavestreamer/player"
+)
+
+// Config describes the processor chain. The processors are applied in the given order,
+// e.g. an equalizer followed by a compressor and a limiter.
+type Config struct {
+	Processors []ProcessorSpec `json:"processors"`
+}
+
+// ProcessorSpec configures one processor of the chain. Exactly one of the fields must be set.
+type ProcessorSpec struct {
+	EQ         []EQBand          `json:"eq,omitempty"`
+	Compressor *CompressorConfig `json:"compressor,omitempty"`
+	Stereo     *StereoConfig     `json:"stereo,omitempty"`
+	Limiter    *LimiterConfig    `json:"limiter,omitempty"`
+}
+
+// StereoConfig configures the balance and mono fold down (see NewStereoImage).
+type StereoConfig struct {
+	// From -1 (left only) to 1 (right only).
+	Balance float32 `json:"balance"`
+	Mono    bool    `json:"mono"`
+}
+
+// Processor names as used by ProcessorSpec.Name and the --dsp-order option.
+const (
+	EQName         = "eq"
+	CompressorName = "compressor"
+	StereoName     = "stereo"
+	LimiterName    = "limiter"
+)
+
+var (
+	mu      sync.Mutex
+	current = Config{Processors: []ProcessorSpec{}}
+)
+
+// Name returns the name of the processor type that is set ("" if none or more than one is set).
+func (spec ProcessorSpec) Name() string {
+	var names []string
+	if len(spec.EQ) > 0 {
+		names = append(names, EQName)
+	}
+	if spec.Compressor != nil {
+		names = append(names, CompressorName)
+	}
+	if spec.Stereo != nil {
+		names = append(names, StereoName)
+	}
+	if spec.Limiter != nil {
+		names = append(names, LimiterName)
+	}
+
+	if len(names) != 1 {
+		return ""
+	}
+	return names[0]
+}
+
+// NewProcessor creates the processor of the spec.
+func (spec ProcessorSpec) NewProcessor() (player.ChunkProcessor, error) {
+	switch spec.Name() {
+	case EQName:
+		eq, err := NewEqualizer(spec.EQ)
+		if err != nil {
+			return nil, fmt.Errorf("invalid equalizer: %w", err)
+		}
+		return eq, nil
+	case CompressorName:
+		compressor, err := NewCompressor(*spec.Compressor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compressor: %w", err)
+		}
+		return compressor, nil
+	case StereoName:
+		if spec.Stereo.Balance < -1 || spec.Stereo.Balance > 1 {
+			return nil, fmt.Errorf("balance must be between -1 and 1, got %.2f", spec.Stereo.Balance)
+		}
+		return NewStereoImage(spec.Stereo.Balance, spec.Stereo.Mono), nil
+	case LimiterName:
+		limiter, err := NewLimiter(*spec.Limiter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limiter: %w", err)
+		}
+		return limiter, nil
+	default:
+		return nil, errors.New("exactly one of eq, compressor, stereo and limiter must be set")
+	}
+}
+
+// NewChain creates the processors for the given config.
+func NewChain(cfg Config) (player.ProcessorChain, error) {
+	chain := make(player.ProcessorChain, 0, len(cfg.Processors))
+
+	for i, spec := range cfg.Processors {
+		processor, err := spec.NewProcessor()
+		if err != nil {
+			return nil, fmt.Errorf("processor %d: %w", i+1, err)
+		}
+		chain = append(chain, processor)
+	}
+
+	return chain, nil
+}
+
+// Apply replaces the processors of the player. The state of the previous processors
+// (e.g. filter memory) is not carried over.
+func Apply(cfg Config) error {
+	chain, err := NewChain(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Processors == nil {
+		cfg.Processors = []ProcessorSpec{}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = cfg
+	player.SetProcessors(chain)
+
+	return nil
+}
+
+// Current returns the config of the active processors.
+func Current() Config {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return current
+}
+
+// ParseEQBand parses a band in the format type:frequency:gain[:q], e.g. "lowshelf:120:-6".
+func ParseEQBand(value string) (EQBand, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return EQBand{}, fmt.Errorf("invalid band '%s', expected type:frequency:gain[:q]", value)
+	}
+
+	band := EQBand{Type: FilterType(strings.ToLower(parts[0]))}
+	numbers := []*float64{&band.Frequency, &band.Gain, &band.Q}
+
+	for i, part := range parts[1:] {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return EQBand{}, fmt.Errorf("invalid band '%s': %w", value, err)
+		}
+		*numbers[i] = number
+	}
+
+	return band, nil
+}
@@ -0,0 +1,99 @@
+package dsp
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player"
+)
+
+type CompressorConfig struct {
+	// Level in dBFS above which the signal is compressed.
+	Threshold float64 `json:"threshold"`
+	// Compression ratio, e.g. 4 for 4:1.
+	Ratio float64 `json:"ratio"`
+	// Attack and release times in milliseconds (0 = defaults of 10ms and 200ms).
+	Attack  float64 `json:"attack,omitempty"`
+	Release float64 `json:"release,omitempty"`
+	// Gain in dB applied after the compression.
+	Makeup float64 `json:"makeup,omitempty"`
+}
+
+// Compressor is a feed-forward dynamic range compressor. All channels are compressed equally.
+type Compressor struct {
+	threshold float64
+	slope     float64
+	makeup    float64
+	attack    float64
+	release   float64
+	// Current level of the signal.
+	envelope float64
+}
+
+func NewCompressor(cfg CompressorConfig) (*Compressor, error) {
+	if cfg.Ratio < 1 {
+		return nil, errors.New("the compressor ratio must be at least 1")
+	}
+	if cfg.Attack < 0 || cfg.Release < 0 {
+		return nil, errors.New("attack and release must not be negative")
+	}
+
+	attack, release := cfg.Attack, cfg.Release
+	if attack == 0 {
+		attack = 10
+	}
+	if release == 0 {
+		release = 200
+	}
+
+	return &Compressor{
+		threshold: cfg.Threshold,
+		slope:     1 - 1/cfg.Ratio,
+		makeup:    math.Pow(10, cfg.Makeup/20),
+		attack:    smoothingCoefficient(time.Duration(attack * float64(time.Millisecond))),
+		release:   smoothingCoefficient(time.Duration(release * float64(time.Millisecond))),
+	}, nil
+}
+
+func (c *Compressor) Process(chunk *player.AudioChunk) {
+	for i := range chunk.Length {
+		level := framePeak(chunk, i)
+
+		if level > c.envelope {
+			c.envelope = c.attack*c.envelope + (1-c.attack)*level
+		} else {
+			c.envelope = c.release*c.envelope + (1-c.release)*level
+		}
+
+		gain := c.makeup
+		if c.envelope > 0 {
+			if over := 20*math.Log10(c.envelope) - c.threshold; over > 0 {
+				gain *= math.Pow(10, -over*c.slope/20)
+			}
+		}
+
+		for _, samples := range chunk.Channels {
+			samples[i] = float32(float64(samples[i]) * gain)
+		}
+	}
+}
+
+// smoothingCoefficient of a one-pole filter that reaches ~63% of a step within the given time.
+func smoothingCoefficient(duration time.Duration) float64 {
+	samples := duration.Seconds() * float64(config.SAMPLE_RATE)
+	if samples <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / samples)
+}
+
+// framePeak returns the maximum absolute sample of the i-th frame.
+func framePeak(chunk *player.AudioChunk, i int) float64 {
+	var peak float32
+	for _, samples := range chunk.Channels {
+		peak = max(peak, samples[i], -samples[i])
+	}
+	return float64(peak)
+}
@@ -0,0 +1,119 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player"
+)
+
+func TestLowShelfCutsBass(t *testing.T) {
+	eq, err := NewEqualizer([]EQBand{{Type: LowShelf, Frequency: 200, Gain: -12}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bass := peakAfter(eq, 50, 0.5)
+	treble := peakAfter(eq, 5000, 0.5)
+
+	// -12 dB is a gain of about 0.25.
+	if bass > 0.15 {
+		t.Errorf("Expected the bass to be attenuated, got a peak of %.3f", bass)
+	}
+	if math.Abs(treble-0.5) > 0.02 {
+		t.Errorf("Expected the treble to be unchanged, got a peak of %.3f", treble)
+	}
+}
+
+func TestLimiterKeepsPeaksBelowCeiling(t *testing.T) {
+	limiter, err := NewLimiter(LimiterConfig{Ceiling: -6})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ceiling := math.Pow(10, -6.0/20)
+	if peak := peakAfter(limiter, 440, 1); peak > ceiling {
+		t.Errorf("Expected peaks below %.3f, got %.3f", ceiling, peak)
+	}
+}
+
+func TestMonoFoldDown(t *testing.T) {
+	chunk := player.NewAudioChunk()
+	if len(chunk.Channels) != 2 {
+		t.Skip("Requires stereo output")
+	}
+	chunk.Channels[0][0] = 1
+
+	NewStereoImage(0, true).Process(chunk)
+
+	if chunk.Channels[0][0] != 0.5 || chunk.Channels[1][0] != 0.5 {
+		t.Errorf("Expected both channels to be 0.5, got %.2f and %.2f", chunk.Channels[0][0], chunk.Channels[1][0])
+	}
+}
+
+func TestChainKeepsTheOrder(t *testing.T) {
+	chain, err := NewChain(Config{Processors: []ProcessorSpec{
+		{Limiter: &LimiterConfig{Ceiling: -1}},
+		{Stereo: &StereoConfig{Mono: true}},
+		{EQ: []EQBand{{Type: Peak, Frequency: 1000, Gain: 3}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, first := chain[0].(*Limiter)
+	_, second := chain[1].(*StereoImage)
+	_, third := chain[2].(*Equalizer)
+	if len(chain) != 3 || !first || !second || !third {
+		t.Errorf("Unexpected chain %#v", chain)
+	}
+
+	for _, spec := range []ProcessorSpec{{}, {Stereo: &StereoConfig{}, Limiter: &LimiterConfig{Ceiling: -1}}} {
+		if _, err := NewChain(Config{Processors: []ProcessorSpec{spec}}); err == nil {
+			t.Errorf("Expected an error for %+v", spec)
+		}
+	}
+}
+
+func TestParseEQBand(t *testing.T) {
+	band, err := ParseEQBand("LowShelf:120:-6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if band != (EQBand{Type: LowShelf, Frequency: 120, Gain: -6}) {
+		t.Errorf("Unexpected band %+v", band)
+	}
+
+	if _, err := ParseEQBand("peak:1000"); err == nil {
+		t.Error("Expected an error for a band without gain")
+	}
+}
+
+// peakAfter processes one second of a sine wave and returns the peak of the last chunk.
+func peakAfter(processor player.ChunkProcessor, frequency, amplitude float64) float64 {
+	var peak float32
+	frame := 0
+
+	for range config.SAMPLE_RATE / config.FRAMES_PER_BUFFER {
+		chunk := player.NewAudioChunk()
+		for i := range chunk.Length {
+			sample := float32(amplitude * math.Sin(2*math.Pi*frequency*float64(frame)/float64(config.SAMPLE_RATE)))
+			for _, samples := range chunk.Channels {
+				samples[i] = sample
+			}
+			frame++
+		}
+
+		processor.Process(chunk)
+
+		peak = 0
+		for _, samples := range chunk.Channels {
+			for _, sample := range samples[:chunk.Length] {
+				peak = max(peak, sample, -sample)
+			}
+		}
+	}
+
+	return float64(peak)
+}
@@ -0,0 +1,138 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player"
+)
+
+// FilterType is the shape of an equalizer band.
+type FilterType string
+
+const (
+	Peak      FilterType = "peak"
+	LowShelf  FilterType = "lowshelf"
+	HighShelf FilterType = "highshelf"
+	LowPass   FilterType = "lowpass"
+	HighPass  FilterType = "highpass"
+)
+
+// EQBand is a single band of the parametric equalizer.
+// A graphic equalizer is a set of peak bands at fixed frequencies.
+type EQBand struct {
+	Type FilterType `json:"type"`
+	// Center or corner frequency in Hz.
+	Frequency float64 `json:"frequency"`
+	// Gain in dB (ignored by low and high pass filters).
+	Gain float64 `json:"gain"`
+	// Quality factor (0 = default of 0.707).
+	Q float64 `json:"q,omitempty"`
+}
+
+// Equalizer is a chain of biquad filters.
+type Equalizer struct {
+	filters []*biquad
+}
+
+func NewEqualizer(bands []EQBand) (*Equalizer, error) {
+	eq := &Equalizer{}
+
+	for _, band := range bands {
+		filter, err := newBiquad(band)
+		if err != nil {
+			return nil, err
+		}
+		eq.filters = append(eq.filters, filter)
+	}
+
+	return eq, nil
+}
+
+func (eq *Equalizer) Process(chunk *player.AudioChunk) {
+	for _, filter := range eq.filters {
+		filter.process(chunk)
+	}
+}
+
+// biquad is a second order IIR filter (Audio EQ Cookbook by Robert Bristow-Johnson).
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	// Filter state per channel (transposed direct form II).
+	state [][2]float64
+}
+
+func newBiquad(band EQBand) (*biquad, error) {
+	nyquist := float64(config.SAMPLE_RATE) / 2
+	if band.Frequency <= 0 || band.Frequency >= nyquist {
+		return nil, fmt.Errorf("frequency must be between 0 and %.0f Hz, got %.0f", nyquist, band.Frequency)
+	}
+
+	q := band.Q
+	if q == 0 {
+		q = math.Sqrt2 / 2
+	}
+	if q < 0 {
+		return nil, fmt.Errorf("q must be positive, got %.2f", q)
+	}
+
+	w0 := 2 * math.Pi * band.Frequency / float64(config.SAMPLE_RATE)
+	cos, sin := math.Cos(w0), math.Sin(w0)
+	alpha := sin / (2 * q)
+	a := math.Pow(10, band.Gain/40)
+
+	var b0, b1, b2, a0, a1, a2 float64
+
+	switch band.Type {
+	case Peak:
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case LowShelf:
+		sqrtA := 2 * math.Sqrt(a) * alpha
+		b0 = a * ((a + 1) - (a-1)*cos + sqrtA)
+		b1 = 2 * a * ((a - 1) - (a+1)*cos)
+		b2 = a * ((a + 1) - (a-1)*cos - sqrtA)
+		a0 = (a + 1) + (a-1)*cos + sqrtA
+		a1 = -2 * ((a - 1) + (a+1)*cos)
+		a2 = (a + 1) + (a-1)*cos - sqrtA
+	case HighShelf:
+		sqrtA := 2 * math.Sqrt(a) * alpha
+		b0 = a * ((a + 1) + (a-1)*cos + sqrtA)
+		b1 = -2 * a * ((a - 1) + (a+1)*cos)
+		b2 = a * ((a + 1) + (a-1)*cos - sqrtA)
+		a0 = (a + 1) - (a-1)*cos + sqrtA
+		a1 = 2 * ((a - 1) - (a+1)*cos)
+		a2 = (a + 1) - (a-1)*cos - sqrtA
+	case LowPass:
+		b0, b1, b2 = (1-cos)/2, 1-cos, (1-cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case HighPass:
+		b0, b1, b2 = (1+cos)/2, -(1 + cos), (1+cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	default:
+		return nil, fmt.Errorf("unknown filter type '%s'", band.Type)
+	}
+
+	return &biquad{
+		b0:    b0 / a0,
+		b1:    b1 / a0,
+		b2:    b2 / a0,
+		a1:    a1 / a0,
+		a2:    a2 / a0,
+		state: make([][2]float64, config.CHANNELS),
+	}, nil
+}
+
+func (filter *biquad) process(chunk *player.AudioChunk) {
+	for ch, samples := range chunk.Channels {
+		z := &filter.state[ch]
+		for i := range chunk.Length {
+			x := float64(samples[i])
+			y := filter.b0*x + z[0]
+			z[0] = filter.b1*x - filter.a1*y + z[1]
+			z[1] = filter.b2*x - filter.a2*y
+			samples[i] = float32(y)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package dsp
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/tim-we/wavestreamer/player"
+)
+
+type LimiterConfig struct {
+	// Maximum output level in dBFS.
+	Ceiling float64 `json:"ceiling"`
+	// Release time in milliseconds (0 = default of 50ms).
+	Release float64 `json:"release,omitempty"`
+}
+
+// Limiter is a brick-wall limiter: no sample will exceed the ceiling.
+// The gain is reduced instantly and recovers over the release time.
+type Limiter struct {
+	ceiling float32
+	release float64
+	gain    float64
+}
+
+func NewLimiter(cfg LimiterConfig) (*Limiter, error) {
+	if cfg.Ceiling > 0 {
+		return nil, errors.New("the limiter ceiling must not be above 0 dBFS")
+	}
+	if cfg.Release < 0 {
+		return nil, errors.New("the release must not be negative")
+	}
+
+	release := cfg.Release
+	if release == 0 {
+		release = 50
+	}
+
+	return &Limiter{
+		ceiling: float32(math.Pow(10, cfg.Ceiling/20)),
+		release: smoothingCoefficient(time.Duration(release * float64(time.Millisecond))),
+		gain:    1,
+	}, nil
+}
+
+func (l *Limiter) Process(chunk *player.AudioChunk) {
+	for i := range chunk.Length {
+		// Recover towards unity gain.
+		l.gain = 1 - (1-l.gain)*l.release
+
+		if peak := framePeak(chunk, i) * l.gain; peak > float64(l.ceiling) {
+			l.gain *= float64(l.ceiling) / peak
+		}
+
+		for _, samples := range chunk.Channels {
+			// Clamp to avoid rounding errors exceeding the ceiling.
+			samples[i] = max(-l.ceiling, min(float32(float64(samples[i])*l.gain), l.ceiling))
+		}
+	}
+}
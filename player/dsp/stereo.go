@@ -0,0 +1,42 @@
+package dsp
+
+import (
+	"github.com/tim-we/wavestreamer/player"
+)
+
+// StereoImage adjusts the balance between the left and right channel or folds them down to mono.
+// It has no effect on mono output.
+type StereoImage struct {
+	left, right float32
+	mono        bool
+}
+
+// NewStereoImage creates a processor for the given balance (-1 = left only, 1 = right only).
+// If mono is set both channels play the average of the input channels.
+func NewStereoImage(balance float32, mono bool) *StereoImage {
+	balance = max(-1, min(balance, 1))
+
+	return &StereoImage{
+		left:  min(1, 1-balance),
+		right: min(1, 1+balance),
+		mono:  mono,
+	}
+}
+
+func (s *StereoImage) Process(chunk *player.AudioChunk) {
+	if len(chunk.Channels) != 2 {
+		return
+	}
+
+	left, right := chunk.Channels[0], chunk.Channels[1]
+
+	for i := range chunk.Length {
+		l, r := left[i], right[i]
+		if s.mono {
+			l = (l + r) / 2
+			r = l
+		}
+		left[i] = l * s.left
+		right[i] = r * s.right
+	}
+}
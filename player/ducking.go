@@ -0,0 +1,78 @@
+package player
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// PriorityMode defines how a priority clip interacts with the main loop.
+type PriorityMode int32
+
+const (
+	// The priority clip replaces the main loop. Main chunks are dropped while it plays.
+	ReplaceMain PriorityMode = iota
+
+	// The priority clip is mixed over the main loop, which is lowered while it plays.
+	DuckMain
+)
+
+// priorityClip is queued for the priority loop along with its mode.
+type priorityClip struct {
+	Clip
+	mode PriorityMode
+}
+
+// Mode of the clip currently played by the priority loop.
+var priorityMode atomic.Int32
+
+// Gain of the main loop while it is ducked.
+var duckGain float32 = decibelsToGain(-12)
+
+// Time it takes to lower the main loop or to restore it.
+var duckFade = 300 * time.Millisecond
+
+// The main loop is restored once there was no priority audio for this long.
+// This avoids pumping if a priority clip stutters or several clips follow each other.
+const duckHold = 200 * time.Millisecond
+
+// SetDucking configures by how much (in dB) the main loop is lowered while a priority clip
+// is mixed over it and how long the transitions take. It must be called before Start.
+func SetDucking(level float64, fade time.Duration) {
+	duckGain = decibelsToGain(min(0, level))
+	duckFade = max(chunkDuration(), fade)
+}
+
+func decibelsToGain(decibels float64) float32 {
+	return float32(math.Pow(10, decibels/20))
+}
+
+// ducker smoothly lowers and restores the gain of the main loop. It is only used from the audio thread.
+type ducker struct {
+	gain float32
+	// Time since the last priority chunk.
+	idle time.Duration
+}
+
+func newDucker() *ducker {
+	return &ducker{gain: 1, idle: duckHold}
+}
+
+// next returns the gain ramp for the next main chunk.
+func (d *ducker) next(priorityActive bool) (start, end float32) {
+	target := float32(1)
+
+	if priorityActive {
+		d.idle = 0
+		target = duckGain
+	} else if d.idle < duckHold {
+		d.idle += chunkDuration()
+		target = duckGain
+	}
+
+	step := (1 - duckGain) * float32(chunkDuration()) / float32(duckFade)
+	start = d.gain
+	d.gain += max(-step, min(step, target-start))
+
+	return start, d.gain
+}
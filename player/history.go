@@ -10,13 +10,16 @@ type HistoryEntry struct {
 	Title         string    `json:"title"`
 	Skipped       bool      `json:"skipped"`
 	UserScheduled bool      `json:"userScheduled"`
+	Underflows    uint64    `json:"underflows,omitempty"`
+	// Why the clip failed (if it did).
+	Error string `json:"error,omitempty"`
 }
 
 const historyLength = 10
 
 var history []HistoryEntry
 
-func addClipToHistory(clip Clip, skipped bool) {
+func addClipToHistory(clip Clip, skipped bool, underflows uint64, err error) {
 	if clip == nil {
 		log.Println("Tried to add nil clip to history.")
 		return
@@ -26,11 +29,17 @@ func addClipToHistory(clip Clip, skipped bool) {
 		return
 	}
 
-	history = append(history, HistoryEntry{
-		StartTime: time.Now(),
-		Title:     clip.Name(),
-		Skipped:   skipped,
-	})
+	entry := HistoryEntry{
+		StartTime:  time.Now(),
+		Title:      clip.Name(),
+		Skipped:    skipped,
+		Underflows: underflows,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	history = append(history, entry)
 	if len(history) > historyLength {
 		history = history[1:] // remove the oldest entry
 	}
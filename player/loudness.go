@@ -1,7 +1,10 @@
 package player
 
 import (
+	"math"
+
 	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player/decoder"
 	"github.com/tim-we/wavestreamer/utils"
 )
 
@@ -49,3 +52,78 @@ func computeCurrentLoudness(previousLoudness float32, chunk *AudioChunk) float32
 	// Interpolate previous loudness value with current chunks loudness (RMS)
 	return utils.Lerp(previousLoudness, chunk.RMS, factor)
 }
+
+// StaticGainClip is implemented by clips whose loudness is known in advance (e.g. from an analysis).
+// If a gain is available it is applied instead of the dynamic normalization.
+type StaticGainClip interface {
+	StaticGain() (gain float32, ok bool)
+}
+
+func staticGain(clip Clip) (float32, bool) {
+	if gainClip, ok := clip.(StaticGainClip); ok {
+		return gainClip.StaticGain()
+	}
+	return 1, false
+}
+
+// LoudnessGain computes the gain that brings a track with the given integrated loudness (LUFS)
+// to the target loudness. Tracks are only amplified as long as their true peak (dBTP) stays below -1 dBTP.
+func LoudnessGain(integrated, truePeak, target float64) float32 {
+	gainDb := target - integrated
+
+	if gainDb > 0 {
+		gainDb = min(gainDb, max(0, -1-truePeak))
+	}
+
+	return limitGain(math.Pow(10, gainDb/20))
+}
+
+// The soft limiter used to apply gains does not support more than this.
+const maxGain = 2
+
+// limitGain caps a static gain at what the soft limiter supports.
+func limitGain(gain float64) float32 {
+	return float32(min(gain, maxGain))
+}
+
+type ReplayGainMode string
+
+const (
+	ReplayGainOff   ReplayGainMode = "off"
+	ReplayGainTrack ReplayGainMode = "track"
+	ReplayGainAlbum ReplayGainMode = "album"
+)
+
+var replayGainMode = ReplayGainOff
+
+// Additional gain in dB applied on top of ReplayGain values.
+var replayGainPreamp float64
+
+// SetReplayGain configures whether ReplayGain tags are used instead of the dynamic normalization.
+// It must be called before Start.
+func SetReplayGain(mode ReplayGainMode, preamp float64) {
+	replayGainMode = mode
+	replayGainPreamp = preamp
+}
+
+// ReplayGain computes the gain for a file with the given ReplayGain tags according
+// to the configured mode. Returns false if ReplayGain is disabled or there are no tags.
+func ReplayGain(info *decoder.ReplayGainInfo) (float32, bool) {
+	if info == nil || replayGainMode == ReplayGainOff {
+		return 1, false
+	}
+
+	gainDb, peak := info.TrackGain, info.TrackPeak
+	if replayGainMode == ReplayGainAlbum && info.HasAlbumGain {
+		gainDb, peak = info.AlbumGain, info.AlbumPeak
+	}
+
+	gain := math.Pow(10, (gainDb+replayGainPreamp)/20)
+
+	if peak > 0 {
+		// Prevent clipping.
+		gain = min(gain, 1/peak)
+	}
+
+	return limitGain(gain), true
+}
@@ -0,0 +1,58 @@
+package player
+
+import (
+	"testing"
+
+	"github.com/tim-we/wavestreamer/player/decoder"
+)
+
+func TestLoudnessGain(t *testing.T) {
+	tests := []struct {
+		name             string
+		integrated, peak float64
+		minGain, maxGain float32
+	}{
+		{"loud master is attenuated", -8, 0.5, 0.39, 0.40},
+		{"track at target is unchanged", -16, -2, 1, 1},
+		{"quiet track is amplified", -22, -10, 1.99, 2},
+		{"amplification is limited by the true peak", -22, -3, 1.25, 1.26},
+	}
+
+	for _, test := range tests {
+		gain := LoudnessGain(test.integrated, test.peak, -16)
+		if gain < test.minGain || gain > test.maxGain {
+			t.Errorf("%s: expected gain in [%v, %v], got %v", test.name, test.minGain, test.maxGain, gain)
+		}
+	}
+}
+
+func TestReplayGain(t *testing.T) {
+	defer SetReplayGain(ReplayGainOff, 0)
+
+	info := &decoder.ReplayGainInfo{
+		TrackGain:    -6,
+		TrackPeak:    1,
+		AlbumGain:    6,
+		AlbumPeak:    0.8,
+		HasAlbumGain: true,
+	}
+
+	if _, ok := ReplayGain(info); ok {
+		t.Error("Expected no gain if ReplayGain is disabled")
+	}
+
+	SetReplayGain(ReplayGainTrack, 0)
+	if gain, _ := ReplayGain(info); gain < 0.50 || gain > 0.51 {
+		t.Errorf("Expected track gain of -6 dB, got %v", gain)
+	}
+
+	SetReplayGain(ReplayGainAlbum, 0)
+	if gain, _ := ReplayGain(info); gain != 1.25 {
+		t.Errorf("Expected album gain limited by the peak, got %v", gain)
+	}
+
+	SetReplayGain(ReplayGainTrack, 6)
+	if gain, _ := ReplayGain(info); gain != 1 {
+		t.Errorf("Expected preamp to be applied, got %v", gain)
+	}
+}
@@ -0,0 +1,149 @@
+package player
+
+import (
+	"github.com/tim-we/wavestreamer/player/output"
+	"github.com/tim-we/wavestreamer/utils"
+)
+
+// OutputTap receives a copy of everything that is sent to the output sink.
+// Taps are called from the audio thread, so they must return quickly and never block.
+// The buffers are reused afterwards and must not be retained.
+type OutputTap func(out [][]float32)
+
+var outputTaps, mixTaps []OutputTap
+
+// AddOutputTap registers a tap for the mixed output. It must be called before Start.
+func AddOutputTap(tap OutputTap) {
+	outputTaps = append(outputTaps, tap)
+}
+
+// AddMixTap registers a tap for the mix before the processing and the volume, which is
+// what gets played again by a replay. It must be called before Start.
+func AddMixTap(tap OutputTap) {
+	mixTaps = append(mixTaps, tap)
+}
+
+// newMixer creates the render function that feeds the output sink.
+// It is called from the audio thread and must never block.
+func newMixer(priorityLoop, mainLoop *PlaybackLoop) output.RenderFunc {
+	volume := newVolumeRamp()
+	state := &mixerState{ducking: newDucker()}
+
+	return func(out [][]float32) {
+		mix(out, priorityLoop, mainLoop, state)
+		for _, tap := range mixTaps {
+			tap(out)
+		}
+
+		// Processing (e.g. the speaker EQ) applies to everything that is played.
+		state.process(out)
+		volume.apply(out)
+
+		for _, tap := range outputTaps {
+			tap(out)
+		}
+	}
+}
+
+// mixerState is the state of the mixer kept between calls. It is only used from the audio thread.
+type mixerState struct {
+	ducking *ducker
+
+	// Whether the previous buffer was an underflow, so that a stutter is reported only once.
+	underflow bool
+
+	// The output buffers as a chunk for the processor chain.
+	output AudioChunk
+}
+
+func mix(out [][]float32, priorityLoop, mainLoop *PlaybackLoop, state *mixerState) {
+	var priorityChunk *AudioChunk
+
+	// Check priority queue first:
+	select {
+	case priorityChunk = <-priorityLoop.NextAudioChunk:
+	default:
+		// No priority clips.
+	}
+
+	if priorityChunk != nil && PriorityMode(priorityMode.Load()) == ReplaceMain {
+		copyChunk(out, priorityChunk)
+		priorityChunk.Release()
+		// Priority chunks should replace normal ones.
+		// Otherwise you would hear the remaining chunks after a pause beep.
+		// Replays hold the main loop back instead, so that nothing is missed.
+		if !mainLoop.IsPaused() && !replaying.Load() {
+			select {
+			case mainChunk := <-mainLoop.NextAudioChunk:
+				mainChunk.Release()
+			default:
+			}
+		}
+		return
+	}
+
+	startGain, endGain := state.ducking.next(priorityChunk != nil)
+
+	// Proceed with the main queue (unless paused, then the buffered chunks are kept for later):
+	var mainChunk *AudioChunk
+	if !mainLoop.IsPaused() {
+		select {
+		case mainChunk = <-mainLoop.NextAudioChunk:
+			state.underflow = false
+		default:
+			// Underflow
+			if mainLoop.countUnderflow() && !state.underflow {
+				state.underflow = true
+				select {
+				case underflowReports <- struct{}{}:
+				default:
+					// The reporter is busy, the counters are still correct.
+				}
+			}
+		}
+	}
+
+	if mainChunk != nil {
+		copyChunk(out, mainChunk)
+		mainChunk.Release()
+		applyGainRamp(out, startGain, endGain)
+	} else {
+		// Underflow, fill with silence
+		for _, channel := range out {
+			clear(channel)
+		}
+	}
+
+	if priorityChunk != nil {
+		// Mix the priority clip over the (ducked) main loop.
+		for ch, channel := range out {
+			samples := priorityChunk.Channels[ch]
+			for i := range priorityChunk.Length {
+				channel[i] = utils.Clamp(-1, channel[i]+samples[i], 1)
+			}
+		}
+		priorityChunk.Release()
+	}
+}
+
+// copyChunk copies the samples of the chunk to the output buffers.
+func copyChunk(out [][]float32, chunk *AudioChunk) {
+	for ch, channel := range out {
+		copy(channel, chunk.Channels[ch])
+	}
+}
+
+// applyGainRamp linearly interpolates the gain from start to end over the buffers.
+func applyGainRamp(out [][]float32, start, end float32) {
+	if start == 1 && end == 1 {
+		// Nothing to do.
+		return
+	}
+
+	for _, channel := range out {
+		step := (end - start) / float32(max(1, len(channel)))
+		for i := range channel {
+			channel[i] *= start + float32(i)*step
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package player
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player/output"
+	"github.com/tim-we/wavestreamer/utils"
+)
+
+func TestMixerPriorityReplacesMainChunk(t *testing.T) {
+	priorityLoop := NewPlaybackLoop("Priority", false, nil)
+	mainLoop := NewPlaybackLoop("Main", false, nil)
+
+	sink := output.NewMemorySink()
+	sink.Start(newMixer(priorityLoop, mainLoop))
+
+	mainLoop.NextAudioChunk <- constantChunk(0.5)
+	mainLoop.NextAudioChunk <- constantChunk(0.75)
+	priorityLoop.NextAudioChunk <- constantChunk(0.25)
+
+	sink.Pull(2)
+
+	out := sink.Output()
+	if out[0][0] != 0.25 || out[1][0] != 0.25 {
+		t.Errorf("Expected priority chunk first, got %v", out[0][0])
+	}
+	// The first main chunk should have been dropped in favor of the priority chunk.
+	if second := out[0][config.FRAMES_PER_BUFFER]; second != 0.75 {
+		t.Errorf("Expected the second main chunk after the priority chunk, got %v", second)
+	}
+}
+
+func TestMixerUnderflowProducesSilence(t *testing.T) {
+	priorityLoop := NewPlaybackLoop("Priority", false, nil)
+	mainLoop := NewPlaybackLoop("Main", false, nil)
+
+	sink := output.NewMemorySink()
+	sink.Start(newMixer(priorityLoop, mainLoop))
+
+	sink.Pull(1)
+	mainLoop.NextAudioChunk <- constantChunk(0.5)
+	sink.Pull(1)
+	sink.Pull(1)
+
+	out := sink.Output()
+	expected := []float32{0, 0.5, 0}
+	for i, value := range expected {
+		if got := out[0][i*config.FRAMES_PER_BUFFER]; got != value {
+			t.Errorf("Buffer %d: expected %v, got %v", i, value, got)
+		}
+	}
+}
+
+func TestMixerPlaysCompleteClip(t *testing.T) {
+	clips := make(chan Clip, 1)
+	clips <- &chunkClip{chunks: 5, value: 0.5}
+	close(clips)
+
+	priorityLoop := NewPlaybackLoop("Priority", false, nil)
+	mainLoop := NewPlaybackLoop("Main", false, func() Clip { return <-clips })
+
+	sink := output.NewMemorySink()
+	sink.Start(newMixer(priorityLoop, mainLoop))
+
+	done := make(chan struct{})
+	go func() {
+		mainLoop.Run()
+		close(done)
+	}()
+
+	received := 0
+	timeout := time.After(time.Second)
+	for received < 5 {
+		select {
+		case <-timeout:
+			t.Fatalf("Only received %d of 5 chunks", received)
+		default:
+		}
+
+		sink.Pull(1)
+		out := sink.Output()
+		if out[0][len(out[0])-1] == 0.5 {
+			received++
+		} else {
+			// Underflow, the loop has not sent the next chunk yet.
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	<-done
+}
+
+func constantChunk(value float32) *AudioChunk {
+	chunk := NewAudioChunk()
+	for _, samples := range chunk.Channels {
+		for i := range samples {
+			samples[i] = value
+		}
+	}
+	return chunk
+}
+
+// chunkClip is a test clip consisting of a fixed number of constant chunks.
+type chunkClip struct {
+	chunks  int
+	value   float32
+	sent    int
+	stopped bool
+}
+
+func (clip *chunkClip) NextChunk() (*AudioChunk, bool) {
+	if clip.stopped || clip.sent >= clip.chunks {
+		return nil, false
+	}
+	clip.sent++
+	return constantChunk(clip.value), true
+}
+
+func (clip *chunkClip) Stop() { clip.stopped = true }
+
+func (clip *chunkClip) Name() string { return "Chunk Clip" }
+
+func (clip *chunkClip) Duration() time.Duration {
+	return time.Duration(clip.chunks) * chunkDuration()
+}
+
+func (clip *chunkClip) Duplicate() Clip { return &chunkClip{chunks: clip.chunks, value: clip.value} }
+
+func (clip *chunkClip) Hidden() bool { return false }
+
+func (clip *chunkClip) CanSeek() bool { return true }
+
+func (clip *chunkClip) Seek(position time.Duration) error {
+	clip.sent = int(position / chunkDuration())
+	return nil
+}
+
+func TestMixerDucksMainLoop(t *testing.T) {
+	priorityMode.Store(int32(DuckMain))
+	defer priorityMode.Store(int32(ReplaceMain))
+
+	priorityLoop := NewPlaybackLoop("Priority", false, nil)
+	mainLoop := NewPlaybackLoop("Main", false, nil)
+
+	sink := output.NewMemorySink()
+	sink.Start(newMixer(priorityLoop, mainLoop))
+
+	mainLoop.NextAudioChunk <- constantChunk(0.5)
+	mainLoop.NextAudioChunk <- constantChunk(0.5)
+	priorityLoop.NextAudioChunk <- constantChunk(0.25)
+	sink.Pull(1)
+
+	// Unlike the replace mode no main chunk is dropped.
+	if len(mainLoop.NextAudioChunk) != 1 {
+		t.Errorf("Expected the second main chunk to be kept")
+	}
+
+	out := sink.Output()
+	// The main loop starts at full volume and is lowered over the course of the fade.
+	if first := out[0][0]; first != 0.75 {
+		t.Errorf("Expected the priority chunk to be mixed over the main chunk, got %v", first)
+	}
+	if last := out[0][config.FRAMES_PER_BUFFER-1]; last >= 0.75 || last <= 0.25 {
+		t.Errorf("Expected the main chunk to be lowered smoothly, got %v", last)
+	}
+
+	// Without further priority audio the main loop is restored to full volume.
+	for range 200 {
+		if len(mainLoop.NextAudioChunk) == 0 {
+			mainLoop.NextAudioChunk <- constantChunk(0.5)
+		}
+		sink.Pull(1)
+	}
+	out = sink.Output()
+	if last := out[0][len(out[0])-1]; last != 0.5 {
+		t.Errorf("Expected the main loop to be restored, got %v", last)
+	}
+}
+
+func TestMixerCountsUnderflows(t *testing.T) {
+	priorityLoop := NewPlaybackLoop("Priority", false, nil)
+	mainLoop := NewPlaybackLoop("Main", false, nil)
+
+	sink := output.NewMemorySink()
+	sink.Start(newMixer(priorityLoop, mainLoop))
+
+	// No clip is playing, so this is not a stutter.
+	sink.Pull(1)
+	if total := mainLoop.underflowsTotal.Load(); total != 0 {
+		t.Errorf("Expected no underflows while waiting for a clip, got %d", total)
+	}
+
+	mainLoop.playing.Store(true)
+	sink.Pull(3)
+	mainLoop.NextAudioChunk <- constantChunk(0.5)
+	sink.Pull(2)
+
+	if underflows := mainLoop.takeUnderflows(); underflows != 4 {
+		t.Errorf("Expected 4 underflows, got %d", underflows)
+	}
+	if underflows := mainLoop.takeUnderflows(); underflows != 0 {
+		t.Errorf("Expected the counter to be reset, got %d", underflows)
+	}
+
+	// The two stutters are reported, the second one is dropped as nobody picked up the first one yet.
+	if !utils.TryDropOne(underflowReports) {
+		t.Error("Expected the underflow to be reported")
+	}
+}
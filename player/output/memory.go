@@ -0,0 +1,68 @@
+package output
+
+import (
+	"sync"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+// MemorySink records all audio in memory. It has no clock of its own,
+// buffers are only rendered when Pull is called. This makes it suitable for tests.
+type MemorySink struct {
+	render  RenderFunc
+	buffers [][]float32
+	output  [][]float32
+	mu      sync.Mutex
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{
+		buffers: newBuffers(),
+		output:  make([][]float32, config.CHANNELS),
+	}
+}
+
+func (sink *MemorySink) Start(render RenderFunc) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.render = render
+	return nil
+}
+
+// Pull renders the given number of buffers and appends them to the recorded output.
+func (sink *MemorySink) Pull(buffers int) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.render == nil {
+		return
+	}
+
+	for range buffers {
+		sink.render(sink.buffers)
+		for ch, buffer := range sink.buffers {
+			sink.output[ch] = append(sink.output[ch], buffer...)
+		}
+	}
+}
+
+// Output returns the recorded samples, one slice per channel.
+func (sink *MemorySink) Output() [][]float32 {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.output
+}
+
+func (sink *MemorySink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.render = nil
+	return nil
+}
+
+func (sink *MemorySink) Name() string {
+	return "Memory"
+}
@@ -0,0 +1,28 @@
+package output
+
+// NullSink discards all audio. It pulls chunks in real time, so the player
+// behaves exactly as it would with a sound card (useful for headless setups).
+type NullSink struct {
+	clock *clock
+}
+
+func NewNullSink() *NullSink {
+	return &NullSink{}
+}
+
+func (sink *NullSink) Start(render RenderFunc) error {
+	sink.clock = startClock(render, func(out [][]float32) {})
+	return nil
+}
+
+func (sink *NullSink) Close() error {
+	if sink.clock != nil {
+		sink.clock.Stop()
+		sink.clock = nil
+	}
+	return nil
+}
+
+func (sink *NullSink) Name() string {
+	return "Null"
+}
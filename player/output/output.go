@@ -0,0 +1,113 @@
+package output
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+// RenderFunc fills the given per-channel buffers with the next FRAMES_PER_BUFFER frames.
+type RenderFunc func(out [][]float32)
+
+// Sink consumes the mixed output of the player.
+type Sink interface {
+	// Start begins pulling audio by repeatedly invoking render. It does not block.
+	Start(render RenderFunc) error
+
+	// Close stops pulling audio and releases all resources of the sink.
+	Close() error
+
+	// A short human readable description of the sink.
+	Name() string
+}
+
+// DeviceSink is implemented by sinks that play on an audio device.
+type DeviceSink interface {
+	Sink
+
+	// Device returns the name of the active device and the output latency.
+	Device() (name string, latency time.Duration)
+}
+
+// Available sink types for the CLI.
+const (
+	PortAudio = "portaudio"
+	Null      = "null"
+	Wav       = "wav"
+)
+
+// New creates the sink of the given type. The file path is only used by file based sinks,
+// the device (name or index, "" = default device) only by PortAudio.
+func New(sinkType, filePath, device string) (Sink, error) {
+	switch sinkType {
+	case "", PortAudio:
+		return NewPortAudioSink(device), nil
+	case Null:
+		if device != "" {
+			return nil, fmt.Errorf("the %s output does not play on a device", Null)
+		}
+		return NewNullSink(), nil
+	case Wav:
+		if device != "" {
+			return nil, fmt.Errorf("the %s output does not play on a device", Wav)
+		}
+		if filePath == "" {
+			return nil, fmt.Errorf("the %s output requires a file path", Wav)
+		}
+		return NewWavSink(filePath)
+	default:
+		return nil, fmt.Errorf("unknown output type '%s'", sinkType)
+	}
+}
+
+func newBuffers() [][]float32 {
+	buffers := make([][]float32, config.CHANNELS)
+	for i := range buffers {
+		buffers[i] = make([]float32, config.FRAMES_PER_BUFFER)
+	}
+	return buffers
+}
+
+// clock emulates the pull rhythm of a sound card for sinks without hardware.
+type clock struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startClock calls render followed by consume in real time until the clock is stopped.
+func startClock(render RenderFunc, consume func(out [][]float32)) *clock {
+	c := &clock{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(c.done)
+
+		buffers := newBuffers()
+		// The amount of audio rendered per call of the RenderFunc.
+		bufferDuration := config.BufferDuration()
+		start := time.Now()
+
+		for n := 1; ; n++ {
+			render(buffers)
+			consume(buffers)
+
+			// Schedule relative to the start to avoid accumulating drift.
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(time.Until(start.Add(time.Duration(n) * bufferDuration))):
+			}
+		}
+	}()
+
+	return c
+}
+
+// Stop halts the clock and waits for the last render call to finish.
+func (c *clock) Stop() {
+	close(c.stop)
+	<-c.done
+}
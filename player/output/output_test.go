@@ -0,0 +1,11 @@
+package output
+
+import "testing"
+
+func TestDeviceIsOnlyUsedByPortAudio(t *testing.T) {
+	for _, sinkType := range []string{Null, Wav} {
+		if _, err := New(sinkType, "out.wav", "1"); err == nil {
+			t.Errorf("Expected the %s output to reject a device", sinkType)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package output
+
+/*
+#cgo linux,arm64 LDFLAGS: -lportaudio
+*/
+import "C"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/tim-we/wavestreamer/config"
+)
+
+// PortAudioSink plays the output on an audio device of the system.
+type PortAudioSink struct {
+	// Name or index of the requested device ("" = default output device).
+	device string
+	stream *portaudio.Stream
+
+	// The device in use, set once the stream has been started.
+	mu      sync.Mutex
+	active  *portaudio.DeviceInfo
+	latency time.Duration
+}
+
+func NewPortAudioSink(device string) *PortAudioSink {
+	return &PortAudioSink{device: device}
+}
+
+// DeviceInfo describes an output device for the device list.
+type DeviceInfo struct {
+	Index   int
+	Name    string
+	HostApi string
+	// Maximum number of output channels.
+	Channels          int
+	DefaultSampleRate float64
+	DefaultLatency    time.Duration
+	IsDefault         bool
+}
+
+// ListDevices returns all devices that can be used for output.
+func ListDevices() ([]DeviceInfo, error) {
+	if err := portaudio.Initialize(); err != nil {
+		return nil, err
+	}
+	defer portaudio.Terminate()
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultDevice, _ := portaudio.DefaultOutputDevice()
+
+	list := make([]DeviceInfo, 0, len(devices))
+	for _, device := range devices {
+		if device.MaxOutputChannels == 0 {
+			continue
+		}
+
+		info := DeviceInfo{
+			Index:             device.Index,
+			Name:              device.Name,
+			Channels:          device.MaxOutputChannels,
+			DefaultSampleRate: device.DefaultSampleRate,
+			DefaultLatency:    device.DefaultLowOutputLatency,
+			IsDefault:         defaultDevice != nil && device.Index == defaultDevice.Index,
+		}
+		if device.HostApi != nil {
+			info.HostApi = device.HostApi.Name
+		}
+		list = append(list, info)
+	}
+
+	return list, nil
+}
+
+// selectDevice finds an output device by its index or name. Names are matched exactly
+// first, then case insensitively as a substring (e.g. "usb" for "USB Audio Device").
+func selectDevice(devices []*portaudio.DeviceInfo, query string) (*portaudio.DeviceInfo, error) {
+	outputs := make([]*portaudio.DeviceInfo, 0, len(devices))
+	for _, device := range devices {
+		if device.MaxOutputChannels > 0 {
+			outputs = append(outputs, device)
+		}
+	}
+
+	if index, err := strconv.Atoi(query); err == nil {
+		for _, device := range outputs {
+			if device.Index == index {
+				return device, nil
+			}
+		}
+		return nil, fmt.Errorf("there is no output device with index %d", index)
+	}
+
+	for _, device := range outputs {
+		if device.Name == query {
+			return device, nil
+		}
+	}
+
+	var match *portaudio.DeviceInfo
+	for _, device := range outputs {
+		if strings.Contains(strings.ToLower(device.Name), strings.ToLower(query)) {
+			if match != nil {
+				return nil, fmt.Errorf("the device name '%s' is ambiguous ('%s' and '%s')", query, match.Name, device.Name)
+			}
+			match = device
+		}
+	}
+
+	if match == nil {
+		return nil, fmt.Errorf("no output device matches '%s'", query)
+	}
+
+	return match, nil
+}
+
+// Start creates and starts a low-latency PortAudio output stream.
+// PortAudio invokes render repeatedly to fill the output buffers (one per channel).
+func (sink *PortAudioSink) Start(render RenderFunc) error {
+	if err := portaudio.Initialize(); err != nil {
+		return err
+	}
+
+	outputDevice, devErr := sink.findDevice()
+	if devErr != nil {
+		portaudio.Terminate()
+		return devErr
+	}
+
+	// Set up a low-latency PortAudio stream with a fixed buffer size
+	streamParams := portaudio.StreamParameters{
+		Output: portaudio.StreamDeviceParameters{
+			Device:   outputDevice,
+			Channels: config.CHANNELS,                      // output channels
+			Latency:  outputDevice.DefaultLowOutputLatency, // use device's low latency setting
+		},
+		SampleRate:      float64(config.SAMPLE_RATE), // output sample rate
+		FramesPerBuffer: config.FRAMES_PER_BUFFER,    // output buffer size
+	}
+
+	stream, streamErr := portaudio.OpenStream(streamParams, func(out [][]float32) { render(out) })
+	if streamErr != nil {
+		portaudio.Terminate()
+		return streamErr
+	}
+
+	info := stream.Info()
+	fmt.Printf("Output device: %s\n", outputDevice.Name)
+	fmt.Printf("Output latency: %d ms\n", info.OutputLatency.Milliseconds())
+
+	if err := stream.Start(); err != nil {
+		stream.Close()
+		portaudio.Terminate()
+		return err
+	}
+
+	sink.stream = stream
+
+	sink.mu.Lock()
+	sink.active = outputDevice
+	sink.latency = info.OutputLatency
+	sink.mu.Unlock()
+
+	return nil
+}
+
+func (sink *PortAudioSink) findDevice() (*portaudio.DeviceInfo, error) {
+	if sink.device == "" {
+		return portaudio.DefaultOutputDevice()
+	}
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	return selectDevice(devices, sink.device)
+}
+
+// Device returns the name of the device in use and the output latency of the stream.
+func (sink *PortAudioSink) Device() (string, time.Duration) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.active == nil {
+		return "", 0
+	}
+	return sink.active.Name, sink.latency
+}
+
+func (sink *PortAudioSink) Close() error {
+	if sink.stream == nil {
+		return nil
+	}
+
+	stopErr := sink.stream.Stop()
+	closeErr := sink.stream.Close()
+	sink.stream = nil
+	terminateErr := portaudio.Terminate()
+
+	if stopErr != nil {
+		return stopErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return terminateErr
+}
+
+func (sink *PortAudioSink) Name() string {
+	return "PortAudio"
+}
@@ -0,0 +1,48 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+func TestSelectDevice(t *testing.T) {
+	devices := []*portaudio.DeviceInfo{
+		{Index: 0, Name: "Built-in Microphone", MaxInputChannels: 2},
+		{Index: 1, Name: "bcm2835 Headphones", MaxOutputChannels: 2},
+		{Index: 2, Name: "USB Audio Device", MaxOutputChannels: 2},
+		{Index: 3, Name: "vc4-hdmi-0", MaxOutputChannels: 2},
+		{Index: 4, Name: "vc4-hdmi-1", MaxOutputChannels: 2},
+	}
+
+	tests := []struct {
+		query    string
+		expected int // index of the expected device, -1 = error
+	}{
+		{"2", 2},
+		{"0", -1}, // input only
+		{"USB Audio Device", 2},
+		{"usb", 2},
+		{"headphones", 1},
+		{"hdmi", -1}, // ambiguous
+		{"vc4-hdmi-1", 4},
+		{"speaker", -1},
+	}
+
+	for _, test := range tests {
+		device, err := selectDevice(devices, test.query)
+
+		if test.expected < 0 {
+			if err == nil {
+				t.Errorf("Expected an error for '%s', got %s", test.query, device.Name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %v", test.query, err)
+		} else if device.Index != test.expected {
+			t.Errorf("Expected device %d for '%s', got %d", test.expected, test.query, device.Index)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package output
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+// WavSink writes the output as 16-bit PCM into a WAV file in real time.
+type WavSink struct {
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	clock   *clock
+	written uint32 // number of data bytes
+	mu      sync.Mutex
+	err     error
+}
+
+const wavHeaderSize = 44
+
+func NewWavSink(path string) (*WavSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &WavSink{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+
+	// The sizes are not known yet, they will be updated on Close.
+	if err := WriteWavHeader(sink.writer, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+func (sink *WavSink) Start(render RenderFunc) error {
+	sink.clock = startClock(render, sink.write)
+	return nil
+}
+
+func (sink *WavSink) write(out [][]float32) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.err != nil {
+		return
+	}
+
+	n, err := WritePCM16(sink.writer, out)
+	sink.written += uint32(n)
+	sink.err = err
+}
+
+// Close stops recording and finalizes the WAV header.
+func (sink *WavSink) Close() error {
+	if sink.clock != nil {
+		sink.clock.Stop()
+		sink.clock = nil
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.file == nil {
+		return sink.err
+	}
+
+	defer func() {
+		sink.file.Close()
+		sink.file = nil
+	}()
+
+	if err := sink.writer.Flush(); err != nil {
+		return err
+	}
+
+	// Rewrite the header now that the size is known.
+	if _, err := sink.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := WriteWavHeader(sink.file, sink.written); err != nil {
+		return err
+	}
+
+	return sink.err
+}
+
+func (sink *WavSink) Name() string {
+	return "WAV file " + sink.path
+}
+
+// WriteWavHeader writes a canonical 44 byte WAV header for 16-bit PCM
+// in the configured output format. dataSize is the number of PCM bytes that follow.
+func WriteWavHeader(w io.Writer, dataSize uint32) error {
+	const bitsPerSample = 16
+	blockAlign := config.CHANNELS * bitsPerSample / 8
+
+	header := make([]byte, 0, wavHeaderSize)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, wavHeaderSize-8+dataSize)
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16) // size of the fmt chunk
+	header = binary.LittleEndian.AppendUint16(header, 1)  // PCM
+	header = binary.LittleEndian.AppendUint16(header, uint16(config.CHANNELS))
+	header = binary.LittleEndian.AppendUint32(header, uint32(config.SAMPLE_RATE))
+	header = binary.LittleEndian.AppendUint32(header, uint32(config.SAMPLE_RATE*blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, bitsPerSample)
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, dataSize)
+
+	_, err := w.Write(header)
+	return err
+}
+
+// WritePCM16 interleaves the channels and writes them as signed 16-bit little endian samples.
+// It returns the number of bytes written.
+func WritePCM16(w io.Writer, channels [][]float32) (int, error) {
+	return w.Write(AppendPCM16(nil, channels))
+}
+
+// AppendPCM16 interleaves the channels, converts them to signed 16-bit little endian samples
+// and appends the result to dst.
+func AppendPCM16(dst []byte, channels [][]float32) []byte {
+	if len(channels) == 0 {
+		return dst
+	}
+
+	frames := len(channels[0])
+	dst = slices.Grow(dst, 2*frames*len(channels))
+
+	for i := range frames {
+		for _, samples := range channels {
+			dst = binary.LittleEndian.AppendUint16(dst, uint16(FloatToPCM16(samples[i])))
+		}
+	}
+
+	return dst
+}
+
+// FloatToPCM16 converts a sample in [-1, 1] to a signed 16-bit integer. Values outside are clipped.
+func FloatToPCM16(sample float32) int16 {
+	if sample >= 1 {
+		return 32767
+	}
+	if sample <= -1 {
+		return -32768
+	}
+	return int16(sample * 32768)
+}
@@ -0,0 +1,65 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+func TestWavSinkHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+
+	sink, err := NewWavSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := make(chan struct{}, 1)
+	sink.Start(func(out [][]float32) {
+		for _, samples := range out {
+			for i := range samples {
+				samples[i] = 0.5
+			}
+		}
+		select {
+		case rendered <- struct{}{}:
+		default:
+		}
+	})
+	<-rendered
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, []byte("RIFF")) || string(data[8:16]) != "WAVEfmt " {
+		t.Fatalf("Invalid WAV header")
+	}
+
+	dataSize := binary.LittleEndian.Uint32(data[40:44])
+	if int(dataSize) != len(data)-wavHeaderSize {
+		t.Errorf("Header reports %d data bytes, file contains %d", dataSize, len(data)-wavHeaderSize)
+	}
+	if dataSize == 0 || dataSize%uint32(2*config.CHANNELS*config.FRAMES_PER_BUFFER) != 0 {
+		t.Errorf("Expected a multiple of complete buffers, got %d bytes", dataSize)
+	}
+
+	if sample := int16(binary.LittleEndian.Uint16(data[wavHeaderSize:])); sample != 16384 {
+		t.Errorf("Expected first sample to be 16384, got %d", sample)
+	}
+}
+
+func TestFloatToPCM16Clipping(t *testing.T) {
+	if FloatToPCM16(1.5) != 32767 || FloatToPCM16(-1.5) != -32768 || FloatToPCM16(0) != 0 {
+		t.Errorf("Unexpected conversion result")
+	}
+}
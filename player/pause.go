@@ -0,0 +1,140 @@
+package player
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// SuspendableClip is implemented by clips that hold resources while they are playing
+// (e.g. a decoding process) and can release them during a long pause.
+type SuspendableClip interface {
+	Clip
+
+	// Suspend releases the resources of the clip. When the clip is played again,
+	// playback continues at the given position.
+	Suspend(position time.Duration)
+}
+
+// Pauses longer than this release the resources of the current clip (0 = never).
+var pauseTimeout = 5 * time.Minute
+
+// SetPauseTimeout configures after which time a pause releases the resources of the current clip.
+// It must be called before Start.
+func SetPauseTimeout(timeout time.Duration) {
+	pauseTimeout = max(0, timeout)
+}
+
+// Pause stops the playback of the main loop. The current clip continues where it stopped on Resume.
+// Priority clips (e.g. beeps) are still played while paused.
+func Pause() error {
+	// A replay must not resume the playback after it has been paused explicitly.
+	keepPausedAfterReplay()
+	return pause()
+}
+
+func pause() error {
+	if mainLoop == nil {
+		return errors.New("player has not been started")
+	}
+	if mainLoop.SetPaused(true) {
+		log.Println("Playback paused.")
+		eventBus.Publish(&NowPlayingEvent{CurrentClip: mainLoop.GetCurrentClip()})
+	}
+	return nil
+}
+
+// Resume continues the playback after Pause.
+func Resume() error {
+	if mainLoop == nil {
+		return errors.New("player has not been started")
+	}
+	if mainLoop.SetPaused(false) {
+		log.Println("Playback resumed.")
+		eventBus.Publish(&NowPlayingEvent{CurrentClip: mainLoop.GetCurrentClip()})
+	}
+	return nil
+}
+
+// IsPaused reports whether the playback has been paused with Pause.
+func IsPaused() bool {
+	return mainLoop != nil && mainLoop.IsPaused()
+}
+
+// SetPaused pauses or resumes the loop. Returns false if the state did not change.
+func (loop *PlaybackLoop) SetPaused(paused bool) bool {
+	if loop.paused.Swap(paused) == paused {
+		return false
+	}
+
+	select {
+	case loop.pauseSignal <- struct{}{}:
+	default:
+		// Signal already pending, the loop will check the current state.
+	}
+
+	return true
+}
+
+func (loop *PlaybackLoop) IsPaused() bool {
+	return loop.paused.Load()
+}
+
+// send passes a chunk to the output. While paused the chunk is held back.
+func (loop *PlaybackLoop) send(chunk *AudioChunk, current, next *clipPlayback) {
+	for {
+		select {
+		case loop.NextAudioChunk <- chunk:
+			return
+		case <-loop.pauseSignal:
+			if loop.waitWhilePaused(current, next) {
+				// The chunk is from before a seek or of a skipped clip.
+				chunk.Release()
+				return
+			}
+		case <-shutdownSignal:
+			return
+		}
+	}
+}
+
+// waitWhilePaused blocks until the loop is resumed. Seek requests are still handled. It returns true
+// if the held back chunk is outdated (after a seek or a skip). If the pause exceeds the timeout the clips are suspended.
+func (loop *PlaybackLoop) waitWhilePaused(current, next *clipPlayback) (outdated bool) {
+	var timeout <-chan time.Time
+	if pauseTimeout > 0 {
+		timer := time.NewTimer(pauseTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for loop.paused.Load() {
+		select {
+		case <-loop.pauseSignal:
+			// Paused state changed, check again.
+		case request := <-loop.seekSignal:
+			loop.handleSeek(current, next != nil, request)
+			loop.updatePosition(current)
+			outdated = true
+		case <-timeout:
+			log.Printf("Paused for more than %v, releasing resources.", pauseTimeout)
+			suspend(current)
+			suspend(next)
+			loop.releasePreparedClip()
+		case <-shutdownSignal:
+			return outdated
+		}
+	}
+
+	// The skip itself is handled by the loop.
+	return outdated || len(loop.skipSignal) > 0
+}
+
+func suspend(playback *clipPlayback) {
+	if playback == nil {
+		return
+	}
+	if clip, ok := playback.clip.(SuspendableClip); ok {
+		clip.Suspend(playback.position)
+	}
+}
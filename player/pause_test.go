@@ -0,0 +1,103 @@
+package player
+
+import (
+	"testing"
+	"time"
+)
+
+// suspendClip reports suspensions of a chunkClip.
+type suspendClip struct {
+	chunkClip
+	suspensions chan suspension
+}
+
+type suspension struct {
+	position time.Duration
+	sent     int // number of chunks read at the time of the suspension
+}
+
+func (clip *suspendClip) Suspend(position time.Duration) {
+	clip.suspensions <- suspension{position, clip.sent}
+}
+
+func TestPauseKeepsPosition(t *testing.T) {
+	oldTimeout := pauseTimeout
+	pauseTimeout = 20 * time.Millisecond
+	defer func() { pauseTimeout = oldTimeout }()
+
+	clip := &suspendClip{
+		chunkClip:   chunkClip{chunks: 20, value: 0.5},
+		suspensions: make(chan suspension, 2),
+	}
+	clips := make(chan Clip, 1)
+	clips <- clip
+	close(clips)
+
+	loop := NewPlaybackLoop("Test", false, func() Clip { return <-clips })
+	done := make(chan struct{})
+	go func() {
+		loop.Run()
+		close(done)
+	}()
+
+	received := 0
+	for range 5 {
+		<-loop.NextAudioChunk
+		received++
+	}
+
+	loop.SetPaused(true)
+
+	select {
+	case s := <-clip.suspensions:
+		// The chunks in the output buffer and the one held back by the loop have already been read.
+		if expected := time.Duration(s.sent) * chunkDuration(); s.position != expected {
+			t.Errorf("Expected suspension at %v, got %v", expected, s.position)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The clip was not suspended after the pause timeout")
+	}
+
+	loop.SetPaused(false)
+
+	for range loop.NextAudioChunk {
+		received++
+		if received == 20 {
+			break
+		}
+	}
+	<-done
+
+	if received != 20 {
+		t.Errorf("Expected all 20 chunks after resuming, got %d", received)
+	}
+}
+
+func TestSkipWhilePausedDiscardsBufferedChunks(t *testing.T) {
+	clips := make(chan Clip, 2)
+	clips <- &chunkClip{chunks: 100, value: 0.5}
+	clips <- &chunkClip{chunks: 1, value: 0.25}
+	close(clips)
+
+	loop := NewPlaybackLoop("Test", false, func() Clip { return <-clips })
+	done := make(chan struct{})
+	go func() {
+		loop.Run()
+		close(done)
+	}()
+
+	<-loop.NextAudioChunk
+	loop.SetPaused(true)
+	// Let the loop fill the output buffer and hold back the next chunk.
+	time.Sleep(50 * time.Millisecond)
+
+	// Like SkipCurrent
+	loop.Skip()
+	loop.discardBufferedChunks()
+	loop.SetPaused(false)
+
+	if chunk := <-loop.NextAudioChunk; chunk.Channels[0][0] != 0.25 {
+		t.Errorf("Expected the next clip right after the skip, got %v", chunk.Channels[0][0])
+	}
+	<-done
+}
@@ -2,6 +2,7 @@ package player
 
 import (
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/tim-we/wavestreamer/config"
@@ -11,12 +12,48 @@ import (
 type PlaybackLoop struct {
 	NextAudioChunk    chan *AudioChunk
 	ClipStartCallback func(Clip)
+	ProgressCallback  func(clip Clip, position time.Duration) // called about once per second
+	position          atomic.Int64                            // playback position of the current clip
+	lastProgress      time.Duration
 	currentClip       Clip
 	name              string
 	skipSignal        chan struct{}
+	seekSignal        chan seekRequest
 	clipProvider      func() Clip
+	clipPeeker        func() Clip // optional, returns the next clip without consuming it
+	preparedClip      Clip        // the last clip that has been prepared ahead of time
 	normalize         bool
 	clipEndCallback   func(Clip, bool)
+	crossfade         time.Duration
+	pendingClip       Clip // a clip that was fetched early but could not be crossfaded
+	paused            atomic.Bool
+	pauseSignal       chan struct{}
+	playing           atomic.Bool   // false while waiting for a clip
+	underflows        atomic.Uint64 // underflows during the current clip
+	underflowsTotal   atomic.Uint64
+}
+
+// clipPlayback holds the state of a clip while it is being played.
+type clipPlayback struct {
+	clip Clip
+
+	// Measured loudness and the gain applied to the previous chunk (normalization).
+	inputLoudness float32
+	lastGain      float32
+
+	// We perform this check only once per clip. That way it is a cheap operation and
+	// does not cause weird audio glitches when we dynamically toggle features like normalization.
+	reduceCPULoad bool
+
+	// Playback position, i.e. the duration of all chunks read so far.
+	position time.Duration
+
+	// Fade in over the first fadeIn of the clip (0 = no fade).
+	fadeIn time.Duration
+
+	// Fade out starting at fadeOutStart over fadeOutLength (0 = no fade).
+	fadeOutStart  time.Duration
+	fadeOutLength time.Duration
 }
 
 func NewPlaybackLoop(name string, normalize bool, clipProvider func() Clip) *PlaybackLoop {
@@ -26,67 +63,236 @@ func NewPlaybackLoop(name string, normalize bool, clipProvider func() Clip) *Pla
 		clipProvider:   clipProvider,
 		normalize:      normalize,
 		skipSignal:     make(chan struct{}, 1),
+		seekSignal:     make(chan seekRequest, 1),
+		pauseSignal:    make(chan struct{}, 1),
 	}
 }
 
 func (loop *PlaybackLoop) Run() {
+	var current *clipPlayback
+
 	for {
-		clip := loop.clipProvider()
-		skipped := false
+		if current == nil {
+			clip := loop.nextClip()
+			if clip == nil {
+				if !isShuttingDown() {
+					log.Printf("No more clips to play in %s.", loop.name)
+				}
+				break
+			}
+			current = loop.startClip(clip)
+		}
+
+		loop.playing.Store(true)
+		next := loop.play(current)
+		loop.playing.Store(next != nil)
 
-		if clip == nil {
-			log.Printf("No more clips to play in %s.", loop.name)
+		if isShuttingDown() {
 			break
 		}
 
-		if loop.ClipStartCallback != nil {
-			loop.ClipStartCallback(clip)
+		if current.reduceCPULoad && next == nil {
+			time.Sleep(200 * time.Millisecond)
 		}
-		loop.currentClip = clip
 
-		// Reset measured loudness for new clip
-		var inputLoudness float32 = config.TARGET_MIN_RMS
-		var lastGain float32 = 1.0
-
-		// We perform this check only once per clip. That way it is a cheap operation and
-		// does not cause weird audio glitches when we dynamically toggle features like normalization.
-		reduceCPULoad := utils.ShouldReduceCPU()
-
-		for {
-			// Check if there is a skip signal
-			if utils.TryDropOne(loop.skipSignal) {
-				clip.Stop()
-				skipped = true
-				break
-			}
+		// If a crossfade has started the next clip is already playing.
+		current = next
+	}
 
-			chunk, hasMore := clip.NextChunk()
+	if loop.pendingClip != nil {
+		loop.pendingClip.Stop()
+		loop.pendingClip = nil
+	}
+}
 
-			if !hasMore || chunk == nil {
-				// We have reached the end of clip
-				break
+// play sends the chunks of the given clip to the output until it ends or is skipped.
+// If a crossfade into the next clip was started, the playback of that clip is returned.
+func (loop *PlaybackLoop) play(current *clipPlayback) (next *clipPlayback) {
+	for {
+		if isShuttingDown() {
+			current.clip.Stop()
+			if next != nil {
+				next.clip.Stop()
 			}
+			return nil
+		}
 
-			if !reduceCPULoad && loop.normalize {
-				inputLoudness = computeCurrentLoudness(inputLoudness, chunk)
-				gain := computeTargetGain(chunk, inputLoudness)
-				chunk.ApplyGain(lastGain, gain)
-				lastGain = gain
+		// Check if there is a skip signal
+		if utils.TryDropOne(loop.skipSignal) {
+			current.clip.Stop()
+			loop.discardBufferedChunks()
+			if next == nil {
+				loop.endClip(current, true)
+				return nil
 			}
+			// The fading out clip ends regularly, the new one is the one being skipped.
+			next.clip.Stop()
+			loop.endClip(current, false)
+			loop.endClip(next, true)
+			return nil
+		}
 
-			loop.NextAudioChunk <- chunk
+		select {
+		case request := <-loop.seekSignal:
+			loop.handleSeek(current, next != nil, request)
+		default:
 		}
 
-		if loop.clipEndCallback != nil {
-			loop.clipEndCallback(clip, skipped)
+		if next == nil {
+			loop.prewarmNextClip(current)
 		}
 
-		if reduceCPULoad {
-			time.Sleep(200 * time.Millisecond)
+		if next == nil && loop.shouldStartCrossfade(current) {
+			next = loop.startCrossfade(current)
+		}
+
+		chunk, hasMore := loop.readChunk(current)
+
+		if !hasMore {
+			// We have reached the end of clip
+			loop.endClip(current, false)
+			return next
+		}
+
+		if next != nil {
+			if nextChunk, nextHasMore := loop.readChunk(next); nextHasMore {
+				chunk.MixIn(nextChunk)
+				nextChunk.Release()
+			}
+		}
+
+		loop.send(chunk, current, next)
+
+		if next != nil {
+			// The next clip is already the current clip from the outside perspective.
+			loop.updatePosition(next)
+		} else {
+			loop.updatePosition(current)
+		}
+
+		if current.fadeOutLength > 0 && current.position >= current.fadeOutStart+current.fadeOutLength {
+			// Faded out completely. No need to wait for the remaining (silent) chunks.
+			current.clip.Stop()
+			loop.endClip(current, false)
+			return next
 		}
 	}
 }
 
+// readChunk reads the next chunk of a clip and applies normalization and fades.
+func (loop *PlaybackLoop) readChunk(playback *clipPlayback) (*AudioChunk, bool) {
+	chunk, hasMore := playback.clip.NextChunk()
+
+	if !hasMore || chunk == nil {
+		return nil, false
+	}
+
+	if gain, ok := staticGain(playback.clip); ok {
+		// The loudness of the clip is known, no need to guess.
+		chunk.ApplyGain(gain, gain)
+	} else if !playback.reduceCPULoad && loop.normalize {
+		playback.inputLoudness = computeCurrentLoudness(playback.inputLoudness, chunk)
+		gain := computeTargetGain(chunk, playback.inputLoudness)
+		chunk.ApplyGain(playback.lastGain, gain)
+		playback.lastGain = gain
+	}
+
+	start := playback.position
+	playback.position += chunk.Duration()
+
+	if playback.fadeIn > 0 && start < playback.fadeIn {
+		chunk.ApplyGain(
+			fadeInGain(start, playback.fadeIn),
+			fadeInGain(playback.position, playback.fadeIn),
+		)
+	}
+
+	if playback.fadeOutLength > 0 && playback.position > playback.fadeOutStart {
+		chunk.ApplyGain(
+			fadeOutGain(start-playback.fadeOutStart, playback.fadeOutLength),
+			fadeOutGain(playback.position-playback.fadeOutStart, playback.fadeOutLength),
+		)
+	}
+
+	return chunk, true
+}
+
+func (loop *PlaybackLoop) nextClip() Clip {
+	if loop.pendingClip != nil {
+		clip := loop.pendingClip
+		loop.pendingClip = nil
+		return clip
+	}
+	return loop.clipProvider()
+}
+
+func (loop *PlaybackLoop) startClip(clip Clip) *clipPlayback {
+	loop.position.Store(0)
+	loop.lastProgress = 0
+
+	if loop.ClipStartCallback != nil {
+		loop.ClipStartCallback(clip)
+	}
+	loop.currentClip = clip
+	if clip == loop.preparedClip {
+		// It is playing now and must not be suspended as the prepared clip.
+		loop.preparedClip = nil
+	}
+
+	return &clipPlayback{
+		clip: clip,
+		// Reset measured loudness for new clip
+		inputLoudness: config.TARGET_MIN_RMS,
+		lastGain:      1.0,
+		reduceCPULoad: utils.ShouldReduceCPU(),
+	}
+}
+
+func (loop *PlaybackLoop) endClip(playback *clipPlayback, skipped bool) {
+	if loop.clipEndCallback != nil {
+		loop.clipEndCallback(playback.clip, skipped)
+	}
+}
+
+func (loop *PlaybackLoop) shouldStartCrossfade(current *clipPlayback) bool {
+	if loop.crossfade <= 0 || loop.pendingClip != nil || !canCrossfade(current.clip) {
+		return false
+	}
+
+	duration := current.clip.Duration()
+	if duration < 2*loop.crossfade {
+		// Too short, the crossfade would take up most of the clip.
+		return false
+	}
+
+	return duration-current.position <= loop.crossfade
+}
+
+// startCrossfade fetches the next clip and starts fading into it.
+// Returns nil if the next clip must not be crossfaded.
+func (loop *PlaybackLoop) startCrossfade(current *clipPlayback) *clipPlayback {
+	clip := loop.nextClip()
+
+	if clip == nil {
+		return nil
+	}
+
+	if !canCrossfade(clip) || clip.Duration() < 2*loop.crossfade {
+		// Play it normally after the current clip has ended.
+		loop.pendingClip = clip
+		return nil
+	}
+
+	length := max(current.clip.Duration()-current.position, chunkDuration())
+	current.fadeOutStart = current.position
+	current.fadeOutLength = length
+
+	next := loop.startClip(clip)
+	next.fadeIn = length
+
+	return next
+}
+
 func (loop *PlaybackLoop) Skip() {
 	select {
 	case loop.skipSignal <- struct{}{}:
@@ -97,10 +303,42 @@ func (loop *PlaybackLoop) Skip() {
 
 }
 
+// discardBufferedChunks drops the chunks that have been sent to the output but not played yet.
+func (loop *PlaybackLoop) discardBufferedChunks() {
+	for {
+		select {
+		case chunk := <-loop.NextAudioChunk:
+			chunk.Release()
+		default:
+			return
+		}
+	}
+}
+
 func (loop *PlaybackLoop) GetCurrentClip() Clip {
 	return loop.currentClip
 }
 
+// GetPosition returns the playback position within the current clip.
+func (loop *PlaybackLoop) GetPosition() time.Duration {
+	return time.Duration(loop.position.Load())
+}
+
+func (loop *PlaybackLoop) updatePosition(playback *clipPlayback) {
+	loop.position.Store(int64(playback.position))
+
+	if loop.ProgressCallback == nil {
+		return
+	}
+
+	// Jumps (e.g. seeking) are reported immediately.
+	elapsed := playback.position - loop.lastProgress
+	if elapsed >= time.Second || elapsed < 0 {
+		loop.lastProgress = playback.position
+		loop.ProgressCallback(playback.clip, playback.position)
+	}
+}
+
 func (loop *PlaybackLoop) OnClipEnd(callback func(Clip, bool)) {
 	if loop.clipEndCallback != nil {
 		panic("OnClipEnd should only be called once")
@@ -17,6 +17,47 @@ func TestAddToQueue(t *testing.T) {
 	}
 }
 
+func TestProgressIsReported(t *testing.T) {
+	// Roughly 2.3 seconds of audio.
+	clip := &chunkClip{chunks: 100, value: 0.5}
+	provided := false
+
+	loop := NewPlaybackLoop("Test", false, func() Clip {
+		if provided {
+			return nil
+		}
+		provided = true
+		return clip
+	})
+
+	var reported []time.Duration
+	loop.ProgressCallback = func(clip Clip, position time.Duration) {
+		reported = append(reported, position)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		loop.Run()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-loop.NextAudioChunk:
+			continue
+		case <-done:
+		}
+		break
+	}
+
+	if len(reported) != 2 {
+		t.Fatalf("Expected 2 progress updates, got %v", reported)
+	}
+	if reported[0] < time.Second || reported[1] < 2*time.Second {
+		t.Errorf("Unexpected progress positions %v", reported)
+	}
+}
+
 type testClip struct{}
 
 func (clip *testClip) NextChunk() (*AudioChunk, bool) { return nil, false }
@@ -30,3 +71,7 @@ func (clip *testClip) Duration() time.Duration { return 0 }
 func (clip *testClip) Duplicate() Clip { return &testClip{} }
 
 func (clip *testClip) Hidden() bool { return false }
+
+func (clip *testClip) CanSeek() bool { return false }
+
+func (clip *testClip) Seek(position time.Duration) error { return ErrSeekNotSupported }
@@ -1,48 +1,87 @@
 package player
 
-/*
-#cgo linux,arm64 LDFLAGS: -lportaudio
-*/
-import "C"
-
 import (
 	"context"
 	"log"
+	"sync/atomic"
+	"time"
 
-	"github.com/gordonklaus/portaudio"
-	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player/output"
 	"github.com/tim-we/wavestreamer/utils"
 )
 
 var userQueue = utils.NewConcurrentQueue[Clip](12)
 
-var priorityQueue = make(chan Clip, 2)
+var priorityQueue = make(chan *priorityClip, 2)
 
 var mainLoop *PlaybackLoop
 
 var beepClipProvider func() Clip
 
-var eventBus *utils.EventBus[PlayerEvent]
+// The sink passed to Start, read by the web app while the player is running.
+var outputSink atomic.Pointer[output.Sink]
 
-var zeroByteSlice = make([]float32, config.FRAMES_PER_BUFFER)
+var eventBus = utils.NewEventBus[PlayerEvent](4, 4)
 
-func Start(clipProvider func() Clip, normalize bool) {
-	eventBus = utils.NewEventBus[PlayerEvent](4, 4)
+// Start runs the player until the clip provider runs out of clips or Shutdown is called.
+// The mixed audio is sent to the given output sink.
+func Start(clipProvider func() Clip, sink output.Sink, normalize bool) {
+	// A clip that has been taken from the clip provider to look ahead.
+	// Both functions below are only called from the main loop.
+	var lookahead Clip
 
 	nextClipProvider := func() Clip {
 		if !userQueue.IsEmpty() {
 			clip, _ := userQueue.GetNext()
 			return clip
 		}
+		if lookahead != nil {
+			clip := lookahead
+			lookahead = nil
+			return clip
+		}
 		if clip := clipProvider(); clip != nil {
 			return clip
 		}
 		return nil
 	}
 
+	peekNextClip := func() Clip {
+		if clip := userQueue.Peek(); clip != nil {
+			return clip
+		}
+		if lookahead == nil {
+			lookahead = clipProvider()
+		}
+		return lookahead
+	}
+
+	// Closed when the main loop has ended, which ends the priority loop as well.
+	mainDone := make(chan struct{})
+
 	// Default & priority playback loops
-	priorityLoop := NewPlaybackLoop("Priority Loop", false, func() Clip { return <-priorityQueue })
+	priorityLoop := NewPlaybackLoop("Priority Loop", false, func() Clip {
+		select {
+		case clip := <-priorityQueue:
+			return clip
+		case <-shutdownSignal:
+			return nil
+		case <-mainDone:
+			return nil
+		}
+	})
+	priorityLoop.ClipStartCallback = func(clip Clip) {
+		priorityMode.Store(int32(clip.(*priorityClip).mode))
+	}
 	mainLoop = NewPlaybackLoop("Main Loop", normalize, nextClipProvider)
+	mainLoop.crossfade = crossfadeDuration
+	mainLoop.clipPeeker = peekNextClip
+	mainLoop.ProgressCallback = func(clip Clip, position time.Duration) {
+		eventBus.Publish(&ProgressEvent{
+			CurrentClip: clip,
+			Position:    position,
+		})
+	}
 	mainLoop.ClipStartCallback = func(clip Clip) {
 		log.Printf("Now playing %s", clip.Name())
 		eventBus.Publish(&NowPlayingEvent{
@@ -50,48 +89,49 @@ func Start(clipProvider func() Clip, normalize bool) {
 		})
 	}
 
-	playCallback := func(out [][]float32) {
-		// Check priority queue first:
-		select {
-		case chunk := <-priorityLoop.NextAudioChunk:
-			copy(out[0], chunk.Left)
-			copy(out[1], chunk.Right)
-			// Priority chunks should replace normal ones.
-			// Otherwise you would hear the remaining chunks after a pause beep.
-			utils.DropOne(mainLoop.NextAudioChunk)
-			return
-		default:
-			// No priority clips.
+	outputSink.Store(&sink)
+	log.Printf("Audio output: %s", sink.Name())
+	if err := sink.Start(newMixer(priorityLoop, mainLoop)); err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := sink.Close(); err != nil {
+			log.Printf("Failed to close audio output: %v", err)
 		}
+	}()
 
-		// There are no priority clips so we proceed with the main queue:
-		select {
-		case chunk := <-mainLoop.NextAudioChunk:
-			copy(out[0], chunk.Left)
-			copy(out[1], chunk.Right)
-		default:
-			// Handle underflow (e.g., fill with silence)
-			copy(out[0], zeroByteSlice)
-			copy(out[1], zeroByteSlice)
+	mainLoop.clipEndCallback = func(clip Clip, skipped bool) {
+		underflows := mainLoop.takeUnderflows()
+		if underflows > 0 {
+			log.Printf("%d underflows while playing %s", underflows, clip.Name())
 		}
+		err := clipError(clip)
+		if err != nil {
+			log.Printf("Skipped %s: %v", clip.Name(), err)
+			eventBus.Publish(&ClipErrorEvent{CurrentClip: clip, Error: err})
+		}
+		addClipToHistory(clip, skipped || err != nil, underflows, err)
 	}
 
-	stream := initPortAudioStream(playCallback)
+	go reportUnderflows()
 
-	defer portaudio.Terminate()
-	defer stream.Close()
+	priorityDone := make(chan struct{})
+	go func() {
+		priorityLoop.Run()
+		close(priorityDone)
+	}()
+	mainLoop.Run()
 
-	if err := stream.Start(); err != nil {
-		log.Fatal(err)
-	}
-	defer stream.Stop()
+	// The priority loop might still be stopping its clip or (without a shutdown) play it to the end.
+	close(mainDone)
+	<-priorityDone
 
-	mainLoop.clipEndCallback = func(clip Clip, skipped bool) {
-		addClipToHistory(clip, skipped)
+	if isShuttingDown() {
+		if lookahead != nil {
+			lookahead.Stop()
+		}
+		stopQueuedClips()
 	}
-
-	go priorityLoop.Run()
-	mainLoop.Run()
 }
 
 func QueueClip(clip Clip) {
@@ -116,6 +156,14 @@ func GetCurrentlyPlaying() Clip {
 	return mainLoop.GetCurrentClip()
 }
 
+// GetPosition returns how far the currently playing clip has progressed.
+func GetPosition() time.Duration {
+	if mainLoop == nil {
+		return 0
+	}
+	return mainLoop.GetPosition()
+}
+
 func SkipCurrent(silent bool) {
 	if mainLoop == nil {
 		// This should not happen...
@@ -127,13 +175,39 @@ func SkipCurrent(silent bool) {
 	}
 
 	mainLoop.Skip()
+
+	// Skipping while paused continues with the next clip. The chunks of the skipped
+	// clip are discarded before the output takes them (the loop does so as well).
+	if IsPaused() {
+		mainLoop.discardBufferedChunks()
+	}
+	Resume()
 }
 
+// PlayPriorityClip plays the clip over the main loop, which is ducked in the meantime.
 func PlayPriorityClip(clip Clip) {
+	PlayPriorityClipWithMode(clip, DuckMain)
+}
+
+// PlayPriorityClipWithMode plays the clip as soon as possible, either replacing or ducking the main loop.
+func PlayPriorityClipWithMode(clip Clip, mode PriorityMode) {
 	if clip == nil {
 		return
 	}
-	priorityQueue <- clip
+	select {
+	case priorityQueue <- &priorityClip{Clip: clip, mode: mode}:
+	case <-shutdownSignal:
+		// Nobody is going to play it.
+		clip.Stop()
+	}
+}
+
+// GetOutput returns the sink the player sends its output to (nil before Start).
+func GetOutput() output.Sink {
+	if sink := outputSink.Load(); sink != nil {
+		return *sink
+	}
+	return nil
 }
 
 func SetBeepProvider(provider func() Clip) {
@@ -1,5 +1,7 @@
 package player
 
+import "time"
+
 type PlayerEvent interface {
 	Type() string
 }
@@ -12,3 +14,54 @@ type NowPlayingEvent struct {
 func (event NowPlayingEvent) Type() string {
 	return "now-playing"
 }
+
+// ProgressEvent is published periodically while a clip is playing.
+type ProgressEvent struct {
+	CurrentClip Clip
+	Position    time.Duration
+}
+
+func (event ProgressEvent) Type() string {
+	return "progress"
+}
+
+// VolumeChangedEvent is published when the master volume has been changed.
+type VolumeChangedEvent struct {
+	Volume float32
+}
+
+func (event VolumeChangedEvent) Type() string {
+	return "volume-changed"
+}
+
+// UnderflowEvent is published when the output had to be filled with silence
+// because the main loop did not provide audio in time.
+type UnderflowEvent struct {
+	CurrentClip Clip
+	Total       uint64
+	ReduceCPU   bool
+}
+
+func (event UnderflowEvent) Type() string {
+	return "underflow"
+}
+
+// ClipErrorEvent is published when a clip of the main loop failed and has been skipped.
+type ClipErrorEvent struct {
+	CurrentClip Clip
+	Error       error
+}
+
+func (event ClipErrorEvent) Type() string {
+	return "clip-error"
+}
+
+// SleepTimerEvent is published when the sleep timer changes and once per second while it is running.
+type SleepTimerEvent struct {
+	// Time until the playback is paused (0 = no sleep timer).
+	Remaining time.Duration
+}
+
+func (event SleepTimerEvent) Type() string {
+	return "sleep-timer"
+}
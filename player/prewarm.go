@@ -0,0 +1,64 @@
+package player
+
+import "time"
+
+// PreparableClip is implemented by clips that need some time before they can deliver
+// audio, for example because a decoding process has to be started first.
+type PreparableClip interface {
+	Clip
+
+	// Prepare starts buffering in the background. It must not block and
+	// calling it multiple times must be safe.
+	Prepare()
+}
+
+// The next clip is prepared when the current one has less than this time left
+// (in addition to the crossfade duration).
+const prewarmTime = 5 * time.Second
+
+// prewarmNextClip prepares the upcoming clip shortly before the current one ends,
+// so that consecutive clips play back-to-back without an audible gap.
+func (loop *PlaybackLoop) prewarmNextClip(current *clipPlayback) {
+	duration := current.clip.Duration()
+	if duration <= 0 || duration-current.position > prewarmTime+loop.crossfade {
+		// E.g. after seeking back
+		loop.releasePreparedClip()
+		return
+	}
+
+	next := loop.peekClip()
+	if next == loop.preparedClip {
+		return
+	}
+
+	// The prepared clip is no longer the next one (e.g. another clip has been queued).
+	loop.releasePreparedClip()
+	if next == nil {
+		return
+	}
+
+	loop.preparedClip = next
+	if preparable, ok := next.(PreparableClip); ok {
+		go preparable.Prepare()
+	}
+}
+
+// releasePreparedClip suspends the clip that has been prepared ahead of time, so that
+// its decoder does not keep running while the clip waits. It is prepared again when it is up next.
+func (loop *PlaybackLoop) releasePreparedClip() {
+	if clip, ok := loop.preparedClip.(SuspendableClip); ok {
+		clip.Suspend(0)
+	}
+	loop.preparedClip = nil
+}
+
+// peekClip returns the clip that will be played next without removing it.
+func (loop *PlaybackLoop) peekClip() Clip {
+	if loop.pendingClip != nil {
+		return loop.pendingClip
+	}
+	if loop.clipPeeker == nil {
+		return nil
+	}
+	return loop.clipPeeker()
+}
@@ -0,0 +1,99 @@
+package player
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextClipIsPreparedAhead(t *testing.T) {
+	next := &preparableClip{chunkClip: chunkClip{chunks: 2}, prepared: make(chan struct{})}
+	queue := []Clip{&chunkClip{chunks: 10}, next}
+
+	loop := NewPlaybackLoop("Test", false, func() Clip {
+		if len(queue) == 0 {
+			return nil
+		}
+		clip := queue[0]
+		queue = queue[1:]
+		return clip
+	})
+	loop.clipPeeker = func() Clip {
+		if len(queue) == 0 {
+			return nil
+		}
+		return queue[0]
+	}
+
+	done := make(chan struct{})
+	go func() {
+		loop.Run()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-loop.NextAudioChunk:
+			continue
+		case <-done:
+		}
+		break
+	}
+
+	select {
+	case <-next.prepared:
+	case <-time.After(time.Second):
+		t.Fatal("The next clip was not prepared")
+	}
+}
+
+type preparableClip struct {
+	chunkClip
+	prepared chan struct{}
+}
+
+func (clip *preparableClip) Prepare() { close(clip.prepared) }
+
+func TestPreparedClipIsSuspendedWhenItIsNoLongerNext(t *testing.T) {
+	var peeked Clip
+	loop := NewPlaybackLoop("Test", false, nil)
+	loop.clipPeeker = func() Clip { return peeked }
+
+	first, second := &suspendableClip{}, &suspendableClip{}
+	ending := &clipPlayback{clip: &chunkClip{chunks: 10}}
+
+	peeked = first
+	loop.prewarmNextClip(ending)
+
+	// Another clip has been queued in front of the prepared one.
+	peeked = second
+	loop.prewarmNextClip(ending)
+	if !first.suspended || second.suspended {
+		t.Errorf("Expected only the first clip to be suspended, first: %v, second: %v", first.suspended, second.suspended)
+	}
+
+	// The prepared clip starts playing.
+	loop.startClip(second)
+	peeked = nil
+	loop.prewarmNextClip(ending)
+	if second.suspended {
+		t.Error("Expected the playing clip not to be suspended")
+	}
+
+	// Seeking away from the end of the current clip
+	peeked = first
+	first.suspended = false
+	loop.prewarmNextClip(ending)
+	loop.prewarmNextClip(&clipPlayback{clip: &chunkClip{chunks: 10000}})
+	if !first.suspended {
+		t.Error("Expected the prepared clip to be suspended outside of the prewarm time")
+	}
+}
+
+type suspendableClip struct {
+	chunkClip
+	suspended bool
+}
+
+func (clip *suspendableClip) Prepare() {}
+
+func (clip *suspendableClip) Suspend(position time.Duration) { clip.suspended = true }
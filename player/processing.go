@@ -0,0 +1,43 @@
+package player
+
+import "sync/atomic"
+
+// ChunkProcessor modifies audio chunks in place, e.g. an equalizer or a limiter.
+// Processors are called from the audio thread, so they must be fast and never block.
+type ChunkProcessor interface {
+	Process(chunk *AudioChunk)
+}
+
+// ProcessorChain applies its processors in order.
+type ProcessorChain []ChunkProcessor
+
+func (chain ProcessorChain) Process(chunk *AudioChunk) {
+	for _, processor := range chain {
+		processor.Process(chunk)
+	}
+}
+
+// The processors applied to the mixed output (before the volume).
+var processors atomic.Pointer[ProcessorChain]
+
+// SetProcessors replaces the processors applied to the output. It can be called at any time.
+// The chain must not be modified afterwards.
+func SetProcessors(chain ProcessorChain) {
+	processors.Store(&chain)
+}
+
+// process applies the current processor chain to the output buffers.
+func (state *mixerState) process(out [][]float32) {
+	chain := processors.Load()
+	if chain == nil || len(*chain) == 0 {
+		return
+	}
+
+	// Wrap the output buffers without allocating a new chunk.
+	state.output.Channels = out
+	state.output.Length = len(out[0])
+	state.output.RMS = 0
+	state.output.Peak = 0
+
+	chain.Process(&state.output)
+}
@@ -0,0 +1,100 @@
+package player
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tim-we/wavestreamer/utils"
+)
+
+// Seek requests are executed by the playback loop. If the loop does not pick them up
+// within this time (e.g. because it is waiting for a clip) the request is abandoned.
+const seekTimeout = 2 * time.Second
+
+type seekRequest struct {
+	position time.Duration
+	relative bool
+	deadline time.Time
+	result   chan error
+}
+
+// Seek jumps to the given position in the currently playing clip.
+func Seek(position time.Duration) error {
+	if mainLoop == nil {
+		return errors.New("player has not been started")
+	}
+	return mainLoop.Seek(position, false)
+}
+
+// SeekRelative jumps forward (positive offset) or backward (negative offset) in the currently playing clip.
+func SeekRelative(offset time.Duration) error {
+	if mainLoop == nil {
+		return errors.New("player has not been started")
+	}
+	return mainLoop.Seek(offset, true)
+}
+
+// Seek asks the loop to change the playback position of the current clip and waits for the result.
+func (loop *PlaybackLoop) Seek(position time.Duration, relative bool) error {
+	request := seekRequest{
+		position: position,
+		relative: relative,
+		deadline: time.Now().Add(seekTimeout),
+		result:   make(chan error, 1),
+	}
+
+	select {
+	case loop.seekSignal <- request:
+	default:
+		return errors.New("another seek is in progress")
+	}
+
+	select {
+	case err := <-request.result:
+		return err
+	case <-time.After(seekTimeout):
+		return errors.New("seek timed out")
+	}
+}
+
+// handleSeek is called by the loop itself, so it is safe to modify the playback state.
+func (loop *PlaybackLoop) handleSeek(current *clipPlayback, crossfading bool, request seekRequest) {
+	if time.Now().After(request.deadline) {
+		// Nobody is waiting for the result anymore.
+		return
+	}
+
+	if crossfading {
+		request.result <- errors.New("cannot seek during a crossfade")
+		return
+	}
+
+	if !current.clip.CanSeek() {
+		request.result <- ErrSeekNotSupported
+		return
+	}
+
+	position := request.position
+	if request.relative {
+		position += current.position
+	}
+	position = max(0, position)
+	if duration := current.clip.Duration(); duration > 0 {
+		position = min(position, duration)
+	}
+
+	if err := current.clip.Seek(position); err != nil {
+		request.result <- err
+		return
+	}
+
+	current.position = position
+	// Make sure a progress update is sent for the new position.
+	loop.lastProgress = -time.Hour
+
+	// Drop the chunks from before the seek that have not been played yet.
+	for utils.TryDropOne(loop.NextAudioChunk) {
+	}
+
+	request.result <- nil
+}
@@ -0,0 +1,67 @@
+package player
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeekSkipsChunks(t *testing.T) {
+	clip := &chunkClip{chunks: 100, value: 0.5}
+	provided := false
+
+	loop := NewPlaybackLoop("Test", false, func() Clip {
+		if provided {
+			return nil
+		}
+		provided = true
+		return clip
+	})
+
+	done := make(chan struct{})
+	go func() {
+		loop.Run()
+		close(done)
+	}()
+
+	<-loop.NextAudioChunk
+
+	result := make(chan error, 1)
+	go func() { result <- loop.Seek(90*chunkDuration(), false) }()
+
+	// Wait until the request has been submitted (or already handled).
+	for len(loop.seekSignal) == 0 && len(result) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Keep consuming so the loop can process the request.
+	received := 0
+	for {
+		select {
+		case <-loop.NextAudioChunk:
+			received++
+			continue
+		case <-done:
+		}
+		break
+	}
+
+	if err := <-result; err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+
+	if received > 15 {
+		t.Errorf("Expected about 10 chunks after seeking, got %d", received)
+	}
+}
+
+func TestSeekNotSupported(t *testing.T) {
+	loop := NewPlaybackLoop("Test", false, nil)
+	playback := &clipPlayback{clip: &testClip{}}
+
+	request := seekRequest{result: make(chan error, 1), deadline: time.Now().Add(time.Minute)}
+	loop.handleSeek(playback, false, request)
+
+	if err := <-request.result; err != ErrSeekNotSupported {
+		t.Errorf("Expected ErrSeekNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package player
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Closed when the player shuts down, which stops both playback loops.
+var shutdownSignal = make(chan struct{})
+var shutdownOnce sync.Once
+
+// Duration of the fade out while shutting down (0 = not shutting down).
+// It is read by the audio thread.
+var shutdownFade atomic.Int64
+
+// Shutdown fades out the output and stops both playback loops, which makes Start return.
+// The clips that are playing or waiting in the queues are stopped, so that their decoding
+// processes and temporary files do not outlive the player. It blocks until the fade is done.
+func Shutdown(fade time.Duration) {
+	shutdownOnce.Do(func() {
+		log.Println("Stopping playback...")
+
+		if mainLoop != nil && !IsPaused() && fade > 0 {
+			shutdownFade.Store(int64(fade))
+			// Also wait for the chunks that are still buffered by the loops.
+			time.Sleep(fade + 2*chunkDuration())
+		}
+
+		close(shutdownSignal)
+	})
+}
+
+func isShuttingDown() bool {
+	select {
+	case <-shutdownSignal:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopQueuedClips stops all clips that are waiting to be played.
+func stopQueuedClips() {
+	for _, clip := range userQueue.Clear() {
+		clip.Stop()
+	}
+
+	for {
+		select {
+		case clip := <-priorityQueue:
+			clip.Stop()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package player
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShutdownStopsLoopAndQueuedClips(t *testing.T) {
+	oldSignal := shutdownSignal
+	shutdownSignal = make(chan struct{})
+	defer func() { shutdownSignal = oldSignal }()
+
+	current := &chunkClip{chunks: 1000, value: 0.5}
+	queued := &chunkClip{chunks: 10, value: 0.5}
+	userQueue.Add(queued)
+
+	loop := NewPlaybackLoop("Test", false, func() Clip { return current })
+	done := make(chan struct{})
+	go func() {
+		loop.Run()
+		close(done)
+	}()
+
+	// Pausing must not keep the loop from stopping.
+	<-loop.NextAudioChunk
+	loop.SetPaused(true)
+
+	close(shutdownSignal)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("The loop did not stop")
+	}
+
+	stopQueuedClips()
+
+	if !current.stopped || !queued.stopped {
+		t.Errorf("Expected all clips to be stopped, current: %v, queued: %v", current.stopped, queued.stopped)
+	}
+	if !userQueue.IsEmpty() {
+		t.Errorf("Expected the queue to be empty, got %d clips", userQueue.Size())
+	}
+}
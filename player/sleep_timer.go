@@ -0,0 +1,136 @@
+package player
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The output is faded out over the last minute of the sleep timer.
+const sleepTimerFade = time.Minute
+
+// Deadline of the sleep timer in Unix nanoseconds (0 = not set). It is read by the audio thread.
+var sleepDeadline atomic.Int64
+
+var sleepTimerMutex sync.Mutex
+
+// Stops the countdown of the current sleep timer (nil if there is none).
+var sleepTimerStop chan struct{}
+
+// SetSleepTimer pauses the playback after the given duration. The output is faded out over
+// the last minute. An existing sleep timer is replaced.
+func SetSleepTimer(duration time.Duration) error {
+	if duration <= 0 {
+		return errors.New("sleep timer duration must be positive")
+	}
+
+	sleepTimerMutex.Lock()
+	defer sleepTimerMutex.Unlock()
+
+	setSleepDeadline(time.Now().Add(duration))
+	log.Printf("Sleep timer set, pausing in %v.", duration.Round(time.Second))
+
+	return nil
+}
+
+// ExtendSleepTimer adds the given duration to the sleep timer. If no sleep timer is set, a new one is started.
+func ExtendSleepTimer(duration time.Duration) error {
+	if duration <= 0 {
+		return errors.New("sleep timer extension must be positive")
+	}
+
+	sleepTimerMutex.Lock()
+	defer sleepTimerMutex.Unlock()
+
+	remaining := GetSleepTimer() + duration
+	setSleepDeadline(time.Now().Add(remaining))
+	log.Printf("Sleep timer extended, pausing in %v.", remaining.Round(time.Second))
+
+	return nil
+}
+
+// CancelSleepTimer stops the sleep timer. If the output is already fading out, the volume is restored.
+func CancelSleepTimer() {
+	sleepTimerMutex.Lock()
+	defer sleepTimerMutex.Unlock()
+
+	if sleepTimerStop == nil {
+		return
+	}
+
+	close(sleepTimerStop)
+	sleepTimerStop = nil
+	sleepDeadline.Store(0)
+
+	log.Println("Sleep timer cancelled.")
+	eventBus.Publish(&SleepTimerEvent{})
+}
+
+// GetSleepTimer returns the time until the sleep timer pauses the playback (0 = no sleep timer).
+func GetSleepTimer() time.Duration {
+	deadline := sleepDeadline.Load()
+	if deadline == 0 {
+		return 0
+	}
+	return max(0, time.Until(time.Unix(0, deadline)))
+}
+
+// setSleepDeadline updates the deadline and starts the countdown if necessary.
+// The caller must hold the sleepTimerMutex.
+func setSleepDeadline(deadline time.Time) {
+	sleepDeadline.Store(deadline.UnixNano())
+
+	if sleepTimerStop == nil {
+		sleepTimerStop = make(chan struct{})
+		go runSleepTimer(sleepTimerStop)
+	}
+
+	eventBus.Publish(&SleepTimerEvent{Remaining: GetSleepTimer()})
+}
+
+// runSleepTimer publishes the remaining time once per second and pauses the playback when the timer expires.
+func runSleepTimer(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if remaining := GetSleepTimer(); remaining > 0 {
+			eventBus.Publish(&SleepTimerEvent{Remaining: remaining})
+			continue
+		}
+
+		sleepTimerMutex.Lock()
+		if sleepTimerStop != stop || GetSleepTimer() > 0 {
+			// Cancelled or extended in the meantime.
+			sleepTimerMutex.Unlock()
+			continue
+		}
+
+		log.Println("Sleep timer expired.")
+		if err := Pause(); err != nil {
+			log.Printf("Failed to pause: %v", err)
+		}
+		sleepTimerStop = nil
+		sleepDeadline.Store(0)
+		sleepTimerMutex.Unlock()
+
+		eventBus.Publish(&SleepTimerEvent{})
+		return
+	}
+}
+
+// sleepTimerFactor returns the factor applied to the volume while the sleep timer fades out the output.
+func sleepTimerFactor() float32 {
+	if sleepDeadline.Load() == 0 {
+		return 1
+	}
+	return float32(min(1, GetSleepTimer().Seconds()/sleepTimerFade.Seconds()))
+}
@@ -0,0 +1,40 @@
+package player
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSleepTimerFadesOutOverTheLastMinute(t *testing.T) {
+	defer CancelSleepTimer()
+
+	if factor := sleepTimerFactor(); factor != 1 {
+		t.Errorf("Expected factor 1 without a sleep timer, got %f", factor)
+	}
+
+	if err := SetSleepTimer(30 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if factor := sleepTimerFactor(); factor < 0.45 || factor > 0.5 {
+		t.Errorf("Expected a factor of about 0.5 with 30s left, got %f", factor)
+	}
+
+	if err := ExtendSleepTimer(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := GetSleepTimer(); remaining < 89*time.Second || remaining > 90*time.Second {
+		t.Errorf("Expected 90s left after extending, got %v", remaining)
+	}
+	if factor := sleepTimerFactor(); factor != 1 {
+		t.Errorf("Expected factor 1 before the fade, got %f", factor)
+	}
+
+	CancelSleepTimer()
+	if remaining, factor := GetSleepTimer(), sleepTimerFactor(); remaining != 0 || factor != 1 {
+		t.Errorf("Expected the timer to be cancelled, got %v left and factor %f", remaining, factor)
+	}
+
+	if err := SetSleepTimer(0); err == nil {
+		t.Error("Expected an error for an empty duration")
+	}
+}
@@ -0,0 +1,260 @@
+package player
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player/output"
+)
+
+// timeShiftBuffer keeps the most recent mix in memory as 16-bit PCM (interleaved, little endian).
+// It is recorded before the processing and the volume, as replays are processed again when they are played.
+type timeShiftBuffer struct {
+	// PCM data from the audio thread (buffers from timeShiftPool), stored by run.
+	// The audio thread never waits for the lock, e.g. while a download copies the buffer.
+	pcm chan *[]byte
+
+	mu   sync.Mutex
+	data []byte
+	pos  int // next write position
+	size int // number of valid bytes
+}
+
+var timeShift *timeShiftBuffer
+
+// SetTimeShift keeps the given length of the output in memory, so that it can be replayed
+// (see ReplayLast). It must be called before Start.
+func SetTimeShift(length time.Duration) {
+	if length <= 0 {
+		return
+	}
+	timeShift = newTimeShiftBuffer(length)
+	go timeShift.run()
+	AddMixTap(timeShift.write)
+}
+
+func newTimeShiftBuffer(length time.Duration) *timeShiftBuffer {
+	return &timeShiftBuffer{
+		pcm:  make(chan *[]byte, 64),
+		data: make([]byte, durationToBytes(length)),
+	}
+}
+
+// durationToBytes returns the size of the 16-bit PCM data of the given duration.
+func durationToBytes(duration time.Duration) int {
+	return int(duration.Seconds()*float64(config.SAMPLE_RATE)) * 2 * config.CHANNELS
+}
+
+// PCM buffers are recycled once they have been stored, so that the audio thread
+// does not allocate for every buffer.
+var timeShiftPool = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// write is a mix tap. It passes the output on to run without blocking.
+func (b *timeShiftBuffer) write(out [][]float32) {
+	buffer := timeShiftPool.Get().(*[]byte)
+	*buffer = output.AppendPCM16((*buffer)[:0], out)
+
+	select {
+	case b.pcm <- buffer:
+	default:
+		// The buffer has been locked for too long, drop audio rather than stalling the output.
+		timeShiftPool.Put(buffer)
+	}
+}
+
+// run stores the output passed on by write.
+func (b *timeShiftBuffer) run() {
+	for buffer := range b.pcm {
+		b.store(*buffer)
+		timeShiftPool.Put(buffer)
+	}
+}
+
+func (b *timeShiftBuffer) store(data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(data) > len(b.data) {
+		data = data[len(data)-len(b.data):]
+	}
+
+	n := copy(b.data[b.pos:], data)
+	copy(b.data, data[n:])
+	b.pos = (b.pos + len(data)) % len(b.data)
+	b.size = min(len(b.data), b.size+len(data))
+}
+
+// last returns up to the given length of the most recent output in chronological order.
+func (b *timeShiftBuffer) last(length time.Duration) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size := min(durationToBytes(length), b.size)
+	result := make([]byte, size)
+
+	start := (b.pos - size + len(b.data)) % len(b.data)
+	n := copy(result, b.data[start:])
+	copy(result[n:], b.data)
+
+	return result
+}
+
+// GetRecentOutput returns up to the given length of the most recent output (before the
+// processing and the volume) as 16-bit PCM (interleaved, little endian).
+func GetRecentOutput(length time.Duration) ([]byte, error) {
+	if timeShift == nil {
+		return nil, errors.New("time-shift buffer is disabled")
+	}
+	return timeShift.last(length), nil
+}
+
+// ReplayLast plays the given length of the most recent output again. The main loop
+// is paused in the meantime, so that no music is missed.
+func ReplayLast(length time.Duration) error {
+	pcm, err := GetRecentOutput(length)
+	if err != nil {
+		return err
+	}
+	if len(pcm) == 0 {
+		return errors.New("nothing to replay")
+	}
+
+	if err := holdForReplay(); err != nil {
+		return err
+	}
+	clip := &replayClip{pcm: pcm, onEnd: releaseReplayHold}
+
+	log.Printf("Replaying the last %v.", clip.Duration().Round(time.Second))
+	PlayPriorityClipWithMode(clip, ReplaceMain)
+
+	return nil
+}
+
+// Replays that are playing or queued. The first one pauses the main loop, which is resumed
+// once the last one has ended (unless it was paused before or has been paused in the meantime).
+var replays struct {
+	sync.Mutex
+	count  int
+	resume bool
+}
+
+// Whether a replay holds back the main loop, read by the mixer.
+var replaying atomic.Bool
+
+func holdForReplay() error {
+	replays.Lock()
+	defer replays.Unlock()
+
+	if replays.count == 0 {
+		replays.resume = !IsPaused()
+		if replays.resume {
+			if err := pause(); err != nil {
+				return err
+			}
+		}
+	}
+
+	replays.count++
+	replaying.Store(true)
+	return nil
+}
+
+func releaseReplayHold() {
+	replays.Lock()
+	defer replays.Unlock()
+
+	replays.count--
+	if replays.count > 0 {
+		return
+	}
+
+	replaying.Store(false)
+	if replays.resume {
+		Resume()
+	}
+}
+
+// keepPausedAfterReplay is called when the playback is paused explicitly.
+func keepPausedAfterReplay() {
+	replays.Lock()
+	defer replays.Unlock()
+
+	replays.resume = false
+}
+
+// replayClip plays a section of the output again.
+type replayClip struct {
+	pcm      []byte
+	position int
+	stopped  atomic.Bool
+
+	// Called once when the clip has been played completely or has been stopped.
+	onEnd func()
+	ended sync.Once
+}
+
+func (clip *replayClip) NextChunk() (*AudioChunk, bool) {
+	frameSize := 2 * config.CHANNELS
+	if clip.stopped.Load() || clip.position+frameSize > len(clip.pcm) {
+		clip.end()
+		return nil, false
+	}
+
+	chunk := GetAudioChunk()
+	chunk.Length = min(config.FRAMES_PER_BUFFER, (len(clip.pcm)-clip.position)/frameSize)
+
+	for i := range chunk.Length {
+		for _, samples := range chunk.Channels {
+			samples[i] = float32(int16(binary.LittleEndian.Uint16(clip.pcm[clip.position:]))) / 32768
+			clip.position += 2
+		}
+	}
+
+	return chunk, true
+}
+
+func (clip *replayClip) Stop() {
+	clip.stopped.Store(true)
+	// Stopped clips might never be asked for another chunk.
+	clip.end()
+}
+
+func (clip *replayClip) end() {
+	clip.ended.Do(func() {
+		if clip.onEnd != nil {
+			clip.onEnd()
+		}
+	})
+}
+
+func (clip *replayClip) Name() string {
+	return "Replay"
+}
+
+func (clip *replayClip) Duration() time.Duration {
+	frames := len(clip.pcm) / (2 * config.CHANNELS)
+	return time.Duration(frames) * time.Second / time.Duration(config.SAMPLE_RATE)
+}
+
+func (clip *replayClip) Duplicate() Clip {
+	return &replayClip{pcm: clip.pcm}
+}
+
+func (clip *replayClip) Hidden() bool {
+	return true
+}
+
+func (clip *replayClip) CanSeek() bool {
+	return false
+}
+
+func (clip *replayClip) Seek(position time.Duration) error {
+	return ErrSeekNotSupported
+}
@@ -0,0 +1,153 @@
+package player
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player/output"
+)
+
+func TestTimeShiftBufferKeepsRecentOutput(t *testing.T) {
+	// Room for 2.5 chunks
+	buffer := newTimeShiftBuffer(5 * chunkDuration() / 2)
+
+	out := NewAudioChunk().Channels
+	for value := range 4 {
+		for _, samples := range out {
+			for i := range samples {
+				samples[i] = float32(value) / 8
+			}
+		}
+		buffer.store(output.AppendPCM16(nil, out))
+	}
+
+	clip := &replayClip{pcm: buffer.last(time.Hour)}
+	ended := false
+	clip.onEnd = func() { ended = true }
+
+	if clip.Duration() < 2*chunkDuration() || clip.Duration() > 3*chunkDuration() {
+		t.Errorf("Expected a duration of 2.5 chunks, got %v", clip.Duration())
+	}
+
+	// The oldest one and a half chunks have been overwritten.
+	var samples []float32
+	for range 3 {
+		chunk, ok := clip.NextChunk()
+		if !ok {
+			t.Fatal("The clip ended early")
+		}
+		samples = append(samples, chunk.Channels[0][:chunk.Length]...)
+	}
+
+	if first, last := samples[0], samples[len(samples)-1]; first != 1.0/8 || last != 3.0/8 {
+		t.Errorf("Expected the samples to go from %f to %f, got %f to %f", 1.0/8, 3.0/8, first, last)
+	}
+
+	if _, ok := clip.NextChunk(); ok || !ended {
+		t.Errorf("Expected the clip to end, more: %v, ended: %v", ok, ended)
+	}
+}
+
+func TestReplaysHoldTheMainLoop(t *testing.T) {
+	defer func(loop *PlaybackLoop) { mainLoop = loop }(mainLoop)
+	mainLoop = NewPlaybackLoop("Main", false, nil)
+
+	first, second := &replayClip{onEnd: releaseReplayHold}, &replayClip{onEnd: releaseReplayHold}
+	for range 2 {
+		if err := holdForReplay(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !IsPaused() || !replaying.Load() {
+		t.Fatal("Expected the replays to pause the main loop")
+	}
+
+	// The first replay ends while the second one is queued.
+	if _, ok := first.NextChunk(); ok {
+		t.Fatal("Expected the empty replay to end")
+	}
+	if !IsPaused() {
+		t.Error("Expected the main loop to stay paused until the last replay has ended")
+	}
+
+	// Stopped replays end as well (only once).
+	second.Stop()
+	second.NextChunk()
+	if IsPaused() || replaying.Load() {
+		t.Error("Expected the main loop to resume after the last replay")
+	}
+
+	// Paused explicitly during the replay
+	holdForReplay()
+	Pause()
+	releaseReplayHold()
+	if !IsPaused() {
+		t.Error("Expected the main loop to stay paused")
+	}
+}
+
+func TestMixerKeepsMainChunksDuringReplays(t *testing.T) {
+	priorityLoop := NewPlaybackLoop("Priority", false, nil)
+	mainLoop := NewPlaybackLoop("Main", false, nil)
+
+	sink := output.NewMemorySink()
+	sink.Start(newMixer(priorityLoop, mainLoop))
+
+	// The main loop has been resumed during the replay (e.g. by skipping).
+	replaying.Store(true)
+	defer replaying.Store(false)
+
+	mainLoop.NextAudioChunk <- constantChunk(0.5)
+	priorityLoop.NextAudioChunk <- constantChunk(0.25)
+	sink.Pull(1)
+	replaying.Store(false)
+	sink.Pull(1)
+
+	out := sink.Output()
+	if first, second := out[0][0], out[0][config.FRAMES_PER_BUFFER]; first != 0.25 || second != 0.5 {
+		t.Errorf("Expected the main chunk after the replay, got %v and %v", first, second)
+	}
+}
+
+func TestTimeShiftRecordsTheMixBeforeTheVolume(t *testing.T) {
+	defer func(taps []OutputTap) { mixTaps = taps }(mixTaps)
+	defer SetVolume(GetVolume())
+	SetVolume(0.5)
+
+	buffer := newTimeShiftBuffer(time.Second)
+	mixTaps = []OutputTap{buffer.write}
+
+	priorityLoop := NewPlaybackLoop("Priority", false, nil)
+	mainLoop := NewPlaybackLoop("Main", false, nil)
+	sink := output.NewMemorySink()
+	sink.Start(newMixer(priorityLoop, mainLoop))
+
+	mainLoop.NextAudioChunk <- constantChunk(0.5)
+	sink.Pull(1)
+
+	// Nothing has been stored yet, but the audio thread does not wait for it.
+	if size := len(buffer.last(time.Second)); size != 0 {
+		t.Errorf("Expected the output to be stored asynchronously, got %d bytes", size)
+	}
+
+	go buffer.run()
+	defer close(buffer.pcm)
+	deadline := time.Now().Add(time.Second)
+	for len(buffer.last(time.Second)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Replays are played at the volume again, so it must not be part of the recording.
+	clip := &replayClip{pcm: buffer.last(time.Second)}
+	chunk, ok := clip.NextChunk()
+	if !ok {
+		t.Fatal("Nothing has been recorded")
+	}
+	if recorded := chunk.Channels[0][0]; recorded != 0.5 {
+		t.Errorf("Expected the mix before the volume, got %v", recorded)
+	}
+	if played := sink.Output()[0][0]; played >= 0.5 {
+		t.Errorf("Expected the output to be played at the volume, got %v", played)
+	}
+}
@@ -0,0 +1,102 @@
+package player
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tim-we/wavestreamer/utils"
+)
+
+// UnderflowRecord describes a stutter, i.e. one or more consecutive output buffers
+// for which the main loop did not provide audio in time.
+type UnderflowRecord struct {
+	Time      time.Time `json:"time"`
+	Clip      string    `json:"clip"`
+	ReduceCPU bool      `json:"reduceCPU"` // whether the CPU load was being reduced at the time
+}
+
+// UnderflowStats is a snapshot of the underflow counters.
+type UnderflowStats struct {
+	Total       uint64
+	CurrentClip uint64
+	Recent      []UnderflowRecord
+}
+
+const underflowLogLength = 20
+
+var (
+	// Notifies the reporter about a new stutter. Sent from the audio thread without blocking.
+	underflowReports = make(chan struct{}, 1)
+
+	underflowMu  sync.Mutex
+	underflowLog []UnderflowRecord
+)
+
+// reportUnderflows logs stutters and publishes them on the event bus.
+// This is done outside of the audio thread because logging may block.
+func reportUnderflows() {
+	for range underflowReports {
+		clip := mainLoop.GetCurrentClip()
+		name := "-"
+		if clip != nil {
+			name = clip.Name()
+		}
+
+		record := UnderflowRecord{
+			Time:      time.Now(),
+			Clip:      name,
+			ReduceCPU: utils.ShouldReduceCPU(),
+		}
+
+		underflowMu.Lock()
+		underflowLog = append(underflowLog, record)
+		if len(underflowLog) > underflowLogLength {
+			underflowLog = underflowLog[1:]
+		}
+		underflowMu.Unlock()
+
+		total := mainLoop.underflowsTotal.Load()
+		log.Printf("Audio underflow while playing %s (%d in total, reduce CPU: %v)", name, total, record.ReduceCPU)
+
+		eventBus.Publish(&UnderflowEvent{
+			CurrentClip: clip,
+			Total:       total,
+			ReduceCPU:   record.ReduceCPU,
+		})
+	}
+}
+
+// GetUnderflowStats returns the number of output buffers that could not be filled in time.
+func GetUnderflowStats() UnderflowStats {
+	if mainLoop == nil {
+		return UnderflowStats{}
+	}
+
+	underflowMu.Lock()
+	defer underflowMu.Unlock()
+
+	return UnderflowStats{
+		Total:       mainLoop.underflowsTotal.Load(),
+		CurrentClip: mainLoop.underflows.Load(),
+		Recent:      append([]UnderflowRecord(nil), underflowLog...),
+	}
+}
+
+// countUnderflow is called by the mixer if the main loop did not provide a chunk in time.
+// Returns true if the loop was playing a clip, i.e. if the underflow is audible.
+func (loop *PlaybackLoop) countUnderflow() bool {
+	if !loop.playing.Load() {
+		// Waiting for the next clip.
+		return false
+	}
+
+	loop.underflows.Add(1)
+	loop.underflowsTotal.Add(1)
+	return true
+}
+
+// takeUnderflows returns the number of underflows since the last call and resets the counter.
+func (loop *PlaybackLoop) takeUnderflows() uint64 {
+	return loop.underflows.Swap(0)
+}
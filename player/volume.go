@@ -0,0 +1,81 @@
+package player
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Master volume in [0, 1], stored as float32 bits so that the audio thread can read it without locking.
+var volume atomic.Uint32
+
+func init() {
+	volume.Store(math.Float32bits(1.0))
+}
+
+// Time it takes to ramp the gain from silence to full volume (or vice versa).
+// Smaller changes take proportionally less time.
+const volumeRampDuration = 250 * time.Millisecond
+
+// Maximum gain change per output buffer.
+func volumeRampStep() float32 {
+	return float32(chunkDuration()) / float32(volumeRampDuration)
+}
+
+// Called synchronously after every change of the volume (see SetVolumeObserver).
+var volumeObserver func(value float32)
+
+// SetVolumeObserver registers a function that is called after every change of the volume,
+// e.g. to persist it. Unlike the events, no change is missed. It must be called before Start.
+func SetVolumeObserver(observer func(value float32)) {
+	volumeObserver = observer
+}
+
+// SetVolume changes the master volume (0 = muted, 1 = full volume).
+// The change is applied gradually to avoid audible clicks.
+func SetVolume(value float32) {
+	value = max(0, min(1, value))
+	old := math.Float32frombits(volume.Swap(math.Float32bits(value)))
+
+	if old != value {
+		if volumeObserver != nil {
+			volumeObserver(value)
+		}
+		eventBus.Publish(&VolumeChangedEvent{Volume: value})
+	}
+}
+
+// GetVolume returns the master volume in [0, 1].
+func GetVolume() float32 {
+	return math.Float32frombits(volume.Load())
+}
+
+// volumeToGain maps the volume to an amplitude gain. Squaring it matches
+// the perceived loudness better than a linear mapping.
+func volumeToGain(value float32) float32 {
+	return value * value
+}
+
+// volumeRamp applies the master volume to the output. It is only used from the audio thread.
+type volumeRamp struct {
+	gain float32
+}
+
+func newVolumeRamp() *volumeRamp {
+	return &volumeRamp{gain: volumeToGain(GetVolume())}
+}
+
+func (ramp *volumeRamp) apply(out [][]float32) {
+	target := volumeToGain(GetVolume() * sleepTimerFactor())
+	start := ramp.gain
+	step := volumeRampStep()
+	if fade := shutdownFade.Load(); fade > 0 {
+		// Shutting down, fade out regardless of the volume.
+		target = 0
+		step = float32(chunkDuration()) / float32(fade)
+	}
+	end := start + max(-step, min(step, target-start))
+	ramp.gain = end
+
+	applyGainRamp(out, start, end)
+}
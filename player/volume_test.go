@@ -0,0 +1,56 @@
+package player
+
+import (
+	"testing"
+
+	"github.com/tim-we/wavestreamer/config"
+)
+
+func TestVolumeIsRampedSmoothly(t *testing.T) {
+	SetVolume(1)
+	defer SetVolume(1)
+
+	ramp := newVolumeRamp()
+	SetVolume(0)
+
+	out := NewAudioChunk().Channels
+
+	previous := float32(1)
+	for buffer := 0; ramp.gain > 0; buffer++ {
+		if buffer > 100 {
+			t.Fatal("The volume never reached the target")
+		}
+
+		for _, channel := range out {
+			for i := range channel {
+				channel[i] = 1
+			}
+		}
+		ramp.apply(out)
+
+		for _, sample := range out[0] {
+			if sample > previous || previous-sample > 2*volumeRampStep()/float32(config.FRAMES_PER_BUFFER) {
+				t.Fatalf("Volume did not decrease smoothly: %v after %v", sample, previous)
+			}
+			previous = sample
+		}
+	}
+}
+
+func TestVolumeObserverSeesEveryChange(t *testing.T) {
+	SetVolume(1)
+	defer SetVolume(1)
+
+	var changes []float32
+	SetVolumeObserver(func(value float32) { changes = append(changes, value) })
+	defer SetVolumeObserver(nil)
+
+	// More changes than the event bus buffers, and one without an effect.
+	for _, value := range []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.6} {
+		SetVolume(value)
+	}
+
+	if len(changes) != 6 || changes[5] != 0.6 {
+		t.Errorf("Expected 6 changes up to 0.6, got %v", changes)
+	}
+}
@@ -0,0 +1,266 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tim-we/wavestreamer/library"
+	"github.com/tim-we/wavestreamer/player"
+	"github.com/tim-we/wavestreamer/player/clips"
+	"github.com/tim-we/wavestreamer/state"
+)
+
+// Alarms are checked this often, i.e. an alarm goes off at most this late.
+const alarmCheckInterval = 10 * time.Second
+
+// Alarms that have been missed by more than this (e.g. while the system was suspended) are skipped.
+const alarmMaxDelay = 10 * time.Minute
+
+// Length of the program that is queued for alarms with a folder or category.
+const alarmProgramLength = 15 * time.Minute
+
+// The volume is raised in steps of this interval, the player smooths the transitions.
+const alarmRampInterval = 500 * time.Millisecond
+
+var alarmCategories = map[string]func() *library.LibraryFile{
+	"music": library.PickRandomSong,
+	"clips": library.PickRandomClip,
+	"hosts": library.PickRandomHostClip,
+}
+
+// StartAlarms checks the alarms in the background and wakes up the player when one goes off.
+func StartAlarms() {
+	go func() {
+		ticker := time.NewTicker(alarmCheckInterval)
+		defer ticker.Stop()
+
+		last := time.Now()
+		for {
+			var now time.Time
+			select {
+			case now = <-ticker.C:
+			case <-stopSignal:
+				return
+			}
+
+			for _, alarm := range GetAlarms() {
+				due, ok := NextAlarmTime(alarm, last)
+				if !alarm.Enabled || !ok || due.After(now) {
+					continue
+				}
+				if now.Sub(due) > alarmMaxDelay {
+					log.Printf("Missed alarm at %s.", alarm.Time)
+					continue
+				}
+				go triggerAlarm(alarm)
+			}
+
+			last = now
+		}
+	}()
+}
+
+// GetAlarms returns all alarms.
+func GetAlarms() []state.Alarm {
+	return slices.Clone(state.Get().Alarms)
+}
+
+// SaveAlarm adds a new alarm (if the id is empty) or replaces the alarm with the same id.
+// It returns the saved alarm.
+func SaveAlarm(alarm state.Alarm) (state.Alarm, error) {
+	if err := validateAlarm(alarm); err != nil {
+		return alarm, err
+	}
+
+	isNew := alarm.Id == ""
+	if isNew {
+		alarm.Id = uuid.NewString()
+	}
+
+	found := false
+	state.Update(func(s *state.State) {
+		alarms := slices.Clone(s.Alarms)
+		if i := slices.IndexFunc(alarms, func(a state.Alarm) bool { return a.Id == alarm.Id }); i >= 0 {
+			alarms[i] = alarm
+			found = true
+		} else if isNew {
+			alarms = append(alarms, alarm)
+		}
+		s.Alarms = alarms
+	})
+
+	if !isNew && !found {
+		return alarm, errors.New("alarm not found")
+	}
+
+	return alarm, nil
+}
+
+// DeleteAlarm removes the alarm with the given id.
+func DeleteAlarm(id string) error {
+	found := false
+	state.Update(func(s *state.State) {
+		alarms := slices.DeleteFunc(slices.Clone(s.Alarms), func(a state.Alarm) bool { return a.Id == id })
+		found = len(alarms) < len(s.Alarms)
+		s.Alarms = alarms
+	})
+
+	if !found {
+		return errors.New("alarm not found")
+	}
+	return nil
+}
+
+// NextAlarmTime returns when the alarm goes off next after the given time (regardless of
+// whether it is enabled). The result is false if the alarm never goes off.
+func NextAlarmTime(alarm state.Alarm, after time.Time) (time.Time, bool) {
+	clock, err := time.Parse("15:04", alarm.Time)
+	if err != nil || alarm.Weekdays == 0 {
+		return time.Time{}, false
+	}
+
+	year, month, day := after.Date()
+	for days := range 8 {
+		candidate := time.Date(year, month, day+days, clock.Hour(), clock.Minute(), 0, 0, after.Location())
+		if candidate.After(after) && alarm.Weekdays&(1<<candidate.Weekday()) != 0 {
+			return candidate, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func validateAlarm(alarm state.Alarm) error {
+	if _, err := time.Parse("15:04", alarm.Time); err != nil {
+		return fmt.Errorf("invalid time '%s', expected HH:MM", alarm.Time)
+	}
+	if alarm.Weekdays == 0 || alarm.Weekdays > 0x7F {
+		return errors.New("weekdays must contain at least one day")
+	}
+	if _, ok := alarmCategories[alarm.Category]; alarm.Category != "" && !ok {
+		return fmt.Errorf("unknown category '%s'", alarm.Category)
+	}
+	if alarm.Folder != "" && library.PickRandomFromFolder(alarm.Folder) == nil {
+		return fmt.Errorf("folder '%s' does not contain any playable files", alarm.Folder)
+	}
+	if alarm.Volume < 0 || alarm.Volume > 1 {
+		return errors.New("volume must be between 0 and 1")
+	}
+	if alarm.Ramp < 0 || alarm.Ramp > time.Hour.Seconds() {
+		return errors.New("ramp must be between 0 and 3600 seconds")
+	}
+	return nil
+}
+
+// triggerAlarm queues the program of the alarm and, if the player is paused, resumes the
+// playback with the volume ramping up from silence.
+func triggerAlarm(alarm state.Alarm) {
+	log.Printf("Alarm at %s went off.", alarm.Time)
+	player.CancelSleepTimer()
+
+	// Fetching the news might take a few seconds.
+	program := alarmProgram(alarm)
+
+	// The program is played before anything the user queued.
+	for _, clip := range slices.Backward(program) {
+		player.QueueClipNext(clip)
+	}
+
+	_, isPauseClip := player.GetCurrentlyPlaying().(*clips.PauseClip)
+	if !player.IsPaused() && !isPauseClip {
+		// Already awake, the program follows the current clip.
+		return
+	}
+
+	target := alarm.Volume
+	if target == 0 {
+		target = player.GetVolume()
+	}
+	player.SetVolume(0)
+
+	if len(program) > 0 || isPauseClip {
+		// Skipping also resumes the playback.
+		player.SkipCurrent(true)
+	} else if err := player.Resume(); err != nil {
+		log.Printf("Failed to resume playback: %v", err)
+		return
+	}
+
+	rampVolume(target, time.Duration(alarm.Ramp*float64(time.Second)))
+}
+
+// alarmProgram creates the clips that are played when the alarm goes off (might be empty).
+func alarmProgram(alarm state.Alarm) []player.Clip {
+	var program []player.Clip
+
+	if alarm.News {
+		if clip, err := fetchTagesschauClip(); err == nil {
+			program = append(program, clip)
+		} else {
+			log.Printf("Alarm without news: %v", err)
+		}
+	}
+
+	pick := alarmCategories[alarm.Category]
+	if alarm.Folder != "" {
+		pick = func() *library.LibraryFile { return library.PickRandomFromFolder(alarm.Folder) }
+	}
+	if pick == nil {
+		return program
+	}
+
+	// Limit the number of clips in case the durations are unknown, and the attempts in case
+	// many files are broken.
+	var length time.Duration
+	for attempts := 0; length < alarmProgramLength && len(program) < 20 && attempts < 40; attempts++ {
+		file := pick()
+		if file == nil {
+			log.Printf("Not enough files for the alarm at %s.", alarm.Time)
+			break
+		}
+
+		clip := file.CreateClip()
+		if clip == nil {
+			// The file is broken (the failure has been reported), try another one.
+			continue
+		}
+		program = append(program, clip)
+		length += clip.Duration()
+	}
+
+	return program
+}
+
+// rampVolume raises the volume from silence to the target over the given duration.
+// It stops when the volume is changed by someone else in the meantime.
+func rampVolume(target float32, duration time.Duration) {
+	if duration <= 0 {
+		player.SetVolume(target)
+		return
+	}
+
+	ticker := time.NewTicker(alarmRampInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	expected := player.GetVolume()
+
+	for range ticker.C {
+		if player.GetVolume() != expected {
+			log.Println("Volume changed during the alarm, stopping the ramp.")
+			return
+		}
+
+		progress := min(1, time.Since(start).Seconds()/duration.Seconds())
+		expected = max(0, min(1, target*float32(progress)))
+		player.SetVolume(expected)
+
+		if progress >= 1 {
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tim-we/wavestreamer/state"
+)
+
+func TestNextAlarmTime(t *testing.T) {
+	// Wednesday
+	now := time.Date(2025, 4, 23, 7, 30, 0, 0, time.UTC)
+
+	weekdays := uint8(1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday)
+
+	tests := []struct {
+		alarm    state.Alarm
+		expected time.Time
+	}{
+		// Later today
+		{state.Alarm{Time: "08:15", Weekdays: weekdays}, time.Date(2025, 4, 23, 8, 15, 0, 0, time.UTC)},
+		// Already passed today
+		{state.Alarm{Time: "07:30", Weekdays: weekdays}, time.Date(2025, 4, 24, 7, 30, 0, 0, time.UTC)},
+		// Weekend only
+		{state.Alarm{Time: "09:00", Weekdays: 1<<time.Saturday | 1<<time.Sunday}, time.Date(2025, 4, 26, 9, 0, 0, 0, time.UTC)},
+		// Same weekday next week
+		{state.Alarm{Time: "06:00", Weekdays: 1 << time.Wednesday}, time.Date(2025, 4, 30, 6, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		next, ok := NextAlarmTime(test.alarm, now)
+		if !ok || !next.Equal(test.expected) {
+			t.Errorf("Expected %+v to go off at %v, got %v", test.alarm, test.expected, next)
+		}
+	}
+
+	if _, ok := NextAlarmTime(state.Alarm{Time: "06:00"}, now); ok {
+		t.Error("Expected an alarm without weekdays to never go off")
+	}
+}
+
+func TestValidateAlarm(t *testing.T) {
+	valid := state.Alarm{Time: "06:45", Weekdays: 0x7F, Category: "music", Volume: 0.5, Ramp: 60}
+	if err := validateAlarm(valid); err != nil {
+		t.Errorf("Expected %+v to be valid, got %v", valid, err)
+	}
+
+	invalid := []state.Alarm{
+		{Time: "6:45pm", Weekdays: 0x7F},
+		{Time: "25:00", Weekdays: 0x7F},
+		{Time: "06:45"},
+		{Time: "06:45", Weekdays: 0x7F, Category: "podcasts"},
+		{Time: "06:45", Weekdays: 0x7F, Folder: "music/does-not-exist"},
+		{Time: "06:45", Weekdays: 0x7F, Volume: 2},
+		{Time: "06:45", Weekdays: 0x7F, Ramp: -1},
+	}
+	for _, alarm := range invalid {
+		if err := validateAlarm(alarm); err == nil {
+			t.Errorf("Expected %+v to be invalid", alarm)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/tim-we/wavestreamer/library"
@@ -10,37 +11,94 @@ import (
 
 var schedulerQueue = make(chan player.Clip, 3)
 
+// Closed by Stop, ends the scheduling loop.
+var stopSignal = make(chan struct{})
+var stopOnce sync.Once
+
+// Random picks of the library, replaced in tests.
+var (
+	pickSong     = library.PickRandomSong
+	pickHostClip = library.PickRandomHostClip
+	pickClip     = library.PickRandomClip
+)
+
+// How long to wait before trying again if the library has nothing to play (e.g. all files are broken).
+var idleRetryDelay = 10 * time.Second
+
 func Start() {
-	go func() {
-		for {
-			// First play music for ~10min
-			musicTime := 0 * time.Second
-			for musicTime < 10*time.Minute {
-				if t := enqueueFile(library.PickRandomSong()); t > 0 {
-					musicTime += t
-				} else {
-					break
-				}
-			}
-
-			// Then either play a host clip...
-			if rand.Intn(100) < 50 {
-				if t := enqueueFile(library.PickRandomHostClip()); t > 0 {
-					continue
-				}
-			}
-
-			// ... or play some random clip(s).
-			clipsTime := 0 * time.Second
-			clipsCount := 0
-			for clipsTime < time.Minute && clipsCount < 2 {
-				if t := enqueueFile(library.PickRandomClip()); t > 0 {
-					clipsTime += t
-					clipsCount++
-				}
-			}
+	go schedule()
+}
+
+// schedule fills the queue until Stop is called.
+func schedule() {
+	for !stopped() {
+		if scheduleRound() {
+			continue
+		}
+
+		select {
+		case <-stopSignal:
+		case <-time.After(idleRetryDelay):
+		}
+	}
+}
+
+// scheduleRound enqueues music followed by a host clip or some random clips.
+// It returns false if nothing could be enqueued.
+func scheduleRound() bool {
+	// First play music for ~10min
+	musicTime := 0 * time.Second
+	for musicTime < 10*time.Minute {
+		if t := enqueueFile(pickSong()); t > 0 {
+			musicTime += t
+		} else {
+			break
+		}
+	}
+
+	// Then either play a host clip...
+	if rand.Intn(100) < 50 {
+		if t := enqueueFile(pickHostClip()); t > 0 {
+			return true
+		}
+	}
+
+	// ... or play some random clip(s).
+	clipsTime := 0 * time.Second
+	clipsCount := 0
+	for clipsTime < time.Minute && clipsCount < 2 {
+		if t := enqueueFile(pickClip()); t > 0 {
+			clipsTime += t
+			clipsCount++
+		} else {
+			break
 		}
-	}()
+	}
+
+	return musicTime > 0 || clipsCount > 0
+}
+
+// Stop ends the scheduling and stops the clips that are waiting in the queue.
+func Stop() {
+	stopOnce.Do(func() { close(stopSignal) })
+
+	for {
+		select {
+		case clip := <-schedulerQueue:
+			clip.Stop()
+		default:
+			return
+		}
+	}
+}
+
+func stopped() bool {
+	select {
+	case <-stopSignal:
+		return true
+	default:
+		return false
+	}
 }
 
 // GetNextClip returns a Clip or nil. It does not block.
@@ -64,7 +122,11 @@ func enqueueFile(file *library.LibraryFile) time.Duration {
 		return 0
 	}
 
-	schedulerQueue <- clip
-
-	return clip.Duration()
+	select {
+	case schedulerQueue <- clip:
+		return clip.Duration()
+	case <-stopSignal:
+		clip.Stop()
+		return 0
+	}
 }
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tim-we/wavestreamer/library"
+)
+
+func TestSchedulerBacksOffWhenAllFilesAreBroken(t *testing.T) {
+	var picks atomic.Int32
+	// Like a library set whose files are all broken.
+	broken := func() *library.LibraryFile {
+		picks.Add(1)
+		return nil
+	}
+	defer func(song, hostClip, clip func() *library.LibraryFile, delay time.Duration) {
+		pickSong, pickHostClip, pickClip, idleRetryDelay = song, hostClip, clip, delay
+	}(pickSong, pickHostClip, pickClip, idleRetryDelay)
+	pickSong, pickHostClip, pickClip = broken, broken, broken
+	idleRetryDelay = 20 * time.Millisecond
+	stopSignal, stopOnce = make(chan struct{}), sync.Once{}
+
+	done := make(chan struct{})
+	go func() {
+		schedule()
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	Stop()
+	<-done
+
+	// One round picks at most three times, without the back-off it would be millions.
+	if count := picks.Load(); count == 0 || count > 3*6 {
+		t.Errorf("Expected a few rounds of picks, got %d picks", count)
+	}
+	if clip := GetNextClip(); clip != nil {
+		t.Errorf("Expected no clip, got %v", clip)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/xml"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -113,53 +112,59 @@ func StartTagesschauScheduler() {
 				log.Println("Tagesschau automatically scheduled.")
 			}
 
-			// Fetch latest episode
-			rssDataRaw, rssDownloadError := utils.DownloadToMemory(PODCAST_FEED)
-			if rssDownloadError != nil {
-				log.Printf("Error downloading Tagesschau RSS:\n%v\n", rssDownloadError)
+			clip, err := fetchTagesschauClip()
+			if err != nil {
+				log.Printf("Error fetching Tagesschau episode:\n%v\n", err)
 				// Lets try again later
 				continue
 			}
 
-			episode, decodeError := extractLatestMP3URL(rssDataRaw)
-			if decodeError != nil {
-				log.Printf("Error decoding Tagesschau RSS:\n%v\n", decodeError)
-				// Lets try again later
-				continue
-			}
+			// And finally... schedule the clip
+			player.QueueClip(clip)
+		}
+	}()
+}
 
-			if time.Since(episode.PubDate) > (24 * time.Hour) {
-				log.Printf("No recent Tagesschau episode available (%v)\n", episode.PubDate)
-				// Lets try again later
-				continue
-			}
+// fetchTagesschauClip downloads the latest episode. The downloaded file is removed when the clip is stopped.
+func fetchTagesschauClip() (*clips.AudioClip, error) {
+	rssDataRaw, err := utils.DownloadToMemory(PODCAST_FEED)
+	if err != nil {
+		return nil, fmt.Errorf("downloading RSS: %w", err)
+	}
 
-			tmpFile, downloadErr := utils.DownloadToTempFile(episode.URL)
-			if downloadErr != nil {
-				log.Printf("Error downloading Tagesschau episode:\n%v\n", downloadErr)
-				// Lets try again later
-				continue
-			}
-			tmpFile.Close()
+	episode, err := extractLatestMP3URL(rssDataRaw)
+	if err != nil {
+		return nil, fmt.Errorf("decoding RSS: %w", err)
+	}
 
-			// Create clip with custom meta data
-			clip, err := clips.NewAudioClip(tmpFile.Name())
-			if err != nil {
-				log.Printf("Failed to create Tagesschau clip:\n%v\n", err)
-			}
-			clip.SetMetaData(episode.PubDate.Format("02.01.06 - 15:04"), "Tagesschau in 100s", "")
+	if time.Since(episode.PubDate) > (24 * time.Hour) {
+		return nil, fmt.Errorf("no recent episode available (%v)", episode.PubDate)
+	}
 
-			// Cleanup
-			clip.OnStop = func() {
-				if err := os.Remove(tmpFile.Name()); err != nil {
-					log.Printf("Failed to remove temporary file %s.\n", err)
-				}
-			}
+	tmpFile, err := utils.DownloadToTempFile(episode.URL)
+	if err != nil {
+		return nil, fmt.Errorf("downloading episode: %w", err)
+	}
+	tmpFile.Close()
 
-			// And finally... schedule the clip
-			player.QueueClip(clip)
+	// Create clip with custom meta data
+	clip, err := clips.NewAudioClip(tmpFile.Name())
+	if err != nil {
+		utils.RemoveTempFile(tmpFile.Name())
+		return nil, fmt.Errorf("creating clip: %w", err)
+	}
+	clip.SetMetaData(episode.PubDate.Format("02.01.06 - 15:04"), "Tagesschau in 100s", "")
+	// The news should be clearly separated from the music.
+	clip.DisableCrossfade()
+
+	// Cleanup
+	clip.OnStop = func() {
+		if err := utils.RemoveTempFile(tmpFile.Name()); err != nil {
+			log.Printf("Failed to remove temporary file %s.\n", err)
 		}
-	}()
+	}
+
+	return clip, nil
 }
 
 func ScheduleTagesschauNow() {
@@ -0,0 +1,138 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State holds everything that should survive a restart.
+type State struct {
+	// Master volume in [0, 1]. nil if it has never been changed.
+	Volume *float32 `json:"volume,omitempty"`
+
+	// Wake-up alarms (see scheduler/alarm.go).
+	Alarms []Alarm `json:"alarms,omitempty"`
+}
+
+// Alarm resumes the playback at a time of day.
+type Alarm struct {
+	Id      string `json:"id"`
+	Enabled bool   `json:"enabled"`
+	// Local time of day, e.g. "06:45"
+	Time string `json:"time"`
+	// Days on which the alarm goes off. Bit i stands for time.Weekday(i), i.e. bit 0 is Sunday.
+	Weekdays uint8 `json:"weekdays"`
+	// Optional program: songs from a folder of the library (e.g. "music/morning")
+	// or a category ("music", "clips" or "hosts"), preceded by the latest news.
+	Folder   string `json:"folder,omitempty"`
+	Category string `json:"category,omitempty"`
+	News     bool   `json:"news,omitempty"`
+	// Target volume in [0, 1] (0 = keep the current volume).
+	Volume float32 `json:"volume,omitempty"`
+	// Duration of the volume ramp in seconds.
+	Ramp float64 `json:"ramp"`
+}
+
+const fileName = "state.json"
+
+// Changes are written to disk after this delay, so that rapid changes
+// (e.g. dragging a volume slider) result in a single write.
+const saveDelay = 2 * time.Second
+
+var (
+	mu        sync.Mutex
+	current   State
+	statePath string
+	saveTimer *time.Timer
+)
+
+// DefaultDir returns the default directory for the state file (~/.config/wavestreamer on Linux).
+func DefaultDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "wavestreamer")
+}
+
+// Load reads the state from the given directory. If dir is empty the state is only kept in memory.
+// A missing state file is not an error.
+func Load(dir string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	statePath = filepath.Join(dir, fileName)
+
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &current)
+}
+
+// Get returns a copy of the current state.
+func Get() State {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return current
+}
+
+// Update modifies the state and schedules writing it to disk.
+func Update(modify func(state *State)) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	modify(&current)
+
+	if statePath == "" {
+		return
+	}
+
+	if saveTimer != nil {
+		saveTimer.Stop()
+	}
+	saveTimer = time.AfterFunc(saveDelay, func() {
+		if err := Save(); err != nil {
+			log.Printf("Failed to save state: %v", err)
+		}
+	})
+}
+
+// Save writes the state to disk immediately.
+func Save() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if statePath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a crash does not leave a corrupted state file behind.
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, statePath)
+}
@@ -0,0 +1,30 @@
+package state
+
+import (
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := Load(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	volume := float32(0.42)
+	Update(func(state *State) { state.Volume = &volume })
+
+	if err := Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	current = State{}
+
+	if err := Load(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded := Get().Volume; loaded == nil || *loaded != volume {
+		t.Errorf("Expected volume %v after loading, got %v", volume, loaded)
+	}
+}
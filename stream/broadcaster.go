@@ -0,0 +1,372 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tim-we/wavestreamer/config"
+	"github.com/tim-we/wavestreamer/player"
+	"github.com/tim-we/wavestreamer/player/output"
+)
+
+type Format string
+
+const (
+	MP3  Format = "mp3"
+	Opus Format = "opus"
+)
+
+// Number of audio bytes between two ICY metadata blocks.
+const icyMetaInterval = 16000
+
+// How many encoded packets a listener may lag behind before it gets disconnected.
+const listenerBufferSize = 128
+
+// Broadcaster encodes the mixed player output once and serves it to any number of HTTP listeners.
+// The encoder (ffmpeg) only runs while there is at least one listener.
+type Broadcaster struct {
+	format  Format
+	bitrate int
+
+	// PCM data from the audio thread to the encoder (buffers from pcmPool).
+	pcm    chan *[]byte
+	active atomic.Bool
+
+	mu        sync.Mutex
+	listeners map[*listener]struct{}
+	header    []byte // Ogg header pages, sent to every new listener
+	title     string
+	wake      chan struct{}
+}
+
+type listener struct {
+	data chan []byte
+}
+
+func NewBroadcaster(format Format, bitrate int) *Broadcaster {
+	return &Broadcaster{
+		format:    format,
+		bitrate:   bitrate,
+		pcm:       make(chan *[]byte, 64),
+		listeners: make(map[*listener]struct{}),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Start runs the encoder loop in the background and follows the now playing information.
+func (b *Broadcaster) Start() {
+	go func() {
+		for event := range player.Subscribe(context.Background()) {
+			if nowPlaying, ok := event.(*player.NowPlayingEvent); ok && nowPlaying.CurrentClip != nil {
+				b.mu.Lock()
+				b.title = nowPlaying.CurrentClip.Name()
+				b.mu.Unlock()
+			}
+		}
+	}()
+
+	go func() {
+		for range b.wake {
+			if b.listenerCount() == 0 {
+				continue
+			}
+			if err := b.encode(); err != nil {
+				log.Printf("Stream encoder failed: %v", err)
+				b.disconnectAll()
+				// Avoid restarting a broken encoder in a tight loop.
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+}
+
+// Write is an output tap. It is called from the audio thread and never blocks.
+func (b *Broadcaster) Write(out [][]float32) {
+	if !b.active.Load() {
+		return
+	}
+
+	sendPCM(b.pcm, out)
+}
+
+// PCM buffers are recycled once the encoder has written them, so that the
+// audio thread does not allocate for every buffer.
+var pcmPool = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// sendPCM converts the output to 16-bit PCM and passes it on without blocking.
+// The receiver must return the buffer with releasePCM.
+func sendPCM(pcm chan<- *[]byte, out [][]float32) {
+	buffer := pcmPool.Get().(*[]byte)
+	*buffer = output.AppendPCM16((*buffer)[:0], out)
+
+	select {
+	case pcm <- buffer:
+	default:
+		// Encoder is too slow, drop audio rather than stalling the output.
+		releasePCM(buffer)
+	}
+}
+
+func releasePCM(buffer *[]byte) {
+	pcmPool.Put(buffer)
+}
+
+// discardPCM drops the buffered PCM data.
+func discardPCM(pcm chan *[]byte) {
+	for len(pcm) > 0 {
+		releasePCM(<-pcm)
+	}
+}
+
+// ServeHTTP streams the encoded audio to a client until it disconnects.
+func (b *Broadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", b.contentType())
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	w.Header().Set("icy-name", "wavestreamer")
+
+	var writer io.Writer = w
+	if r.Header.Get("Icy-MetaData") == "1" {
+		w.Header().Set("icy-metaint", strconv.Itoa(icyMetaInterval))
+		writer = newIcyWriter(w, icyMetaInterval, b.currentTitle)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	l := b.addListener()
+	defer b.removeListener(l)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data, ok := <-l.data:
+			if !ok {
+				// Disconnected by the broadcaster.
+				return
+			}
+			if _, err := writer.Write(data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (b *Broadcaster) addListener() *listener {
+	l := &listener{data: make(chan []byte, listenerBufferSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.header != nil {
+		l.data <- b.header
+	}
+	b.listeners[l] = struct{}{}
+	log.Printf("Stream listener connected (%d total)", len(b.listeners))
+
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+
+	return l
+}
+
+func (b *Broadcaster) removeListener(l *listener) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.listeners[l]; ok {
+		delete(b.listeners, l)
+		close(l.data)
+		log.Printf("Stream listener disconnected (%d remaining)", len(b.listeners))
+	}
+}
+
+func (b *Broadcaster) disconnectAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for l := range b.listeners {
+		delete(b.listeners, l)
+		close(l.data)
+	}
+}
+
+func (b *Broadcaster) listenerCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.listeners)
+}
+
+// broadcast sends encoded data to all listeners. Slow listeners are disconnected
+// because dropping parts of the encoded stream would corrupt it.
+func (b *Broadcaster) broadcast(data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for l := range b.listeners {
+		select {
+		case l.data <- data:
+		default:
+			log.Println("Stream listener is too slow, disconnecting.")
+			delete(b.listeners, l)
+			close(l.data)
+		}
+	}
+}
+
+func (b *Broadcaster) currentTitle() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.title
+}
+
+// encode runs ffmpeg until there are no more listeners.
+func (b *Broadcaster) encode() error {
+	cmd := exec.Command("ffmpeg", b.encoderArgs()...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	log.Printf("Started %s stream encoder.", b.format)
+
+	// Discard stale audio from a previous encoder run.
+	discardPCM(b.pcm)
+	b.active.Store(true)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case data := <-b.pcm:
+				_, err := stdin.Write(*data)
+				releasePCM(data)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	readErr := b.pump(bufio.NewReader(stdout))
+
+	b.active.Store(false)
+	close(done)
+	stdin.Close()
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	b.mu.Lock()
+	b.header = nil
+	b.mu.Unlock()
+
+	log.Printf("Stopped %s stream encoder.", b.format)
+
+	return readErr
+}
+
+// pump forwards encoder output to the listeners until none are left.
+func (b *Broadcaster) pump(reader *bufio.Reader) error {
+	headerComplete := b.format != Opus
+
+	for b.listenerCount() > 0 {
+		var data []byte
+
+		if b.format == Opus {
+			// Ogg streams must be split at page boundaries so that new listeners can join.
+			page, err := readOggPage(reader)
+			if err != nil {
+				return err
+			}
+
+			if !headerComplete {
+				if page.granulePosition == 0 {
+					// Header pages are kept for listeners that join later.
+					b.mu.Lock()
+					b.header = append(b.header, page.data...)
+					b.mu.Unlock()
+				} else {
+					headerComplete = true
+				}
+			}
+			data = page.data
+		} else {
+			buffer := make([]byte, 4096)
+			n, err := reader.Read(buffer)
+			if err != nil {
+				return err
+			}
+			data = buffer[:n]
+		}
+
+		b.broadcast(data)
+	}
+
+	return nil
+}
+
+func (b *Broadcaster) encoderArgs() []string {
+	return encoderArgs(b.format, b.bitrate, "pipe:1")
+}
+
+// encoderArgs returns the ffmpeg arguments for encoding the raw player output (from stdin).
+func encoderArgs(format Format, bitrate int, output string) []string {
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-f", "s16le", // raw input from the player
+		"-ar", strconv.Itoa(config.SAMPLE_RATE),
+		"-ac", strconv.Itoa(config.CHANNELS),
+		"-i", "pipe:0",
+		"-b:a", fmt.Sprintf("%dk", bitrate),
+	}
+
+	switch format {
+	case Opus:
+		// Opus only supports a fixed set of sample rates.
+		args = append(args, "-c:a", "libopus", "-ar", "48000", "-f", "ogg")
+	default:
+		args = append(args, "-c:a", "libmp3lame", "-f", "mp3")
+	}
+
+	return append(args, output)
+}
+
+func (b *Broadcaster) contentType() string {
+	if b.format == Opus {
+		return "audio/ogg"
+	}
+	return "audio/mpeg"
+}
@@ -0,0 +1,93 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tim-we/wavestreamer/player"
+)
+
+func TestBroadcastFansOutToAllListeners(t *testing.T) {
+	b := NewBroadcaster(MP3, 128)
+	listeners := []*listener{b.addListener(), b.addListener(), b.addListener()}
+
+	b.broadcast([]byte("abc"))
+	b.broadcast([]byte("def"))
+
+	for i, l := range listeners {
+		for _, expected := range []string{"abc", "def"} {
+			if data := <-l.data; string(data) != expected {
+				t.Errorf("Listener %d: expected %q, got %q", i, expected, data)
+			}
+		}
+	}
+
+	if count := b.listenerCount(); count != len(listeners) {
+		t.Errorf("Expected %d listeners, got %d", len(listeners), count)
+	}
+}
+
+func TestBroadcastDisconnectsSlowListeners(t *testing.T) {
+	b := NewBroadcaster(MP3, 128)
+	slow := b.addListener()
+	fast := b.addListener()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Only the fast listener reads, the slow one falls behind.
+		for range listenerBufferSize + 1 {
+			b.broadcast([]byte("x"))
+			<-fast.data
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Broadcasting blocked on a slow listener")
+	}
+
+	// The slow listener got everything up to the point it was disconnected.
+	received := 0
+	for range slow.data {
+		received++
+	}
+	if received != listenerBufferSize {
+		t.Errorf("Expected the slow listener to get %d packets, got %d", listenerBufferSize, received)
+	}
+
+	if count := b.listenerCount(); count != 1 {
+		t.Errorf("Expected only the fast listener to remain, got %d listeners", count)
+	}
+}
+
+func TestBroadcasterWriteNeverBlocks(t *testing.T) {
+	b := NewBroadcaster(Opus, 96)
+	b.active.Store(true)
+	out := player.NewAudioChunk().Channels
+
+	// Nobody reads the PCM data, as if the encoder was stuck.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 2 * cap(b.pcm) {
+			b.Write(out)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Write blocked on a stuck encoder")
+	}
+
+	if len(b.pcm) != cap(b.pcm) {
+		t.Errorf("Expected %d buffered writes, got %d", cap(b.pcm), len(b.pcm))
+	}
+
+	// Dropped buffers are recycled instead of allocating new ones.
+	if allocs := testing.AllocsPerRun(100, func() { b.Write(out) }); allocs > 0 {
+		t.Errorf("Expected no allocations per write, got %f", allocs)
+	}
+}
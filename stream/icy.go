@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"io"
+	"strings"
+)
+
+// icyWriter interleaves SHOUTcast/Icecast (ICY) metadata blocks with the audio data.
+// Every metaInt audio bytes a metadata block is inserted. Its first byte is the length
+// of the block divided by 16, an empty block (length 0) means the title is unchanged.
+type icyWriter struct {
+	w         io.Writer
+	metaInt   int
+	untilMeta int
+	title     func() string
+	lastTitle string
+}
+
+func newIcyWriter(w io.Writer, metaInt int, title func() string) *icyWriter {
+	return &icyWriter{
+		w:         w,
+		metaInt:   metaInt,
+		untilMeta: metaInt,
+		title:     title,
+	}
+}
+
+func (iw *icyWriter) Write(p []byte) (int, error) {
+	written := 0
+
+	for len(p) > 0 {
+		n := min(len(p), iw.untilMeta)
+		if _, err := iw.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+		iw.untilMeta -= n
+
+		if iw.untilMeta == 0 {
+			if _, err := iw.w.Write(iw.nextMetaBlock()); err != nil {
+				return written, err
+			}
+			iw.untilMeta = iw.metaInt
+		}
+	}
+
+	return written, nil
+}
+
+func (iw *icyWriter) nextMetaBlock() []byte {
+	title := iw.title()
+	if title == iw.lastTitle {
+		return []byte{0}
+	}
+	iw.lastTitle = title
+
+	return icyMetaBlock(title)
+}
+
+// icyMetaBlock formats the title as a length prefixed ICY metadata block.
+func icyMetaBlock(title string) []byte {
+	// The stream title is terminated by "';" so it must not contain that sequence.
+	title = strings.ReplaceAll(title, "';", "'")
+	meta := "StreamTitle='" + title + "';"
+
+	// The length is stored in a single byte in units of 16 bytes.
+	const maxLength = 255 * 16
+	if len(meta) > maxLength {
+		meta = meta[:maxLength-2] + "';"
+	}
+
+	blocks := (len(meta) + 15) / 16
+	data := make([]byte, 1+blocks*16)
+	data[0] = byte(blocks)
+	copy(data[1:], meta)
+
+	return data
+}
@@ -0,0 +1,41 @@
+package stream
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIcyMetaBlock(t *testing.T) {
+	block := icyMetaBlock("Artist - Title")
+
+	if len(block) != 1+int(block[0])*16 {
+		t.Fatalf("Length byte %d does not match block size %d", block[0], len(block))
+	}
+
+	expected := "StreamTitle='Artist - Title';"
+	if !bytes.HasPrefix(block[1:], []byte(expected)) {
+		t.Errorf("Unexpected metadata %q", block[1:])
+	}
+}
+
+func TestIcyWriterInterleavesMetadata(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := newIcyWriter(&buffer, 4, func() string { return "Song" })
+
+	writer.Write([]byte("abcdef"))
+	writer.Write([]byte("gh"))
+
+	data := buffer.Bytes()
+	meta := icyMetaBlock("Song")
+
+	// First block after 4 bytes contains the title.
+	if !bytes.Equal(data[:4], []byte("abcd")) || !bytes.Equal(data[4:4+len(meta)], meta) {
+		t.Fatalf("Unexpected first metadata block in %q", data)
+	}
+
+	// Second block is empty because the title has not changed.
+	rest := data[4+len(meta):]
+	if !bytes.Equal(rest, []byte("efgh\x00")) {
+		t.Errorf("Expected unchanged title marker, got %q", rest)
+	}
+}
@@ -0,0 +1,52 @@
+package stream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+type oggPage struct {
+	granulePosition uint64
+	// The complete page including its header.
+	data []byte
+}
+
+const oggPageHeaderSize = 27
+
+// readOggPage reads exactly one page of an Ogg bitstream.
+func readOggPage(r io.Reader) (*oggPage, error) {
+	header := make([]byte, oggPageHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if string(header[0:4]) != "OggS" {
+		return nil, fmt.Errorf("invalid Ogg page signature")
+	}
+
+	segmentCount := int(header[26])
+	segmentTable := make([]byte, segmentCount)
+	if _, err := io.ReadFull(r, segmentTable); err != nil {
+		return nil, err
+	}
+
+	bodySize := 0
+	for _, size := range segmentTable {
+		bodySize += int(size)
+	}
+
+	data := make([]byte, 0, oggPageHeaderSize+segmentCount+bodySize)
+	data = append(data, header...)
+	data = append(data, segmentTable...)
+	data = data[:cap(data)]
+
+	if _, err := io.ReadFull(r, data[oggPageHeaderSize+segmentCount:]); err != nil {
+		return nil, err
+	}
+
+	return &oggPage{
+		granulePosition: binary.LittleEndian.Uint64(header[6:14]),
+		data:            data,
+	}, nil
+}
@@ -0,0 +1,280 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Segment file names start with this prefix, followed by the start time.
+const segmentPrefix = "aircheck-"
+
+// With seconds, so that a restart within the same minute starts a new segment.
+const segmentTimeFormat = "2006-01-02_15-04-05"
+
+// The quota is checked this often while recording, not only when a new segment starts.
+const quotaCheckInterval = time.Minute
+
+// Recorder writes the mixed player output to disk ("air-check"). A new file (segment)
+// is started every hour, old segments are deleted when they exceed the quota.
+type Recorder struct {
+	dir     string
+	format  Format
+	bitrate int
+
+	// Quota (0 = unlimited)
+	maxSize int64
+	maxAge  time.Duration
+
+	// PCM data from the audio thread to the encoder (buffers from pcmPool).
+	pcm chan *[]byte
+
+	mu      sync.Mutex
+	current string // name of the segment that is being recorded
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Segment is a recorded file.
+type Segment struct {
+	Name      string    `json:"name"`
+	Start     time.Time `json:"start"`
+	Size      int64     `json:"size"`
+	Recording bool      `json:"recording"`
+}
+
+// NewRecorder creates a recorder that stores its segments in the given directory.
+func NewRecorder(dir string, format Format, bitrate int, maxSize int64, maxAge time.Duration) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		dir:     dir,
+		format:  format,
+		bitrate: bitrate,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		pcm:     make(chan *[]byte, 64),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Start records segments in the background until Stop is called.
+func (r *Recorder) Start() {
+	go func() {
+		defer close(r.done)
+
+		// Audio buffered before the first segment or while the encoder was failing is outdated.
+		// At a regular rollover it is the start of the next segment.
+		discard := true
+
+		for {
+			r.deleteOldSegments()
+
+			start := time.Now()
+			err := r.record(start, nextHour(start), discard)
+			discard = err != nil
+
+			select {
+			case <-r.stop:
+				return
+			default:
+			}
+
+			if err != nil {
+				log.Printf("Recording failed: %v", err)
+				// Avoid restarting a broken encoder in a tight loop.
+				select {
+				case <-time.After(10 * time.Second):
+				case <-r.stop:
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Stop finishes the current segment.
+func (r *Recorder) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// Write is an output tap. It is called from the audio thread and never blocks.
+func (r *Recorder) Write(out [][]float32) {
+	sendPCM(r.pcm, out)
+}
+
+// record encodes the output into a new segment until the end time. The audio that has been
+// buffered while no encoder was running is either discarded or written to the new segment.
+func (r *Recorder) record(start, end time.Time, discardBuffered bool) error {
+	name := segmentPrefix + start.Format(segmentTimeFormat) + r.extension()
+
+	// Existing segments are never overwritten (-n), the encoder fails instead.
+	cmd := exec.Command("ffmpeg", append([]string{"-n"}, encoderArgs(r.format, r.bitrate, filepath.Join(r.dir, name))...)...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.current = name
+	r.mu.Unlock()
+	log.Printf("Recording to %s", name)
+
+	if discardBuffered {
+		discardPCM(r.pcm)
+	}
+
+	timer := time.NewTimer(time.Until(end))
+	defer timer.Stop()
+
+	quotaTicker := time.NewTicker(quotaCheckInterval)
+	defer quotaTicker.Stop()
+
+	var writeErr error
+loop:
+	for {
+		select {
+		case data := <-r.pcm:
+			_, writeErr = stdin.Write(*data)
+			releasePCM(data)
+			if writeErr != nil {
+				break loop
+			}
+		case <-quotaTicker.C:
+			r.deleteOldSegments()
+		case <-timer.C:
+			break loop
+		case <-r.stop:
+			break loop
+		}
+	}
+
+	// Closing stdin lets ffmpeg finish the file.
+	stdin.Close()
+	err = cmd.Wait()
+
+	r.mu.Lock()
+	r.current = ""
+	r.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return writeErr
+}
+
+// Segments returns the recorded segments, oldest first.
+func (r *Recorder) Segments() ([]Segment, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	current := r.current
+	r.mu.Unlock()
+
+	var segments []Segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentPrefix) {
+			continue
+		}
+
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), filepath.Ext(name))
+		start, err := time.ParseInLocation(segmentTimeFormat, timestamp, time.Local)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		segments = append(segments, Segment{
+			Name:      name,
+			Start:     start,
+			Size:      info.Size(),
+			Recording: name == current,
+		})
+	}
+
+	slices.SortFunc(segments, func(a, b Segment) int { return a.Start.Compare(b.Start) })
+
+	return segments, nil
+}
+
+// SegmentPath returns the path of the segment with the given name.
+func (r *Recorder) SegmentPath(name string) (string, error) {
+	if !strings.HasPrefix(name, segmentPrefix) || filepath.Base(name) != name {
+		return "", errors.New("invalid segment name")
+	}
+
+	path := filepath.Join(r.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.New("segment not found")
+	}
+	return path, nil
+}
+
+// deleteOldSegments deletes the oldest segments until the remaining ones are within the quota.
+func (r *Recorder) deleteOldSegments() {
+	segments, err := r.Segments()
+	if err != nil {
+		log.Printf("Failed to list recordings: %v", err)
+		return
+	}
+
+	var total int64
+	for _, segment := range segments {
+		total += segment.Size
+	}
+
+	for _, segment := range segments {
+		tooOld := r.maxAge > 0 && time.Since(segment.Start) > r.maxAge
+		tooBig := r.maxSize > 0 && total > r.maxSize
+		if segment.Recording || (!tooOld && !tooBig) {
+			// Segments are sorted, the remaining ones are newer.
+			break
+		}
+
+		if err := os.Remove(filepath.Join(r.dir, segment.Name)); err != nil {
+			log.Printf("Failed to delete recording: %v", err)
+			continue
+		}
+		log.Printf("Deleted old recording %s", segment.Name)
+		total -= segment.Size
+	}
+}
+
+func (r *Recorder) extension() string {
+	if r.format == Opus {
+		return ".opus"
+	}
+	return ".mp3"
+}
+
+// nextHour returns the start of the hour after the given time.
+func nextHour(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, t.Hour()+1, 0, 0, 0, t.Location())
+}
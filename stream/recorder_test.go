@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorderDeletesOldSegments(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	// Four segments of 100 bytes, one per hour.
+	var names []string
+	for hours := 3; hours >= 0; hours-- {
+		name := segmentPrefix + now.Add(-time.Duration(hours)*time.Hour).Format(segmentTimeFormat) + ".mp3"
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 100), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	// Other files are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewRecorder(dir, MP3, 128, 250, 150*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.current = names[3]
+
+	recorder.deleteOldSegments()
+
+	segments, err := recorder.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The oldest segment is too old, the second oldest exceeds the size quota.
+	if len(segments) != 2 || segments[0].Name != names[2] || !segments[1].Recording {
+		t.Errorf("Expected the two newest segments to remain, got %+v", segments)
+	}
+}
+
+func TestRecorderSegmentPath(t *testing.T) {
+	recorder, err := NewRecorder(t.TempDir(), Opus, 96, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"../secret", "aircheck-../../secret", "aircheck-2025-01-01_10-00-00.opus"} {
+		if _, err := recorder.SegmentPath(name); err == nil {
+			t.Errorf("Expected an error for '%s'", name)
+		}
+	}
+}
+
+func TestNextHour(t *testing.T) {
+	next := nextHour(time.Date(2025, 12, 31, 23, 42, 5, 0, time.UTC))
+	if expected := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, next)
+	}
+}
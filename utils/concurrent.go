@@ -63,6 +63,17 @@ func (queue *ConcurrentQueue[T]) GetNext() (T, bool) {
 	return item, true
 }
 
+// Clear removes all items from the queue and returns them.
+func (queue *ConcurrentQueue[T]) Clear() []T {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	items := queue.list
+	queue.list = make([]T, 0, cap(items))
+
+	return items
+}
+
 // Size returns the number of items currently in the queue.
 func (queue *ConcurrentQueue[T]) Size() int {
 	queue.mutex.RLock()
@@ -3,10 +3,15 @@ package utils
 import (
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"sync"
 )
 
+// Temporary files created by DownloadToTempFile that have not been removed yet.
+var tempFiles sync.Map
+
 func DownloadToTempFile(url string) (*os.File, error) {
 	// Make HTTP GET request
 	resp, err := http.Get(url)
@@ -20,12 +25,13 @@ func DownloadToTempFile(url string) (*os.File, error) {
 	if err != nil {
 		return nil, err
 	}
+	tempFiles.Store(tmpFile.Name(), struct{}{})
 
 	// Copy response body to temp file
 	_, err = io.Copy(tmpFile, resp.Body)
 	if err != nil {
 		tmpFile.Close()
-		os.Remove(tmpFile.Name()) // clean up on error
+		RemoveTempFile(tmpFile.Name()) // clean up on error
 		return nil, err
 	}
 
@@ -39,6 +45,22 @@ func DownloadToTempFile(url string) (*os.File, error) {
 	return tmpFile, nil
 }
 
+// RemoveTempFile deletes a file created by DownloadToTempFile.
+func RemoveTempFile(name string) error {
+	tempFiles.Delete(name)
+	return os.Remove(name)
+}
+
+// RemoveTempFiles deletes all downloaded files that are still around, e.g. before exiting.
+func RemoveTempFiles() {
+	tempFiles.Range(func(name, _ any) bool {
+		if err := RemoveTempFile(name.(string)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove temporary file: %v", err)
+		}
+		return true
+	})
+}
+
 func DownloadToMemory(url string) ([]byte, error) {
 	resp, err := http.Get(url)
 	if err != nil {
@@ -1,30 +1,79 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jessevdk/go-flags"
+	"github.com/tim-we/wavestreamer/config"
 	"github.com/tim-we/wavestreamer/gpio"
 	"github.com/tim-we/wavestreamer/library"
+	"github.com/tim-we/wavestreamer/meter"
 	"github.com/tim-we/wavestreamer/player"
 	"github.com/tim-we/wavestreamer/player/clips"
+	"github.com/tim-we/wavestreamer/player/decoder"
+	"github.com/tim-we/wavestreamer/player/dsp"
+	"github.com/tim-we/wavestreamer/player/output"
 	"github.com/tim-we/wavestreamer/scheduler"
+	"github.com/tim-we/wavestreamer/state"
+	"github.com/tim-we/wavestreamer/stream"
+	"github.com/tim-we/wavestreamer/utils"
 	"github.com/tim-we/wavestreamer/webapp"
 )
 
 type AppOptions struct {
-	MusicDir    string `short:"d" long:"music-dir" description:"Path to directory containing music files"`
-	News        bool   `short:"n" long:"news" description:"Enable hourly news (Tagesschau in 100s)"`
-	WebApp      bool   `short:"w" long:"webapp" description:"Enable web app" `
-	WebAppPort  int    `short:"p" long:"port" description:"Web App Port" default:"6969"`
-	GPIO        bool   `short:"i" long:"gpio" description:"Enable GPIO controls"`
-	GPIOPin     string `long:"gpio-pin" description:"GPIO data signal pin. Default: GPIO17"`
-	NoNormalize bool   `long:"no-normalize" description:"Disable automatic loudness normalization"`
-	Version     bool   `short:"v" long:"version" description:"Display version & build information"`
+	MusicDir      string        `short:"d" long:"music-dir" description:"Path to directory containing music files"`
+	News          bool          `short:"n" long:"news" description:"Enable hourly news (Tagesschau in 100s)"`
+	WebApp        bool          `short:"w" long:"webapp" description:"Enable web app" `
+	WebAppPort    int           `short:"p" long:"port" description:"Web App Port" default:"6969"`
+	Stream        bool          `short:"s" long:"stream" description:"Serve a live stream of the output at /stream (requires the web app)"`
+	StreamFormat  string        `long:"stream-format" description:"Live stream format" choice:"mp3" choice:"opus" default:"mp3"`
+	StreamBitrate int           `long:"stream-bitrate" description:"Live stream bitrate in kbit/s" default:"128"`
+	RecordDir     string        `long:"record-dir" description:"Record the output into hourly files in this directory (air-check)"`
+	RecordFormat  string        `long:"record-format" description:"Recording format" choice:"mp3" choice:"opus" default:"mp3"`
+	RecordBitrate int           `long:"record-bitrate" description:"Recording bitrate in kbit/s" default:"64"`
+	RecordMaxSize int64         `long:"record-max-size" description:"Delete the oldest recordings when they take up more than this many MB (0 = unlimited)" default:"2048"`
+	RecordMaxAge  time.Duration `long:"record-max-age" description:"Delete recordings older than this (0 = never)" default:"168h"`
+	GPIO          bool          `short:"i" long:"gpio" description:"Enable GPIO controls"`
+	GPIOPin       string        `long:"gpio-pin" description:"GPIO data signal pin. Default: GPIO17"`
+	NoNormalize   bool          `long:"no-normalize" description:"Disable all loudness adjustments (also ignores ReplayGain tags and --target-lufs)"`
+	ReplayGain    string        `long:"replaygain" description:"Use ReplayGain tags instead of the dynamic normalization if a file has them" choice:"off" choice:"track" choice:"album" default:"track"`
+	Preamp        float64       `long:"replaygain-preamp" description:"Additional gain in dB for files with ReplayGain tags" default:"0"`
+	TargetLUFS    float64       `long:"target-lufs" description:"Analyze the loudness of every file (EBU R128) and play all of them at this loudness, e.g. -16 (0 = disabled)" default:"0"`
+	DuckLevel     float64       `long:"duck-level" description:"Lower the music by this many dB while announcements or beeps are played over it" default:"12"`
+	DuckFade      time.Duration `long:"duck-fade" description:"Duration of the transition when lowering or restoring the music" default:"300ms"`
+	PauseTimeout  time.Duration `long:"pause-timeout" description:"Release the decoder of the current song after pausing for this long (0 = never)" default:"5m"`
+	TimeShift     time.Duration `long:"timeshift" description:"Keep this much of the output in memory, so that it can be replayed (0 = disabled)" default:"3m"`
+	ShutdownFade  time.Duration `long:"shutdown-fade" description:"Fade out the output over this duration when stopped by SIGINT or SIGTERM" default:"1s"`
+	EQ            []string      `long:"eq" description:"Equalizer band as type:frequency:gain[:q], e.g. lowshelf:120:-6 (can be repeated). Types: peak, lowshelf, highshelf, lowpass, highpass"`
+	CompThreshold float64       `long:"compressor-threshold" description:"Compress audio above this level in dBFS, e.g. -20 (0 = disabled)" default:"0"`
+	CompRatio     float64       `long:"compressor-ratio" description:"Compression ratio (see --compressor-threshold)" default:"3"`
+	Limiter       float64       `long:"limiter" description:"Limit the output to this level in dBFS, e.g. -1 (0 = disabled)" default:"0"`
+	Balance       float32       `long:"balance" description:"Stereo balance from -1 (left) to 1 (right)" default:"0"`
+	Mono          bool          `long:"mono" description:"Play the same (mixed down) signal on both channels"`
+	DspOrder      string        `long:"dsp-order" description:"Order in which the output processors are applied (processors that are not listed are not used)" default:"eq,compressor,stereo,limiter"`
+	MaxFailures   int           `long:"max-failures" description:"Stop picking files randomly after they failed to play this many times (0 = never)" default:"3"`
+	TrimSilence   bool          `long:"trim-silence" description:"Skip silence at the beginning and the end of songs"`
+	SilenceLevel  float64       `long:"silence-threshold" description:"Audio below this level in dBFS is considered silent (see --trim-silence)" default:"-50"`
+	SilenceLength time.Duration `long:"silence-min-length" description:"Only trim silences of at least this length (see --trim-silence)" default:"1s"`
+	Crossfade     time.Duration `long:"crossfade" description:"Overlap consecutive songs by this duration, e.g. 4s (0 = disabled)" default:"0s"`
+	NativeMP3     bool          `long:"native-mp3" description:"Decode MP3 files without ffmpeg (experimental)"`
+	Output        string        `short:"o" long:"output" description:"Audio output" choice:"portaudio" choice:"null" choice:"wav" default:"portaudio"`
+	SampleRate    int           `long:"sample-rate" description:"Output sample rate in Hz" default:"44100"`
+	Channels      int           `long:"channels" description:"Number of output channels (1 = mono, 2 = stereo)" choice:"1" choice:"2" default:"2"`
+	BufferSize    int           `long:"frames-per-buffer" description:"Output buffer size in frames. Larger buffers are more robust but increase latency" default:"1024"`
+	Device        string        `long:"device" description:"PortAudio output device (name or index, see --list-devices). Default: system default"`
+	ListDevices   bool          `long:"list-devices" description:"List the available output devices and exit"`
+	OutputFile    string        `long:"output-file" description:"Target file for the wav output"`
+	StateDir      string        `long:"state-dir" description:"Directory for persistent state like the volume. Default: ~/.config/wavestreamer"`
+	Version       bool          `short:"v" long:"version" description:"Display version & build information"`
 }
 
 // These will be replaced in the GitHub Actions workflow
@@ -45,13 +94,53 @@ func main() {
 		return
 	}
 
+	if opts.ListDevices {
+		printDevices()
+		return
+	}
+
 	CheckFFmpegDependencies()
 
+	if err := config.SetAudioFormat(opts.SampleRate, opts.Channels, opts.BufferSize); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	if len(opts.MusicDir) == 0 {
 		fmt.Println("Required argument -d or --music-dir not set.")
 		os.Exit(1)
 	}
 
+	sink, err := output.New(opts.Output, opts.OutputFile, opts.Device)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if opts.StateDir == "" {
+		opts.StateDir = state.DefaultDir()
+	}
+	if err := state.Load(opts.StateDir); err != nil {
+		log.Printf("Failed to load state from %s: %v", opts.StateDir, err)
+	}
+	restoreVolume()
+
+	if opts.NoNormalize {
+		// No gain changes at all, not even the ones from tags or the loudness analysis.
+		opts.ReplayGain = string(player.ReplayGainOff)
+		opts.TargetLUFS = 0
+	}
+
+	if opts.TargetLUFS < 0 {
+		fmt.Printf("Normalizing files to %.1f LUFS (analysis runs in the background).\n", opts.TargetLUFS)
+		library.EnableLoudnessNormalization(opts.StateDir, opts.TargetLUFS)
+	}
+
+	library.SetMaxFailures(opts.MaxFailures)
+	if opts.NativeMP3 {
+		decoder.EnableNativeMP3()
+	}
+
 	fmt.Println("Using music directory:", opts.MusicDir)
 	library.WatchRootDir(opts.MusicDir)
 
@@ -63,18 +152,51 @@ func main() {
 
 	fmt.Println("Starting scheduler...")
 	scheduler.Start()
+	scheduler.StartAlarms()
 
 	if opts.News {
 		fmt.Println("Starting Tagesschau loop...")
 		scheduler.StartTagesschauScheduler()
 	}
 
+	var broadcaster *stream.Broadcaster
+	if opts.Stream {
+		if !opts.WebApp {
+			fmt.Println("The live stream requires the web app (-w).")
+			os.Exit(1)
+		}
+		fmt.Printf("Live stream enabled (%s, %d kbit/s).\n", opts.StreamFormat, opts.StreamBitrate)
+		broadcaster = stream.NewBroadcaster(stream.Format(opts.StreamFormat), opts.StreamBitrate)
+		broadcaster.Start()
+		player.AddOutputTap(broadcaster.Write)
+	}
+
+	var recorder *stream.Recorder
+	if opts.RecordDir != "" {
+		fmt.Printf("Recording the output to %s (%s, %d kbit/s).\n", opts.RecordDir, opts.RecordFormat, opts.RecordBitrate)
+		recorder, err = stream.NewRecorder(
+			opts.RecordDir,
+			stream.Format(opts.RecordFormat),
+			opts.RecordBitrate,
+			opts.RecordMaxSize*1024*1024,
+			opts.RecordMaxAge,
+		)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		recorder.Start()
+		player.AddOutputTap(recorder.Write)
+	}
+
 	if opts.WebApp {
 		fmt.Println("Starting web server...")
 		if opts.WebAppPort < 1024 {
 			log.Println("Warning: Ports below 1024 require root access.")
 		}
-		webapp.StartServer(opts.WebAppPort, opts.News)
+		levelMeter := meter.NewMeter()
+		player.AddOutputTap(levelMeter.Write)
+		webapp.StartServer(opts.WebAppPort, opts.News, broadcaster, levelMeter, recorder)
 	}
 
 	if opts.GPIO {
@@ -86,13 +208,142 @@ func main() {
 		fmt.Println("Loudness normalization is enabled (default).")
 	}
 
+	if opts.Crossfade > 0 {
+		fmt.Printf("Crossfading songs over %v.\n", opts.Crossfade)
+		player.SetCrossfade(opts.Crossfade)
+	}
+
+	if opts.TrimSilence {
+		fmt.Printf("Trimming silence below %.0f dBFS.\n", opts.SilenceLevel)
+		clips.SetSilenceTrimming(opts.SilenceLevel, opts.SilenceLength)
+	}
+
+	player.SetPauseTimeout(opts.PauseTimeout)
+	player.SetTimeShift(opts.TimeShift)
+	player.SetReplayGain(player.ReplayGainMode(opts.ReplayGain), opts.Preamp)
+	player.SetDucking(-opts.DuckLevel, opts.DuckFade)
+
+	if err := applyDspOptions(opts); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	// To avoid circular dependencies we have to create the beep clip here.
 	player.SetBeepProvider(func() player.Clip { return clips.NewBeep() })
 
+	go handleSignals(opts.ShutdownFade)
+
 	fmt.Println("Starting playback loop...")
-	player.Start(scheduler.GetNextClip, !opts.NoNormalize)
+	player.Start(scheduler.GetNextClip, sink, !opts.NoNormalize)
 
 	fmt.Println("Player stopped.")
+
+	scheduler.Stop()
+	if recorder != nil {
+		recorder.Stop()
+	}
+	utils.RemoveTempFiles()
+	if err := state.Save(); err != nil {
+		log.Printf("Failed to save state: %v", err)
+	}
+}
+
+// handleSignals shuts the player down on SIGINT or SIGTERM, which lets main return.
+// A second signal terminates the process immediately.
+func handleSignals(fade time.Duration) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	fmt.Println("Shutting down...")
+	player.Shutdown(fade)
+}
+
+// applyDspOptions sets up the processors applied to the output.
+func applyDspOptions(opts AppOptions) error {
+	specs := map[string]dsp.ProcessorSpec{}
+
+	for _, value := range opts.EQ {
+		band, err := dsp.ParseEQBand(value)
+		if err != nil {
+			return err
+		}
+		specs[dsp.EQName] = dsp.ProcessorSpec{EQ: append(specs[dsp.EQName].EQ, band)}
+	}
+
+	if opts.CompThreshold < 0 {
+		specs[dsp.CompressorName] = dsp.ProcessorSpec{
+			Compressor: &dsp.CompressorConfig{Threshold: opts.CompThreshold, Ratio: opts.CompRatio},
+		}
+	}
+
+	if opts.Balance != 0 || opts.Mono {
+		specs[dsp.StereoName] = dsp.ProcessorSpec{
+			Stereo: &dsp.StereoConfig{Balance: opts.Balance, Mono: opts.Mono},
+		}
+	}
+
+	if opts.Limiter < 0 {
+		specs[dsp.LimiterName] = dsp.ProcessorSpec{Limiter: &dsp.LimiterConfig{Ceiling: opts.Limiter}}
+	}
+
+	var cfg dsp.Config
+	for _, name := range strings.Split(opts.DspOrder, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case dsp.EQName, dsp.CompressorName, dsp.StereoName, dsp.LimiterName:
+		default:
+			return fmt.Errorf("unknown processor '%s' in --dsp-order", name)
+		}
+		if spec, ok := specs[name]; ok {
+			cfg.Processors = append(cfg.Processors, spec)
+		}
+	}
+
+	return dsp.Apply(cfg)
+}
+
+// restoreVolume sets the volume of the last session and keeps track of future changes.
+func restoreVolume() {
+	if volume := state.Get().Volume; volume != nil {
+		fmt.Printf("Volume: %.0f%%\n", *volume*100)
+		player.SetVolume(*volume)
+	}
+
+	player.SetVolumeObserver(func(float32) {
+		state.Update(func(s *state.State) {
+			// Concurrent changes might call this out of order, the current volume is the last one.
+			volume := player.GetVolume()
+			s.Volume = &volume
+		})
+	})
+}
+
+func printDevices() {
+	devices, err := output.ListDevices()
+	if err != nil {
+		fmt.Println("Failed to list devices:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Output devices:")
+	for _, device := range devices {
+		marker := " "
+		if device.IsDefault {
+			marker = "*"
+		}
+		fmt.Printf(
+			"%s %2d: %s (%s, %d channels, %.0f Hz, %d ms latency)\n",
+			marker,
+			device.Index,
+			device.Name,
+			device.HostApi,
+			device.Channels,
+			device.DefaultSampleRate,
+			device.DefaultLatency.Milliseconds(),
+		)
+	}
+	fmt.Println("* = default device")
 }
 
 // CheckFFmpegDependencies verifies that ffmpeg and ffprobe are available in PATH.
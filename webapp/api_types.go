@@ -1,6 +1,13 @@
 package webapp
 
-import "github.com/tim-we/wavestreamer/player"
+import (
+	"time"
+
+	"github.com/tim-we/wavestreamer/player"
+	"github.com/tim-we/wavestreamer/player/dsp"
+	"github.com/tim-we/wavestreamer/state"
+	"github.com/tim-we/wavestreamer/stream"
+)
 
 type ApiNowResponse struct {
 	Status      string              `json:"status"`
@@ -13,6 +20,15 @@ type ApiNowPlayingEvent struct {
 	Current string                `json:"current"`
 	IsPause bool                  `json:"isPause"`
 	History []player.HistoryEntry `json:"history"`
+	ApiProgressEvent
+}
+
+type ApiProgressEvent struct {
+	// Elapsed and total playback time of the current clip in seconds.
+	// The duration is 0 if it is unknown or infinite.
+	Elapsed  float64   `json:"elapsed"`
+	Duration float64   `json:"duration"`
+	Started  time.Time `json:"started"`
 }
 
 type ApiNowLibraryInfo struct {
@@ -45,3 +61,95 @@ type ApiConfigResponse struct {
 	Status string `json:"status"`
 	News   bool   `json:"news"`
 }
+
+type ApiVolumeResponse struct {
+	Status string  `json:"status"`
+	Volume float32 `json:"volume"`
+}
+
+type ApiSleepTimerResponse struct {
+	Status string `json:"status"`
+	Active bool   `json:"active"`
+	// Seconds until the playback is paused.
+	Remaining float64 `json:"remaining"`
+}
+
+type ApiAlarmsResponse struct {
+	Status string       `json:"status"`
+	Alarms []AlarmEntry `json:"alarms"`
+}
+
+type ApiAlarmResponse struct {
+	Status string     `json:"status"`
+	Alarm  AlarmEntry `json:"alarm"`
+}
+
+type AlarmEntry struct {
+	state.Alarm
+	// When the alarm goes off next (nil if it is disabled).
+	Next *time.Time `json:"next,omitempty"`
+}
+
+type ApiRecordingsResponse struct {
+	Status   string           `json:"status"`
+	Segments []stream.Segment `json:"segments"`
+}
+
+type ApiDspResponse struct {
+	Status string     `json:"status"`
+	Config dsp.Config `json:"config"`
+}
+
+type ApiOutputResponse struct {
+	Status          string `json:"status"`
+	Output          string `json:"output"`
+	Device          string `json:"device,omitempty"`
+	LatencyMs       int64  `json:"latencyMs,omitempty"`
+	SampleRate      int    `json:"sampleRate"`
+	Channels        int    `json:"channels"`
+	FramesPerBuffer int    `json:"framesPerBuffer"`
+	// Edges of the bands of the level spectrum in Hz.
+	SpectrumBands []float64 `json:"spectrumBands"`
+}
+
+type ApiLevelsEvent struct {
+	// Per channel, linear (1 = full scale).
+	RMS  []float32 `json:"rms"`
+	Peak []float32 `json:"peak"`
+	// Level of every band in dBFS (see ApiOutputResponse.SpectrumBands).
+	Spectrum []float32 `json:"spectrum,omitempty"`
+}
+
+type ApiUnderflowsResponse struct {
+	Status string `json:"status"`
+	// Number of output buffers that had to be filled with silence.
+	Total       uint64                   `json:"total"`
+	CurrentClip uint64                   `json:"currentClip"`
+	Recent      []player.UnderflowRecord `json:"recent"`
+}
+
+type ApiUnderflowEvent struct {
+	Clip      string `json:"clip"`
+	Total     uint64 `json:"total"`
+	ReduceCPU bool   `json:"reduceCPU"`
+}
+
+type ApiClipErrorEvent struct {
+	Clip  string `json:"clip"`
+	Error string `json:"error"`
+}
+
+type ApiProblemFilesResponse struct {
+	Status string             `json:"status"`
+	Files  []ProblemFileEntry `json:"files"`
+}
+
+type ProblemFileEntry struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Failures int    `json:"failures"`
+	// Broken files are excluded from random picks.
+	Broken      bool      `json:"broken"`
+	Error       string    `json:"error"`
+	LastFailure time.Time `json:"lastFailure"`
+}
@@ -7,15 +7,23 @@ import (
 	"fmt"
 	"io/fs"
 	"log"
+	"math"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/tim-we/wavestreamer/config"
 	"github.com/tim-we/wavestreamer/library"
+	"github.com/tim-we/wavestreamer/meter"
 	"github.com/tim-we/wavestreamer/player"
 	"github.com/tim-we/wavestreamer/player/clips"
+	"github.com/tim-we/wavestreamer/player/dsp"
+	"github.com/tim-we/wavestreamer/player/output"
 	"github.com/tim-we/wavestreamer/scheduler"
+	"github.com/tim-we/wavestreamer/state"
+	"github.com/tim-we/wavestreamer/stream"
 	"github.com/tim-we/wavestreamer/utils"
 )
 
@@ -24,7 +32,7 @@ var content embed.FS
 
 var startTime = time.Now()
 
-func StartServer(port int, news bool) {
+func StartServer(port int, news bool, broadcaster *stream.Broadcaster, levelMeter *meter.Meter, recorder *stream.Recorder) {
 	// Strip the "dist" prefix so files are served at root (/)
 	staticFiles, err := fs.Sub(content, "dist")
 	if err != nil {
@@ -34,6 +42,11 @@ func StartServer(port int, news bool) {
 	// Serve static (embedded) files
 	http.Handle("/", http.FileServer(http.FS(staticFiles)))
 
+	// Live stream of the output (optional)
+	if broadcaster != nil {
+		http.Handle("/stream", broadcaster)
+	}
+
 	// API endpoints:
 
 	addJsonEndpoint("/api/now", func(r *http.Request) (any, error) {
@@ -84,6 +97,16 @@ func StartServer(port int, news bool) {
 			switch ev := unknownEvent.(type) {
 			case *player.NowPlayingEvent:
 				data = createNowPlaying(ev.CurrentClip)
+			case *player.ProgressEvent:
+				data = createProgress(ev.CurrentClip, ev.Position)
+			case *player.VolumeChangedEvent:
+				data = ApiVolumeResponse{"ok", ev.Volume}
+			case *player.UnderflowEvent:
+				data = createUnderflowEvent(ev)
+			case *player.ClipErrorEvent:
+				data = ApiClipErrorEvent{Clip: ev.CurrentClip.Name(), Error: ev.Error.Error()}
+			case *player.SleepTimerEvent:
+				data = createSleepTimerResponse(ev.Remaining)
 			default:
 				break
 			}
@@ -100,24 +123,110 @@ func StartServer(port int, news bool) {
 		}
 	})
 
+	http.HandleFunc("/api/levels", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			respondWithError(w, http.StatusInternalServerError, "streaming unsupported")
+			return
+		}
+		if utils.ShouldReduceCPU() {
+			respondWithError(w, http.StatusNotAcceptable, "Currently not available.")
+			return
+		}
+
+		// Updates per second (1-20) and whether the spectrum should be included.
+		rate := 15
+		if value := r.URL.Query().Get("rate"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > 20 {
+				respondWithError(w, http.StatusBadRequest, "Rate must be between 1 and 20.")
+				return
+			}
+			rate = parsed
+		}
+		spectrum, _ := strconv.ParseBool(r.URL.Query().Get("spectrum"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		for levels := range levelMeter.Listen(r.Context(), time.Second/time.Duration(rate)) {
+			event := ApiLevelsEvent{RMS: levels.RMS, Peak: levels.Peak}
+			if spectrum {
+				event.Spectrum = levels.Spectrum()
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to marshal JSON: %v", err)
+				break
+			}
+
+			fmt.Fprint(w, "event: levels\n")
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	})
+
 	addJsonEndpoint("/api/skip", func(r *http.Request) (any, error) {
 		player.SkipCurrent(false)
 		return ApiOkResponse{"ok"}, nil
 	})
 
 	addJsonEndpoint("/api/pause", func(r *http.Request) (any, error) {
-		current := player.GetCurrentlyPlaying()
+		// Scheduled pauses (clips) are skipped, otherwise the playback is paused or resumed.
+		if _, isPause := player.GetCurrentlyPlaying().(*clips.PauseClip); isPause {
+			player.SkipCurrent(true)
+			return ApiOkResponse{"ok"}, nil
+		}
 
-		// If the current clip is a Pause we don't schedule another one,
-		// we skip the current one (see below)
-		if _, isPause := current.(*clips.PauseClip); current == nil || !isPause {
-			player.QueueClip(clips.NewPause(10 * time.Minute))
+		var err error
+		if player.IsPaused() {
+			err = player.Resume()
+		} else {
+			err = player.Pause()
 		}
+		if err != nil {
+			return nil, err
+		}
+
+		return ApiOkResponse{"ok"}, nil
+	})
 
-		player.SkipCurrent(true)
+	addJsonEndpoint("/api/replay", func(r *http.Request) (any, error) {
+		length, err := parseReplayLength(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := player.ReplayLast(length); err != nil {
+			return nil, err
+		}
 		return ApiOkResponse{"ok"}, nil
 	})
 
+	http.HandleFunc("/api/replay/download", func(w http.ResponseWriter, r *http.Request) {
+		length, err := parseReplayLength(r)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		pcm, err := player.GetRecentOutput(length)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="replay-%s.wav"`, time.Now().Format("2006-01-02_15-04-05")))
+		if err := output.WriteWavHeader(w, uint32(len(pcm))); err != nil {
+			return
+		}
+		w.Write(pcm)
+	})
+
 	addJsonEndpoint("/api/repeat", func(r *http.Request) (any, error) {
 		current := player.GetCurrentlyPlaying()
 		if current == nil {
@@ -130,6 +239,144 @@ func StartServer(port int, news bool) {
 		return ApiOkResponse{"ok"}, nil
 	})
 
+	addJsonEndpoint("/api/seek", func(r *http.Request) (any, error) {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+
+		// Either an absolute position or a relative offset (both in seconds).
+		switch {
+		case r.Form.Has("position"):
+			position, err := parseSeconds(r.Form.Get("position"))
+			if err != nil {
+				return nil, err
+			}
+			if err := player.Seek(position); err != nil {
+				return nil, err
+			}
+		case r.Form.Has("offset"):
+			offset, err := parseSeconds(r.Form.Get("offset"))
+			if err != nil {
+				return nil, err
+			}
+			if err := player.SeekRelative(offset); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New("Either position or offset must be set.")
+		}
+
+		return ApiOkResponse{"ok"}, nil
+	})
+
+	addJsonEndpoint("/api/volume", func(r *http.Request) (any, error) {
+		if r.Method == http.MethodPost {
+			if err := r.ParseForm(); err != nil {
+				return nil, err
+			}
+			value, err := strconv.ParseFloat(r.Form.Get("volume"), 32)
+			if err != nil || value < 0 || value > 1 {
+				return nil, errors.New("Volume must be a number between 0 and 1.")
+			}
+			player.SetVolume(float32(value))
+		}
+
+		return ApiVolumeResponse{"ok", player.GetVolume()}, nil
+	})
+
+	addJsonEndpoint("/api/dsp", func(r *http.Request) (any, error) {
+		if r.Method == http.MethodPost {
+			if err := r.ParseForm(); err != nil {
+				return nil, err
+			}
+			var cfg dsp.Config
+			if err := json.Unmarshal([]byte(r.Form.Get("config")), &cfg); err != nil {
+				return nil, fmt.Errorf("Invalid config: %v", err)
+			}
+			if err := dsp.Apply(cfg); err != nil {
+				return nil, err
+			}
+		}
+
+		return ApiDspResponse{"ok", dsp.Current()}, nil
+	})
+
+	addJsonEndpoint("/api/sleep", func(r *http.Request) (any, error) {
+		return createSleepTimerResponse(player.GetSleepTimer()), nil
+	})
+
+	addJsonEndpoint("/api/sleep/set", func(r *http.Request) (any, error) {
+		duration, err := parseMinutesField(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := player.SetSleepTimer(duration); err != nil {
+			return nil, err
+		}
+		return createSleepTimerResponse(player.GetSleepTimer()), nil
+	})
+
+	addJsonEndpoint("/api/sleep/extend", func(r *http.Request) (any, error) {
+		duration, err := parseMinutesField(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := player.ExtendSleepTimer(duration); err != nil {
+			return nil, err
+		}
+		return createSleepTimerResponse(player.GetSleepTimer()), nil
+	})
+
+	addJsonEndpoint("/api/sleep/cancel", func(r *http.Request) (any, error) {
+		player.CancelSleepTimer()
+		return createSleepTimerResponse(0), nil
+	})
+
+	addJsonEndpoint("/api/alarms", func(r *http.Request) (any, error) {
+		alarms := scheduler.GetAlarms()
+		entries := make([]AlarmEntry, len(alarms))
+		for i, alarm := range alarms {
+			entries[i] = createAlarmEntry(alarm)
+		}
+		return ApiAlarmsResponse{"ok", entries}, nil
+	})
+
+	addJsonEndpoint("/api/alarms/save", func(r *http.Request) (any, error) {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		// Defaults for fields that are not set.
+		alarm := state.Alarm{Enabled: true, Weekdays: 0x7F, Ramp: 60}
+		if err := json.Unmarshal([]byte(r.Form.Get("alarm")), &alarm); err != nil {
+			return nil, fmt.Errorf("Invalid alarm: %v", err)
+		}
+		saved, err := scheduler.SaveAlarm(alarm)
+		if err != nil {
+			return nil, err
+		}
+		return ApiAlarmResponse{"ok", createAlarmEntry(saved)}, nil
+	})
+
+	addJsonEndpoint("/api/alarms/delete", func(r *http.Request) (any, error) {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		if err := scheduler.DeleteAlarm(r.Form.Get("id")); err != nil {
+			return nil, err
+		}
+		return ApiOkResponse{"ok"}, nil
+	})
+
+	addJsonEndpoint("/api/underflows", func(r *http.Request) (any, error) {
+		stats := player.GetUnderflowStats()
+		return ApiUnderflowsResponse{
+			Status:      "ok",
+			Total:       stats.Total,
+			CurrentClip: stats.CurrentClip,
+			Recent:      stats.Recent,
+		}, nil
+	})
+
 	addJsonEndpoint("/api/library/search", func(r *http.Request) (any, error) {
 		// Parse query parameters and get the value of `query`
 		query := r.URL.Query().Get("query")
@@ -156,6 +403,66 @@ func StartServer(port int, news bool) {
 		http.ServeFile(w, r, libFile.Path())
 	})
 
+	addJsonEndpoint("/api/library/problems", func(r *http.Request) (any, error) {
+		problems := library.GetProblemFiles()
+		entries := make([]ProblemFileEntry, 0, len(problems))
+		for _, problem := range problems {
+			entries = append(entries, ProblemFileEntry{
+				Id:          problem.File.Id.String(),
+				Name:        problem.File.Name(),
+				Failures:    problem.File.Failures(),
+				Broken:      problem.File.IsBroken(),
+				Error:       problem.Failure.Error,
+				LastFailure: problem.Failure.Time,
+			})
+		}
+		return ApiProblemFilesResponse{"ok", entries}, nil
+	})
+
+	addJsonEndpoint("/api/library/problems/reset", func(r *http.Request) (any, error) {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		fileId, err := uuid.Parse(r.Form.Get("file"))
+		if err != nil {
+			return nil, errors.New("Invalid id value.")
+		}
+		file := library.GetFileById(fileId)
+		if file == nil {
+			return nil, errors.New("File not found.")
+		}
+		file.ResetFailures()
+		return ApiOkResponse{"ok"}, nil
+	})
+
+	addJsonEndpoint("/api/recordings", func(r *http.Request) (any, error) {
+		if recorder == nil {
+			return nil, errors.New("Recording is disabled.")
+		}
+		segments, err := recorder.Segments()
+		if err != nil {
+			return nil, err
+		}
+		return ApiRecordingsResponse{"ok", segments}, nil
+	})
+
+	http.HandleFunc("/api/recordings/download", func(w http.ResponseWriter, r *http.Request) {
+		if recorder == nil {
+			respondWithError(w, http.StatusNotFound, "recording is disabled")
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		path, err := recorder.SegmentPath(name)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		http.ServeFile(w, r, path)
+	})
+
 	addJsonEndpoint("/api/schedule", func(r *http.Request) (any, error) {
 		if err := r.ParseForm(); err != nil {
 			return nil, err
@@ -187,6 +494,30 @@ func StartServer(port int, news bool) {
 		return ApiConfigResponse{"ok", news}, nil
 	})
 
+	addJsonEndpoint("/api/output", func(r *http.Request) (any, error) {
+		sink := player.GetOutput()
+		if sink == nil {
+			return nil, errors.New("Player has not been started yet.")
+		}
+
+		response := ApiOutputResponse{
+			Status:          "ok",
+			Output:          sink.Name(),
+			SampleRate:      config.SAMPLE_RATE,
+			Channels:        config.CHANNELS,
+			FramesPerBuffer: config.FRAMES_PER_BUFFER,
+			SpectrumBands:   meter.SpectrumBands(config.SAMPLE_RATE),
+		}
+
+		if deviceSink, ok := sink.(output.DeviceSink); ok {
+			device, latency := deviceSink.Device()
+			response.Device = device
+			response.LatencyMs = latency.Milliseconds()
+		}
+
+		return response, nil
+	})
+
 	// Start server
 	go func() {
 		log.Printf("Serving on http://localhost:%d\n", port)
@@ -212,13 +543,96 @@ func createNowPlaying(current player.Clip) *ApiNowPlayingEvent {
 		currentClipName = current.Name()
 	}
 
-	_, isPause := current.(*clips.PauseClip)
+	_, isPauseClip := current.(*clips.PauseClip)
+	isPause := isPauseClip || player.IsPaused()
 
 	return &ApiNowPlayingEvent{
-		Current: currentClipName,
-		IsPause: isPause,
-		History: player.GetHistory(),
+		Current:          currentClipName,
+		IsPause:          isPause,
+		History:          player.GetHistory(),
+		ApiProgressEvent: createProgress(current, player.GetPosition()),
+	}
+}
+
+func createProgress(current player.Clip, position time.Duration) ApiProgressEvent {
+	var duration time.Duration
+	if current != nil {
+		duration = current.Duration()
+	}
+
+	return ApiProgressEvent{
+		Elapsed:  position.Seconds(),
+		Duration: duration.Seconds(),
+		Started:  time.Now().Add(-position),
+	}
+}
+
+func createUnderflowEvent(event *player.UnderflowEvent) ApiUnderflowEvent {
+	clipName := "-"
+	if event.CurrentClip != nil {
+		clipName = event.CurrentClip.Name()
+	}
+
+	return ApiUnderflowEvent{
+		Clip:      clipName,
+		Total:     event.Total,
+		ReduceCPU: event.ReduceCPU,
+	}
+}
+
+func createAlarmEntry(alarm state.Alarm) AlarmEntry {
+	entry := AlarmEntry{Alarm: alarm}
+	if next, ok := scheduler.NextAlarmTime(alarm, time.Now()); ok && alarm.Enabled {
+		entry.Next = &next
+	}
+	return entry
+}
+
+func createSleepTimerResponse(remaining time.Duration) ApiSleepTimerResponse {
+	return ApiSleepTimerResponse{
+		Status:    "ok",
+		Active:    remaining > 0,
+		Remaining: remaining.Seconds(),
+	}
+}
+
+// parseReplayLength parses the optional "seconds" field (default: 30 seconds).
+func parseReplayLength(r *http.Request) (time.Duration, error) {
+	if err := r.ParseForm(); err != nil {
+		return 0, err
+	}
+	if !r.Form.Has("seconds") {
+		return 30 * time.Second, nil
+	}
+	length, err := parseSeconds(r.Form.Get("seconds"))
+	if err != nil {
+		return 0, err
+	}
+	if length <= 0 {
+		return 0, errors.New("Seconds must be positive.")
+	}
+	return length, nil
+}
+
+// parseMinutesField parses the positive number of minutes in the form field "minutes".
+func parseMinutesField(r *http.Request) (time.Duration, error) {
+	if err := r.ParseForm(); err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.ParseFloat(r.Form.Get("minutes"), 64)
+	if err != nil || !(minutes > 0) || math.IsInf(minutes, 0) {
+		return 0, errors.New("Minutes must be a positive number.")
+	}
+	return time.Duration(minutes * float64(time.Minute)), nil
+}
+
+// parseSeconds parses a (possibly negative or fractional) number of seconds.
+func parseSeconds(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, fmt.Errorf("Invalid number of seconds: '%s'", value)
 	}
+	return time.Duration(seconds * float64(time.Second)), nil
 }
 
 func addJsonEndpoint(path string, handler func(r *http.Request) (any, error)) {
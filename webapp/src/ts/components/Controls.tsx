@@ -61,12 +61,38 @@ const Controls: FunctionComponent = () => {
           🗞️
         </Button>
       ) : null}
+      <VolumeSlider />
     </section>
   );
 };
 
 export default Controls;
 
+const VolumeSlider: FunctionComponent = () => {
+  const volume = WavestreamerApi.volumeSignal.value;
+
+  if (volume === null) {
+    return null;
+  }
+
+  return (
+    <input
+      id="volume"
+      type="range"
+      title={`volume: ${Math.round(volume * 100)}%`}
+      min={0}
+      max={1}
+      step={0.01}
+      value={volume}
+      onInput={(e) => {
+        const value = Number.parseFloat(e.currentTarget.value);
+        WavestreamerApi.volumeSignal.value = value;
+        WavestreamerApi.setVolume(value).catch((e) => console.error(e));
+      }}
+    />
+  );
+};
+
 type ButtonProps = {
   id?: string;
   label?: string;
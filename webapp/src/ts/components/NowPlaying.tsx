@@ -1,13 +1,21 @@
 import type { FunctionComponent } from "preact";
-import { nowDataSignal } from "../wavestreamer-api";
+import { nowDataSignal, progressSignal } from "../wavestreamer-api";
 
 const NowPlaying: FunctionComponent<unknown> = () => {
   const clip = nowDataSignal.value?.current ?? "-";
+  const progress = progressSignal.value;
 
   return (
     <section id="now">
       <div class="title">🎶 Now playing:</div>
       <div id="current-clip">{clip}</div>
+      {progress && progress.duration > 0 ? (
+        <progress
+          id="current-progress"
+          max={progress.duration}
+          value={Math.min(progress.elapsed, progress.duration)}
+        />
+      ) : null}
     </section>
   );
 };
@@ -3,7 +3,10 @@ import { signal } from "@preact/signals";
 const baseUrl = `http://${window.location.host}/api`;
 
 export const nowDataSignal = signal<NowPlayingEvent | null>(null);
+export const progressSignal = signal<ProgressEvent | null>(null);
+export const volumeSignal = signal<number | null>(null);
 export const connectedSignal = signal<boolean>(true);
+export const sleepTimerSignal = signal<ApiSleepTimerResponse | null>(null);
 
 export async function init(): Promise<void> {
   // initial update
@@ -12,6 +15,9 @@ export async function init(): Promise<void> {
   // notify listeners
   connectedSignal.value = true;
   nowDataSignal.value = data.now;
+  progressSignal.value = data.now;
+  volumeSignal.value = (await getVolume()).volume;
+  sleepTimerSignal.value = await getSleepTimer();
 
   // subscribe to further events
   let source = connect();
@@ -38,7 +44,19 @@ function connect(): EventSource {
   });
   source.addEventListener("now-playing", (e) => {
     connectedSignal.value = true;
-    nowDataSignal.value = JSON.parse(e.data);
+    const data: NowPlayingEvent = JSON.parse(e.data);
+    nowDataSignal.value = data;
+    progressSignal.value = data;
+  });
+  source.addEventListener("progress", (e) => {
+    progressSignal.value = JSON.parse(e.data);
+  });
+  source.addEventListener("volume-changed", (e) => {
+    const data: ApiVolumeResponse = JSON.parse(e.data);
+    volumeSignal.value = data.volume;
+  });
+  source.addEventListener("sleep-timer", (e) => {
+    sleepTimerSignal.value = JSON.parse(e.data);
   });
   return source;
 }
@@ -59,6 +77,73 @@ export async function repeat(): Promise<void> {
   await request("/repeat", "PUT");
 }
 
+/** Plays the last seconds of the output again (the music is paused in the meantime). */
+export async function replay(seconds = 30): Promise<void> {
+  await request(
+    "/replay",
+    "POST",
+    new URLSearchParams({ seconds: seconds.toString() }),
+  );
+}
+
+export function getReplayDownloadUrl(seconds = 30): string {
+  return `${baseUrl}/replay/download?seconds=${seconds}`;
+}
+
+export async function seek(position: number): Promise<void> {
+  await request(
+    "/seek",
+    "POST",
+    new URLSearchParams({ position: position.toString() }),
+  );
+}
+
+export async function seekRelative(offset: number): Promise<void> {
+  await request(
+    "/seek",
+    "POST",
+    new URLSearchParams({ offset: offset.toString() }),
+  );
+}
+
+export function getVolume(): Promise<ApiVolumeResponse> {
+  return request<ApiVolumeResponse>("/volume");
+}
+
+export async function setVolume(volume: number): Promise<void> {
+  await request(
+    "/volume",
+    "POST",
+    new URLSearchParams({ volume: volume.toString() }),
+  );
+}
+
+export function getSleepTimer(): Promise<ApiSleepTimerResponse> {
+  return request<ApiSleepTimerResponse>("/sleep");
+}
+
+export function setSleepTimer(minutes: number): Promise<ApiSleepTimerResponse> {
+  return request<ApiSleepTimerResponse>(
+    "/sleep/set",
+    "POST",
+    new URLSearchParams({ minutes: minutes.toString() }),
+  );
+}
+
+export function extendSleepTimer(
+  minutes: number,
+): Promise<ApiSleepTimerResponse> {
+  return request<ApiSleepTimerResponse>(
+    "/sleep/extend",
+    "POST",
+    new URLSearchParams({ minutes: minutes.toString() }),
+  );
+}
+
+export function cancelSleepTimer(): Promise<ApiSleepTimerResponse> {
+  return request<ApiSleepTimerResponse>("/sleep/cancel", "POST");
+}
+
 export async function search(
   query: string,
 ): Promise<ApiSearchResponse["results"]> {
@@ -111,6 +196,89 @@ export function getConfig(): Promise<ApiConfigResponse> {
   return request<ApiConfigResponse>("/config", "POST");
 }
 
+export function getOutput(): Promise<ApiOutputResponse> {
+  return request<ApiOutputResponse>("/output");
+}
+
+export function getUnderflows(): Promise<ApiUnderflowsResponse> {
+  return request<ApiUnderflowsResponse>("/underflows");
+}
+
+export async function getProblemFiles(): Promise<ProblemFileEntry[]> {
+  const response = await request<ApiProblemFilesResponse>("/library/problems");
+  return response.files;
+}
+
+export async function resetProblemFile(
+  fileId: ProblemFileEntry["id"],
+): Promise<void> {
+  await request(
+    "/library/problems/reset",
+    "POST",
+    new URLSearchParams({ file: fileId }),
+  );
+}
+
+export function getDsp(): Promise<ApiDspResponse> {
+  return request<ApiDspResponse>("/dsp");
+}
+
+export async function setDsp(config: DspConfig): Promise<void> {
+  await request(
+    "/dsp",
+    "POST",
+    new URLSearchParams({ config: JSON.stringify(config) }),
+  );
+}
+
+export async function getAlarms(): Promise<Alarm[]> {
+  const response = await request<ApiAlarmsResponse>("/alarms");
+  return response.alarms;
+}
+
+/** Creates a new alarm (without id) or updates an existing one. */
+export async function saveAlarm(alarm: AlarmConfig): Promise<Alarm> {
+  const response = await request<ApiAlarmResponse>(
+    "/alarms/save",
+    "POST",
+    new URLSearchParams({ alarm: JSON.stringify(alarm) }),
+  );
+  return response.alarm;
+}
+
+export async function deleteAlarm(id: Alarm["id"]): Promise<void> {
+  await request("/alarms/delete", "POST", new URLSearchParams({ id: id }));
+}
+
+/**
+ * Subscribes to the levels of the output (up to 20 updates per second).
+ * Returns a function that ends the subscription.
+ */
+export function subscribeLevels(
+  callback: (levels: LevelsEvent) => void,
+  rate = 15,
+  spectrum = false,
+): () => void {
+  const params = new URLSearchParams({
+    rate: rate.toString(),
+    spectrum: spectrum.toString(),
+  });
+  const source = new EventSource(`${baseUrl}/levels?${params}`);
+  source.addEventListener("levels", (e) => {
+    callback(JSON.parse(e.data));
+  });
+  return () => source.close();
+}
+
+export async function getRecordings(): Promise<RecordingSegment[]> {
+  const response = await request<ApiRecordingsResponse>("/recordings");
+  return response.segments;
+}
+
+export function getRecordingUrl(name: RecordingSegment["name"]): string {
+  return `${baseUrl}/recordings/download?name=${encodeURIComponent(name)}`;
+}
+
 export function getDownloadUrl(clip: SearchResultEntry["id"]): string {
   return `${baseUrl}/library/download?file=${encodeURIComponent(clip)}`;
 }
@@ -140,17 +308,30 @@ type NowData = {
   uptime: string;
 };
 
-type NowPlayingEvent = {
+type NowPlayingEvent = ProgressEvent & {
   current: string;
   isPause: boolean;
   history: HistoryEntry[];
 };
 
+export type ProgressEvent = {
+  /** Elapsed time in seconds */
+  elapsed: number;
+  /** Duration in seconds, 0 if unknown */
+  duration: number;
+  /** Start time of the current clip */
+  started: string;
+};
+
 export type HistoryEntry = {
   start: string;
   title: string;
   skipped: boolean;
   userScheduled: boolean;
+  /** Number of output underflows (stutters) while the clip was playing */
+  underflows?: number;
+  /** Set if the clip could not be played */
+  error?: string;
 };
 
 export type SearchResultEntry = {
@@ -162,3 +343,160 @@ type ApiConfigResponse = {
   status: "ok";
   news: boolean;
 };
+
+type ApiVolumeResponse = {
+  status: "ok";
+  /** Master volume between 0 and 1 */
+  volume: number;
+};
+
+type ApiSleepTimerResponse = {
+  status: "ok";
+  active: boolean;
+  /** Seconds until the playback is paused */
+  remaining: number;
+};
+
+type ApiOutputResponse = {
+  status: "ok";
+  /** Type of the output, e.g. "PortAudio" */
+  output: string;
+  /** Only set for audio devices */
+  device?: string;
+  latencyMs?: number;
+  sampleRate: number;
+  channels: number;
+  framesPerBuffer: number;
+  /** Edges of the spectrum bands in Hz (one more than the number of bands) */
+  spectrumBands: number[];
+};
+
+export type LevelsEvent = {
+  /** Per channel, linear between 0 and 1 */
+  rms: number[];
+  peak: number[];
+  /** dBFS per band, only if requested (see ApiOutputResponse.spectrumBands) */
+  spectrum?: number[];
+};
+
+type ApiUnderflowsResponse = {
+  status: "ok";
+  total: number;
+  currentClip: number;
+  recent: { time: string; clip: string; reduceCPU: boolean }[];
+};
+
+type ApiProblemFilesResponse = {
+  status: "ok";
+  files: ProblemFileEntry[];
+};
+
+export type ProblemFileEntry = {
+  id: string;
+  name: string;
+  failures: number;
+  /** Broken files are excluded from random picks */
+  broken: boolean;
+  /** The last error */
+  error: string;
+  lastFailure: string;
+};
+
+type ApiAlarmsResponse = {
+  status: "ok";
+  alarms: Alarm[];
+};
+
+type ApiAlarmResponse = {
+  status: "ok";
+  alarm: Alarm;
+};
+
+export type AlarmConfig = {
+  /** Not set for new alarms */
+  id?: string;
+  /** Default: true */
+  enabled?: boolean;
+  /** Local time of day, e.g. "06:45" */
+  time: string;
+  /** Bit i stands for weekday i, starting with Sunday (0). Default: every day (127) */
+  weekdays?: number;
+  /** Play songs from this folder of the library, e.g. "music/morning" */
+  folder?: string;
+  category?: "music" | "clips" | "hosts";
+  /** Play the latest news first */
+  news?: boolean;
+  /** Target volume between 0 and 1 (0 = current volume) */
+  volume?: number;
+  /** Seconds until the target volume is reached. Default: 60 */
+  ramp?: number;
+};
+
+export type Alarm = AlarmConfig & {
+  id: string;
+  enabled: boolean;
+  weekdays: number;
+  ramp: number;
+  /** ISO date, not set for disabled alarms */
+  next?: string;
+};
+
+type ApiRecordingsResponse = {
+  status: "ok";
+  /** Oldest first */
+  segments: RecordingSegment[];
+};
+
+export type RecordingSegment = {
+  name: string;
+  /** ISO date */
+  start: string;
+  /** Bytes */
+  size: number;
+  /** The segment is still being recorded */
+  recording: boolean;
+};
+
+type ApiDspResponse = {
+  status: "ok";
+  config: DspConfig;
+};
+
+/** Processors applied to the output, in the given order */
+export type DspConfig = {
+  processors: DspProcessor[];
+};
+
+/** Exactly one of the fields must be set */
+export type DspProcessor = {
+  eq?: {
+    type: "peak" | "lowshelf" | "highshelf" | "lowpass" | "highpass";
+    /** Hz */
+    frequency: number;
+    /** dB */
+    gain: number;
+    q?: number;
+  }[];
+  compressor?: {
+    /** dBFS */
+    threshold: number;
+    ratio: number;
+    /** Milliseconds */
+    attack?: number;
+    release?: number;
+    /** dB */
+    makeup?: number;
+  };
+  stereo?: {
+    /** -1 (left) to 1 (right) */
+    balance: number;
+    mono: boolean;
+  };
+  limiter?: {
+    /** dBFS */
+    ceiling: number;
+    /** Milliseconds */
+    release?: number;
+  };
+};
+};